@@ -1,20 +1,157 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
 	"net/http"
+	"os"
+	"time"
 
+	"github.com/vibe-gaming/esia-mock/internal/certs"
+	"github.com/vibe-gaming/esia-mock/internal/clients"
+	"github.com/vibe-gaming/esia-mock/internal/config"
+	"github.com/vibe-gaming/esia-mock/internal/faults"
+	"github.com/vibe-gaming/esia-mock/internal/fixtures"
 	"github.com/vibe-gaming/esia-mock/internal/handler"
 	"github.com/vibe-gaming/esia-mock/internal/logger"
+	"github.com/vibe-gaming/esia-mock/internal/maintenance"
+	"github.com/vibe-gaming/esia-mock/internal/oauth"
+	"github.com/vibe-gaming/esia-mock/internal/profiles"
+	"github.com/vibe-gaming/esia-mock/internal/ratelimit"
+	"github.com/vibe-gaming/esia-mock/internal/storage"
 	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
 )
 
 func main() {
 	logger.Init("info")
-	RunMockServer()
+
+	if len(os.Args) > 1 && os.Args[1] == "snapshot" {
+		if err := runSnapshotCommand(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	cfg, opts, err := config.Load(os.Args[1:], os.LookupEnv, os.Stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "esia-mock:", err)
+		os.Exit(2)
+	}
+
+	if opts.PrintConfig {
+		enc := yaml.NewEncoder(os.Stdout)
+		enc.SetIndent(2)
+		if err := enc.Encode(cfg.Masked()); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	logger.Configure(cfg.Log.Level, cfg.Log.Format)
+	logger.Info("Effective configuration",
+		zap.String("file", opts.ConfigFile),
+		zap.Any("config", cfg.Masked()))
+
+	RunMockServer(cfg)
 }
 
-func RunMockServer() {
-	h := handler.New()
+func RunMockServer(cfg *config.Config) {
+	// Хранилище кодов, токенов и персон: memory (по умолчанию), bolt (файл) или redis
+	backend, err := storage.Open(cfg.Storage.Backend, cfg.StoragePath())
+	if err != nil {
+		logger.Fatal("Failed to open storage", zap.Error(err))
+	}
+
+	// Seed и версия генерации: разные окружения получают разные, но воспроизводимые данные,
+	// а закрепленная версия не дает обновлению мока менять персоны
+	userCache, err := storage.New(storage.Options{
+		Seed:             cfg.Generator.Seed,
+		GeneratorVersion: cfg.Generator.Version,
+		Backend:          backend,
+		MaxUsers:         cfg.Storage.MaxUsers,
+	})
+	if err != nil {
+		logger.Fatal("Failed to create user cache", zap.Error(err))
+	}
+
+	oauthStore := oauth.NewStore(backend, time.Duration(cfg.OAuth.CodeTTL), time.Duration(cfg.OAuth.TokenTTL))
+	janitorCtx, stopJanitor := context.WithCancel(context.Background())
+	janitorDone := make(chan struct{})
+	go func() {
+		defer close(janitorDone)
+		oauthStore.RunJanitor(janitorCtx, time.Duration(cfg.Storage.JanitorInterval))
+	}()
+
+	registry := clients.NewRegistry(backend)
+
+	// Клиенты из настроек применяются вместе с фикстурами: сброс состояния возвращает и их
+	var fixtureSet *fixtures.Set
+	if len(cfg.Fixtures) > 0 || len(cfg.Clients) > 0 {
+		fixtureSet = &fixtures.Set{}
+		if len(cfg.Fixtures) > 0 {
+			fixtureSet, err = fixtures.Load(cfg.Fixtures)
+			if err != nil {
+				logger.Fatal("Failed to load fixtures", zap.Error(err))
+			}
+		}
+		fixtureSet.Clients = append(fixtureSet.Clients, cfg.Clients...)
+		if err := fixtures.Apply(fixtureSet, userCache, oauthStore, registry); err != nil {
+			logger.Fatal("Failed to apply fixtures", zap.Error(err))
+		}
+		logger.Info("Fixtures loaded",
+			zap.Int("personas", len(fixtureSet.Personas)),
+			zap.Int("clients", len(fixtureSet.Clients)),
+			zap.Int("tokens", len(fixtureSet.Tokens)))
+	}
+
+	h := handler.New(userCache, oauthStore, registry)
+	h.SetFixtures(fixtureSet)
+	h.SetMutualTLS(cfg.Server.TLS.Enabled && cfg.Server.TLS.ClientAuth != certs.ClientAuthNone,
+		cfg.Server.TLS.ClientAuth == certs.ClientAuthRequire)
+	h.SetReadyCheck(backend.Ping)
+
+	// Технические работы проверяются первыми, затем лимиты и правила сбоев:
+	// отклоненный запрос не расходует лимит и не считается подходящим для правила
+	var server http.Handler = http.DefaultServeMux
+
+	if cfg.Features.Faults {
+		faultEngine := faults.NewEngine()
+		for i, rule := range cfg.Faults {
+			if _, err := faultEngine.Add(rule); err != nil {
+				logger.Fatal("Invalid fault rule", zap.Int("index", i), zap.Error(err))
+			}
+		}
+		h.SetFaults(faultEngine)
+		server = faultEngine.Middleware(server, h.RequestIdentity)
+	}
+
+	if cfg.Features.RateLimits {
+		limiter := ratelimit.NewLimiter()
+		for i, rule := range cfg.RateLimits {
+			if _, err := limiter.Add(rule); err != nil {
+				logger.Fatal("Invalid rate limit", zap.Int("index", i), zap.Error(err))
+			}
+		}
+		h.SetRateLimits(limiter)
+		server = limiter.Middleware(server, h.RequestIdentity)
+	}
+
+	if cfg.Features.Maintenance {
+		maintenanceMode := maintenance.NewMode()
+		if err := maintenanceMode.Set(cfg.Maintenance); err != nil {
+			logger.Fatal("Invalid maintenance settings", zap.Error(err))
+		}
+		h.SetMaintenance(maintenanceMode)
+		server = maintenanceMode.Middleware(server)
+	}
 
 	// ESIA OAuth2 endpoints
 	http.HandleFunc("/aas/oauth2/ac", h.Authorize)
@@ -26,15 +163,88 @@ func RunMockServer() {
 		}
 	})
 	http.HandleFunc("/aas/oauth2/te", h.Token)
-	http.HandleFunc("/rs/prns/", h.GetPerson)
+	http.HandleFunc("/rs/prns/", h.Persons)
 	http.HandleFunc("/userinfo", h.UserInfo)
 
-	port := "8085"
+	// Служебные эндпоинты мока
+	http.HandleFunc("/mock/info", h.Info)
+	http.HandleFunc("/mock/stats", h.Stats)
+
+	// Admin API
+	if cfg.Features.AdminAPI {
+		http.HandleFunc("/admin/snapshot", h.Snapshot)
+		http.HandleFunc("/admin/personas", h.Personas)
+		http.HandleFunc("/admin/personas/", h.Personas)
+		http.HandleFunc("/admin/codes", h.Codes)
+		http.HandleFunc("/admin/codes/", h.Codes)
+		http.HandleFunc("/admin/tokens", h.Tokens)
+		http.HandleFunc("/admin/tokens/", h.Tokens)
+		http.HandleFunc("/admin/login", h.Login)
+		http.HandleFunc("/admin/login/token", h.LoginToken)
+		http.HandleFunc("/admin/reset", h.Reset)
+		http.HandleFunc("/admin/reset/", h.Reset)
+		http.HandleFunc("/admin/clients", h.Clients)
+		http.HandleFunc("/admin/clients/", h.Clients)
+		http.HandleFunc("/admin/faults", h.Faults)
+		http.HandleFunc("/admin/faults/", h.Faults)
+		http.HandleFunc("/admin/ratelimits", h.RateLimits)
+		http.HandleFunc("/admin/ratelimits/", h.RateLimits)
+		http.HandleFunc("/admin/maintenance", h.Maintenance)
+	}
+
+	logger.Info("ESIA Mock Server started", zap.String("addr", cfg.Server.Addr), zap.String("seed", cfg.Generator.Seed),
+		zap.Int("generator_version", userCache.GeneratorVersion()),
+		zap.String("storage", cfg.Storage.Backend),
+		zap.Bool("tls", cfg.Server.TLS.Enabled))
+
+	// Проверки состояния не проходят через технические работы, лимиты и правила сбоев
+	root := http.NewServeMux()
+	root.HandleFunc("/healthz", h.Healthz)
+	root.HandleFunc("/readyz", h.Readyz)
+	root.Handle("/", server)
 
-	addr := ":" + port
-	logger.Info("ESIA Mock Server started", zap.String("addr", addr))
+	// Профиль среды выбирается первым: остальные подсистемы видят путь без префикса профиля
+	profileSet, err := profiles.NewSet(cfg.Profiles, cfg.Profile)
+	if err != nil {
+		logger.Fatal("Invalid profiles", zap.Error(err))
+	}
+	for _, p := range profileSet.List() {
+		logger.Info("Environment profile",
+			zap.String("name", p.Name),
+			zap.String("issuer", p.Issuer),
+			zap.String("path_prefix", p.PathPrefix),
+			zap.Strings("hosts", p.Hosts),
+			zap.Bool("default", p == profileSet.Default()))
+	}
 
-	if err := http.ListenAndServe(addr, nil); err != nil {
-		logger.Fatal("Failed to start server", zap.Error(err))
+	// Ошибки сервера, в том числе ошибки TLS handshake, пишутся в общий лог
+	srv := &http.Server{
+		Addr:              cfg.Server.Addr,
+		Handler:           profileSet.Middleware(root),
+		ReadHeaderTimeout: time.Duration(cfg.Server.ReadHeaderTimeout),
+		ReadTimeout:       time.Duration(cfg.Server.ReadTimeout),
+		WriteTimeout:      time.Duration(cfg.Server.WriteTimeout),
+		IdleTimeout:       time.Duration(cfg.Server.IdleTimeout),
+		ErrorLog:          zap.NewStdLog(logger.Logger()),
+	}
+	if cfg.Server.TLS.Enabled {
+		srv.TLSConfig, err = serverTLS(cfg.Server.TLS)
+		if err != nil {
+			logger.Fatal("Failed to configure TLS", zap.Error(err))
+		}
+	}
+
+	serveErr := serve(srv, h, time.Duration(cfg.Server.ShutdownTimeout))
+	// Хранилище закрывается после того, как очистка завершит начатый проход
+	stopJanitor()
+	<-janitorDone
+
+	// bolt снимает блокировку файла, redis закрывает соединения
+	if err := backend.Close(); err != nil {
+		logger.Error("Failed to close storage", zap.Error(err))
+	}
+	if serveErr != nil {
+		logger.Fatal("Failed to start server", zap.Error(serveErr))
 	}
+	logger.Info("ESIA Mock Server stopped")
 }
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/vibe-gaming/esia-mock/internal/certs"
+	"github.com/vibe-gaming/esia-mock/internal/config"
+	"github.com/vibe-gaming/esia-mock/internal/handler"
+	"github.com/vibe-gaming/esia-mock/internal/logger"
+	"go.uber.org/zap"
+)
+
+// serve запускает сервер и ждет SIGINT или SIGTERM. При остановке /readyz сразу отвечает 503,
+// новые соединения не принимаются, а текущие запросы завершаются в пределах shutdownTimeout.
+// Повторный сигнал во время остановки завершает процесс сразу
+func serve(srv *http.Server, h *handler.Handler, shutdownTimeout time.Duration) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Готовность отмечается после открытия порта: до этого /readyz недоступен
+	ln, err := net.Listen("tcp", srv.Addr)
+	if err != nil {
+		return err
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		if srv.TLSConfig != nil {
+			serveErr <- srv.ServeTLS(ln, "", "")
+		} else {
+			serveErr <- srv.Serve(ln)
+		}
+	}()
+	h.SetReady(true)
+
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
+	}
+	stop()
+
+	h.SetReady(false)
+	logger.Info("Shutting down", zap.Duration("timeout", shutdownTimeout))
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		logger.Error("Requests did not finish in time, closing connections", zap.Error(err))
+		srv.Close()
+	}
+	return nil
+}
+
+// serverTLS настройки TLS с заданным сертификатом или с самоподписанным из tls.Dir
+func serverTLS(settings config.TLS) (*tls.Config, error) {
+	certFile, keyFile := settings.CertFile, settings.KeyFile
+	if certFile == "" {
+		files, err := certs.SelfSigned(settings.Dir, settings.Hosts, time.Now())
+		if err != nil {
+			return nil, err
+		}
+		logger.Info("Using self-signed certificate",
+			zap.String("ca", files.CA),
+			zap.Strings("hosts", settings.Hosts))
+		certFile, keyFile = files.Cert, files.Key
+	}
+	return certs.ServerConfig(certFile, keyFile, settings.ClientAuth)
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// defaultAdminAddr адрес работающего мока по умолчанию
+const defaultAdminAddr = "http://localhost:8085"
+
+// runSnapshotCommand выгружает или загружает снапшот работающего мока через admin API:
+//
+//	esia-mock snapshot export [-addr URL] [-o file]
+//	esia-mock snapshot import [-addr URL] [-mode merge|replace] file
+func runSnapshotCommand(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: esia-mock snapshot export|import [flags]")
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+
+	switch args[0] {
+	case "export":
+		fs := flag.NewFlagSet("snapshot export", flag.ContinueOnError)
+		addr := fs.String("addr", defaultAdminAddr, "адрес мока")
+		output := fs.String("o", "", "файл для снапшота (по умолчанию stdout)")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+
+		resp, err := client.Get(strings.TrimRight(*addr, "/") + "/admin/snapshot")
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("export failed: %s: %s", resp.Status, body)
+		}
+
+		if *output == "" {
+			_, err = os.Stdout.Write(body)
+			return err
+		}
+		return os.WriteFile(*output, body, 0o644)
+
+	case "import":
+		fs := flag.NewFlagSet("snapshot import", flag.ContinueOnError)
+		addr := fs.String("addr", defaultAdminAddr, "адрес мока")
+		mode := fs.String("mode", "merge", "режим импорта: merge или replace")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		if fs.NArg() != 1 {
+			return fmt.Errorf("usage: esia-mock snapshot import [-addr URL] [-mode merge|replace] file")
+		}
+
+		data, err := os.ReadFile(fs.Arg(0))
+		if err != nil {
+			return err
+		}
+
+		target := strings.TrimRight(*addr, "/") + "/admin/snapshot?mode=" + url.QueryEscape(*mode)
+		resp, err := client.Post(target, "application/json", bytes.NewReader(data))
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusNoContent {
+			body, _ := io.ReadAll(resp.Body)
+			return fmt.Errorf("import failed: %s: %s", resp.Status, body)
+		}
+		return nil
+	}
+
+	return fmt.Errorf("unknown snapshot command %q, expected export or import", args[0])
+}
@@ -0,0 +1,215 @@
+package certs
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Режимы проверки сертификата клиента (mTLS)
+const (
+	ClientAuthNone     = "none"     // сертификат не запрашивается
+	ClientAuthOptional = "optional" // запрашивается, но не обязателен
+	ClientAuthRequire  = "require"  // без сертификата отклоняется обмен кода на токен
+)
+
+// Имена файлов самоподписанных сертификатов в каталоге
+const (
+	CAFile       = "ca.pem"
+	CAKeyFile    = "ca-key.pem"
+	CertFile     = "cert.pem"
+	KeyFile      = "key.pem"
+	caValidity   = 10 * 365 * 24 * time.Hour
+	leafValidity = 397 * 24 * time.Hour // предел, который принимают браузеры
+	renewBefore  = 30 * 24 * time.Hour
+)
+
+// Files пути к сертификату и ключу сервера и к сертификату CA, которому нужно доверять
+type Files struct {
+	CA   string
+	Cert string
+	Key  string
+}
+
+// SelfSigned возвращает самоподписанные сертификаты из каталога dir, создавая недостающие.
+// CA создается один раз и переиспользуется: доверие к нему сохраняется между запусками.
+// Сертификат сервера выпускается заново, если он истекает или не покрывает hosts
+func SelfSigned(dir string, hosts []string, now time.Time) (Files, error) {
+	files := Files{
+		CA:   filepath.Join(dir, CAFile),
+		Cert: filepath.Join(dir, CertFile),
+		Key:  filepath.Join(dir, KeyFile),
+	}
+	caKeyPath := filepath.Join(dir, CAKeyFile)
+
+	if len(hosts) == 0 {
+		return files, errors.New("at least one host is required")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return files, err
+	}
+
+	ca, caKey, err := loadPair(files.CA, caKeyPath)
+	if err != nil {
+		ca, caKey, err = newCA(now)
+		if err != nil {
+			return files, err
+		}
+		if err := writePair(files.CA, caKeyPath, ca.Raw, caKey); err != nil {
+			return files, err
+		}
+		// Новый CA делает старый сертификат сервера недействительным
+		os.Remove(files.Cert)
+	}
+
+	if leaf, _, err := loadPair(files.Cert, files.Key); err == nil && leafValid(leaf, ca, hosts, now) {
+		return files, nil
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return files, err
+	}
+	template := &x509.Certificate{
+		SerialNumber: serialNumber(now),
+		Subject:      pkix.Name{Organization: []string{"esia-mock"}, CommonName: hosts[0]},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(leafValidity),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+	if err != nil {
+		return files, err
+	}
+	return files, writePair(files.Cert, files.Key, der, key)
+}
+
+// ServerConfig настройки TLS сервера с сертификатом из файлов и проверкой сертификата клиента.
+// Цепочка сертификата клиента не проверяется: клиента опознает реестр по самому сертификату.
+// С require сертификат, как и с optional, только запрашивается: его наличие проверяет обмен
+// кода на токен, а проверки состояния и остальные эндпоинты доступны без сертификата
+func ServerConfig(certFile, keyFile, clientAuth string) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	switch clientAuth {
+	case "", ClientAuthNone:
+		config.ClientAuth = tls.NoClientCert
+	case ClientAuthOptional, ClientAuthRequire:
+		config.ClientAuth = tls.RequestClientCert
+	default:
+		return nil, fmt.Errorf("unknown client auth mode %q", clientAuth)
+	}
+	return config, nil
+}
+
+// newCA создает корневой сертификат мока
+func newCA(now time.Time) (*x509.Certificate, *ecdsa.PrivateKey, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	template := &x509.Certificate{
+		SerialNumber:          serialNumber(now),
+		Subject:               pkix.Name{Organization: []string{"esia-mock"}, CommonName: "esia-mock CA"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(caValidity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	return cert, key, err
+}
+
+// leafValid проверяет, что сертификат сервера подписан CA, еще действует и покрывает hosts
+func leafValid(leaf, ca *x509.Certificate, hosts []string, now time.Time) bool {
+	if leaf.CheckSignatureFrom(ca) != nil || now.Add(renewBefore).After(leaf.NotAfter) {
+		return false
+	}
+	for _, host := range hosts {
+		if leaf.VerifyHostname(host) != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// loadPair читает сертификат и ECDSA-ключ в PEM
+func loadPair(certPath, keyPath string) (*x509.Certificate, *ecdsa.PrivateKey, error) {
+	certPEM, err := os.ReadFile(certPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyPEM, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	certBlock, _ := pem.Decode(certPEM)
+	keyBlock, _ := pem.Decode(keyPEM)
+	if certBlock == nil || keyBlock == nil {
+		return nil, nil, errors.New("invalid PEM")
+	}
+	cert, err := x509.ParseCertificate(certBlock.Bytes)
+	if err != nil {
+		return nil, nil, err
+	}
+	key, err := x509.ParseECPrivateKey(keyBlock.Bytes)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cert, key, nil
+}
+
+// writePair сохраняет сертификат и ключ в PEM; ключ доступен только владельцу
+func writePair(certPath, keyPath string, der []byte, key *ecdsa.PrivateKey) error {
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		return err
+	}
+	return os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o644)
+}
+
+// serialNumber случайный серийный номер; если rand недоступен — по времени выпуска
+func serialNumber(now time.Time) *big.Int {
+	limit := new(big.Int).Lsh(big.NewInt(1), 120)
+	n, err := rand.Int(rand.Reader, limit)
+	if err != nil {
+		return big.NewInt(now.UnixNano())
+	}
+	return n
+}
@@ -0,0 +1,156 @@
+package certs
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func readCert(t *testing.T, path string) *x509.Certificate {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		t.Fatalf("%s: no PEM block", path)
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+// verify проверяет сертификат сервера по CA для имени host
+func verify(t *testing.T, files Files, host string, now time.Time) error {
+	t.Helper()
+	roots := x509.NewCertPool()
+	roots.AddCert(readCert(t, files.CA))
+	_, err := readCert(t, files.Cert).Verify(x509.VerifyOptions{DNSName: host, Roots: roots, CurrentTime: now})
+	return err
+}
+
+func TestSelfSigned(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	files, err := SelfSigned(dir, []string{"localhost", "127.0.0.1"}, now)
+	if err != nil {
+		t.Fatalf("SelfSigned: %v", err)
+	}
+	for _, host := range []string{"localhost", "127.0.0.1"} {
+		if err := verify(t, files, host, now); err != nil {
+			t.Errorf("verify %s: %v", host, err)
+		}
+	}
+	if _, err := tls.LoadX509KeyPair(files.Cert, files.Key); err != nil {
+		t.Errorf("key pair: %v", err)
+	}
+	if info, err := os.Stat(files.Key); err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("key file mode = %v, %v", info.Mode(), err)
+	}
+
+	// Повторный запуск переиспользует сертификаты
+	caSerial := readCert(t, files.CA).SerialNumber
+	leafSerial := readCert(t, files.Cert).SerialNumber
+	if _, err := SelfSigned(dir, []string{"localhost"}, now.Add(24*time.Hour)); err != nil {
+		t.Fatalf("SelfSigned: %v", err)
+	}
+	if readCert(t, files.Cert).SerialNumber.Cmp(leafSerial) != 0 {
+		t.Error("valid certificate reissued")
+	}
+
+	// Новое имя и истечение срока выпускают сертификат сервера заново тем же CA
+	for _, at := range []time.Time{now, now.Add(leafValidity)} {
+		if _, err := SelfSigned(dir, []string{"localhost", "esia-mock"}, at); err != nil {
+			t.Fatalf("SelfSigned: %v", err)
+		}
+		if readCert(t, files.Cert).SerialNumber.Cmp(leafSerial) == 0 {
+			t.Errorf("certificate not reissued at %v", at)
+		}
+		leafSerial = readCert(t, files.Cert).SerialNumber
+		if err := verify(t, files, "esia-mock", at); err != nil {
+			t.Errorf("verify esia-mock: %v", err)
+		}
+	}
+	if readCert(t, files.CA).SerialNumber.Cmp(caSerial) != 0 {
+		t.Error("CA reissued")
+	}
+}
+
+func TestServerConfig(t *testing.T) {
+	files, err := SelfSigned(t.TempDir(), []string{"localhost"}, time.Now())
+	if err != nil {
+		t.Fatalf("SelfSigned: %v", err)
+	}
+
+	modes := map[string]tls.ClientAuthType{
+		ClientAuthNone:     tls.NoClientCert,
+		ClientAuthOptional: tls.RequestClientCert,
+		ClientAuthRequire:  tls.RequestClientCert,
+	}
+	for mode, want := range modes {
+		config, err := ServerConfig(files.Cert, files.Key, mode)
+		if err != nil {
+			t.Fatalf("ServerConfig(%s): %v", mode, err)
+		}
+		if config.ClientAuth != want {
+			t.Errorf("%s: ClientAuth = %v, want %v", mode, config.ClientAuth, want)
+		}
+	}
+
+	if _, err := ServerConfig(files.Cert, files.Key, "verify"); err == nil {
+		t.Error("unknown mode accepted")
+	}
+	if _, err := ServerConfig(files.CA, files.Key, ClientAuthNone); err == nil {
+		t.Error("mismatched key accepted")
+	}
+}
+
+func TestRequireAcceptsHandshakeWithoutCertificate(t *testing.T) {
+	files, err := SelfSigned(t.TempDir(), []string{"127.0.0.1"}, time.Now())
+	if err != nil {
+		t.Fatalf("SelfSigned: %v", err)
+	}
+	config, err := ServerConfig(files.Cert, files.Key, ClientAuthRequire)
+	if err != nil {
+		t.Fatalf("ServerConfig: %v", err)
+	}
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strconv.Itoa(len(r.TLS.PeerCertificates))))
+	}))
+	srv.TLS = config
+	srv.StartTLS()
+	defer srv.Close()
+
+	// Проверки состояния отвечают и без сертификата клиента; предъявленный сертификат
+	// доходит до обработчика
+	clientCert, err := tls.LoadX509KeyPair(files.Cert, files.Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for want, certificates := range map[string][]tls.Certificate{"0": nil, "1": {clientCert}} {
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+			InsecureSkipVerify: true,
+			Certificates:       certificates,
+		}}}
+		resp, err := client.Get(srv.URL + "/healthz")
+		if err != nil {
+			t.Fatalf("%s client certificates: %v", want, err)
+		}
+		body := make([]byte, 1)
+		resp.Body.Read(body)
+		resp.Body.Close()
+		if string(body) != want {
+			t.Errorf("peer certificates = %s, want %s", body, want)
+		}
+	}
+}
@@ -0,0 +1,246 @@
+package clients
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/vibe-gaming/esia-mock/internal/storage"
+)
+
+// Типы выдачи маркера, которые можно разрешить клиенту
+const (
+	GrantAuthorizationCode = "authorization_code"
+	GrantRefreshToken      = "refresh_token"
+	GrantClientCredentials = "client_credentials"
+)
+
+// GrantTypes известные типы выдачи маркера
+var GrantTypes = []string{GrantAuthorizationCode, GrantRefreshToken, GrantClientCredentials}
+
+// Client зарегистрированная информационная система (клиент ЕСИА). Пустой список
+// scopes или grantTypes не ограничивает клиента
+type Client struct {
+	ID   string `json:"id" yaml:"id"` // client_id, мнемоника ИС
+	Name string `json:"name,omitempty" yaml:"name,omitempty"`
+
+	// RedirectURIs разрешенные адреса возврата; адрес со звездочкой в конце — префикс
+	RedirectURIs []string `json:"redirectUris" yaml:"redirectUris"`
+	Scopes       []string `json:"scopes,omitempty" yaml:"scopes,omitempty"`
+	GrantTypes   []string `json:"grantTypes,omitempty" yaml:"grantTypes,omitempty"`
+
+	// Certificate сертификат ИС в PEM
+	Certificate string `json:"certificate,omitempty" yaml:"certificate,omitempty"`
+}
+
+// Validate проверяет адреса возврата, типы выдачи маркера и сертификат
+func (c *Client) Validate() error {
+	if c.ID == "" {
+		return errors.New("client id is required")
+	}
+	for _, uri := range c.RedirectURIs {
+		if u, err := url.Parse(strings.TrimSuffix(uri, "*")); err != nil || !u.IsAbs() {
+			return fmt.Errorf("invalid redirect uri %q, expected an absolute URL", uri)
+		}
+	}
+	for _, grant := range c.GrantTypes {
+		if !contains(GrantTypes, grant) {
+			return fmt.Errorf("unknown grant type %q, expected one of %s", grant, strings.Join(GrantTypes, ", "))
+		}
+	}
+	if c.Certificate != "" {
+		if _, err := c.ParseCertificate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ParseCertificate разбирает сертификат ИС
+func (c *Client) ParseCertificate() (*x509.Certificate, error) {
+	block, _ := pem.Decode([]byte(c.Certificate))
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, errors.New("certificate must be a PEM-encoded CERTIFICATE block")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid certificate: %w", err)
+	}
+	return cert, nil
+}
+
+// AllowsRedirect проверяет адрес возврата: точное совпадение или совпадение с шаблоном
+func (c *Client) AllowsRedirect(uri string) bool {
+	for _, allowed := range c.RedirectURIs {
+		if pattern, ok := strings.CutSuffix(allowed, "*"); ok {
+			if matchRedirectPattern(pattern, uri) {
+				return true
+			}
+		} else if uri == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// matchRedirectPattern сравнивает адрес с шаблоном без звездочки: схема и хост должны
+// совпадать точно, а путь после нормализации (.., //) — начинаться с пути шаблона
+// целыми сегментами. https://app.example.ru* не пропустит https://app.example.ru.evil.ru,
+// а https://app.example.ru/cb* — https://app.example.ru/cb/../admin
+func matchRedirectPattern(pattern, uri string) bool {
+	p, err := url.Parse(pattern)
+	if err != nil {
+		return false
+	}
+	u, err := url.Parse(uri)
+	if err != nil || !u.IsAbs() || u.User != nil || u.Fragment != "" {
+		return false
+	}
+	if u.Scheme != p.Scheme || !strings.EqualFold(u.Host, p.Host) {
+		return false
+	}
+
+	base := strings.TrimSuffix(p.Path, "/")
+	if base == "" {
+		return true
+	}
+	cleaned := path.Clean("/" + u.Path)
+	return cleaned == base || strings.HasPrefix(cleaned, base+"/")
+}
+
+// DisallowedScopes возвращает области доступа из scope (через пробел), не разрешенные клиенту
+func (c *Client) DisallowedScopes(scope string) []string {
+	if len(c.Scopes) == 0 {
+		return nil
+	}
+	var disallowed []string
+	for _, s := range strings.Fields(scope) {
+		if !contains(c.Scopes, s) {
+			disallowed = append(disallowed, s)
+		}
+	}
+	return disallowed
+}
+
+// AllowsGrant проверяет тип выдачи маркера
+func (c *Client) AllowsGrant(grantType string) bool {
+	return len(c.GrantTypes) == 0 || contains(c.GrantTypes, grantType)
+}
+
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Registry хранит зарегистрированных клиентов в storage.Backend
+type Registry struct {
+	backend storage.Backend
+}
+
+// NewRegistry создает реестр клиентов
+func NewRegistry(backend storage.Backend) *Registry {
+	return &Registry{backend: backend}
+}
+
+// Get возвращает клиента по client_id или storage.ErrNotFound
+func (r *Registry) Get(id string) (*Client, error) {
+	var client Client
+	if err := storage.GetJSON(r.backend, storage.BucketClients, id, &client); err != nil {
+		return nil, err
+	}
+	return &client, nil
+}
+
+// Lookup ищет клиента для проверки запроса. Пока реестр пуст, проверки выключены:
+// enforced равен false и принимается любой client_id
+func (r *Registry) Lookup(id string) (client *Client, enforced bool, err error) {
+	client, err = r.Get(id)
+	if err == nil {
+		return client, true, nil
+	}
+	if !storage.IsNotFound(err) {
+		return nil, true, err
+	}
+
+	entries, err := r.backend.List(storage.BucketClients)
+	if err != nil {
+		return nil, true, err
+	}
+	return nil, len(entries) > 0, nil
+}
+
+// FindByCertificate ищет клиента, зарегистрировавшего сертификат (DER); nil — такого нет
+func (r *Registry) FindByCertificate(raw []byte) (*Client, error) {
+	list, err := r.List()
+	if err != nil {
+		return nil, err
+	}
+	for _, client := range list {
+		if client.Certificate == "" {
+			continue
+		}
+		if cert, err := client.ParseCertificate(); err == nil && bytes.Equal(cert.Raw, raw) {
+			return client, nil
+		}
+	}
+	return nil, nil
+}
+
+// Save проверяет клиента, затем создает или заменяет его
+func (r *Registry) Save(client *Client) error {
+	if err := client.Validate(); err != nil {
+		return err
+	}
+	return storage.PutJSON(r.backend, storage.BucketClients, client.ID, client, 0)
+}
+
+// Delete удаляет клиента
+func (r *Registry) Delete(id string) error {
+	return r.backend.Delete(storage.BucketClients, id)
+}
+
+// List возвращает всех клиентов, упорядоченных по client_id
+func (r *Registry) List() ([]*Client, error) {
+	entries, err := r.backend.List(storage.BucketClients)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*Client, 0, len(entries))
+	for id, data := range entries {
+		var client Client
+		if err := json.Unmarshal(data, &client); err != nil {
+			return nil, fmt.Errorf("decode client %s: %w", id, err)
+		}
+		result = append(result, &client)
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+	return result, nil
+}
+
+// Import загружает клиентов; при replace существующие клиенты удаляются
+func (r *Registry) Import(clients []*Client, replace bool) error {
+	if replace {
+		if err := storage.Clear(r.backend, storage.BucketClients); err != nil {
+			return err
+		}
+	}
+	for _, client := range clients {
+		if err := r.Save(client); err != nil {
+			return err
+		}
+	}
+	return nil
+}
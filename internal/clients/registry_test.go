@@ -0,0 +1,147 @@
+package clients
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vibe-gaming/esia-mock/internal/storage"
+)
+
+// testCertificate самоподписанный сертификат ИС в PEM
+func testCertificate(t *testing.T) string {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "crm"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+}
+
+func TestPolicies(t *testing.T) {
+	c := &Client{
+		ID:           "crm",
+		RedirectURIs: []string{"https://crm.example.ru/callback", "https://stage.example.ru/*", "https://app.example.ru*", "https://lk.example.ru/cb*"},
+		Scopes:       []string{"openid", "fullname"},
+		GrantTypes:   []string{GrantAuthorizationCode},
+	}
+
+	redirects := map[string]bool{
+		"https://crm.example.ru/callback":         true,
+		"https://crm.example.ru/callback?x=1":     false,
+		"https://crm.example.ru/callback/other":   false,
+		"https://stage.example.ru/":               true,
+		"https://stage.example.ru/pr-42/callback": true,
+		"https://stage.example.ru.evil.com/":      false,
+		"http://stage.example.ru/callback":        false,
+		"https://app.example.ru":                  true,
+		"https://app.example.ru/any/path?x=1":     true,
+		"https://app.example.ru.evil.ru/cb":       false,
+		"https://app.example.ru@evil.ru/cb":       false,
+		"https://app.example.ru:8443/cb":          false,
+		"https://lk.example.ru/cb":                true,
+		"https://lk.example.ru/cb/":               true,
+		"https://lk.example.ru/cb/pr-42?state=1":  true,
+		"https://lk.example.ru/cbx":               false,
+		"https://lk.example.ru/cb/../admin":       false,
+		"https://lk.example.ru/cb/%2e%2e/admin":   false,
+		"https://lk.example.ru/cb/x/../../admin":  false,
+		"https://lk.example.ru/cb/x/../ok":        true,
+		"https://lk.example.ru/cb#frag":           false,
+		"lk.example.ru/cb":                        false,
+	}
+	for uri, want := range redirects {
+		if got := c.AllowsRedirect(uri); got != want {
+			t.Errorf("AllowsRedirect(%q) = %v, want %v", uri, got, want)
+		}
+	}
+
+	if got := c.DisallowedScopes("openid fullname"); len(got) != 0 {
+		t.Errorf("allowed scopes rejected: %v", got)
+	}
+	if got := c.DisallowedScopes("openid email  mobile"); strings.Join(got, " ") != "email mobile" {
+		t.Errorf("DisallowedScopes = %v, want [email mobile]", got)
+	}
+	if !c.AllowsGrant(GrantAuthorizationCode) || c.AllowsGrant(GrantClientCredentials) {
+		t.Error("grant types not enforced")
+	}
+
+	// Пустые списки не ограничивают клиента
+	open := &Client{ID: "open"}
+	if len(open.DisallowedScopes("openid email")) != 0 || !open.AllowsGrant(GrantRefreshToken) {
+		t.Error("client without lists is restricted")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	valid := &Client{ID: "crm", RedirectURIs: []string{"https://crm.example.ru/*"}, Certificate: testCertificate(t)}
+	if err := valid.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	if cert, err := valid.ParseCertificate(); err != nil || cert.Subject.CommonName != "crm" {
+		t.Errorf("ParseCertificate = %v, %v", cert, err)
+	}
+
+	for name, c := range map[string]*Client{
+		"empty id":      {},
+		"relative uri":  {ID: "a", RedirectURIs: []string{"/callback"}},
+		"unknown grant": {ID: "a", GrantTypes: []string{"password"}},
+		"certificate":   {ID: "a", Certificate: "not a certificate"},
+	} {
+		if err := c.Validate(); err == nil {
+			t.Errorf("%s: accepted", name)
+		}
+	}
+}
+
+func TestLookup(t *testing.T) {
+	r := NewRegistry(storage.NewMemoryBackend())
+
+	// Пустой реестр не ограничивает клиентов
+	if client, enforced, err := r.Lookup("any"); client != nil || enforced || err != nil {
+		t.Errorf("empty registry: %v, %v, %v", client, enforced, err)
+	}
+
+	if err := r.Save(&Client{ID: "crm", RedirectURIs: []string{"https://crm.example.ru/callback"}}); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if client, enforced, err := r.Lookup("crm"); client == nil || !enforced || err != nil {
+		t.Errorf("registered client: %v, %v, %v", client, enforced, err)
+	}
+	if client, enforced, err := r.Lookup("any"); client != nil || !enforced || err != nil {
+		t.Errorf("unknown client: %v, %v, %v", client, enforced, err)
+	}
+
+	certificate := testCertificate(t)
+	if err := r.Save(&Client{ID: "signed", Certificate: certificate}); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	block, _ := pem.Decode([]byte(certificate))
+	if client, err := r.FindByCertificate(block.Bytes); err != nil || client == nil || client.ID != "signed" {
+		t.Errorf("FindByCertificate = %v, %v", client, err)
+	}
+	if client, err := r.FindByCertificate([]byte("other")); err != nil || client != nil {
+		t.Errorf("unknown certificate: %v, %v", client, err)
+	}
+
+	if err := r.Save(&Client{ID: "bad", RedirectURIs: []string{"callback"}}); err == nil {
+		t.Error("invalid client saved")
+	}
+}
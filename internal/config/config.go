@@ -0,0 +1,314 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/vibe-gaming/esia-mock/internal/certs"
+	"github.com/vibe-gaming/esia-mock/internal/clients"
+	"github.com/vibe-gaming/esia-mock/internal/faults"
+	"github.com/vibe-gaming/esia-mock/internal/logger"
+	"github.com/vibe-gaming/esia-mock/internal/maintenance"
+	"github.com/vibe-gaming/esia-mock/internal/oauth"
+	"github.com/vibe-gaming/esia-mock/internal/profiles"
+	"github.com/vibe-gaming/esia-mock/internal/ratelimit"
+	"github.com/vibe-gaming/esia-mock/internal/storage"
+	"gopkg.in/yaml.v3"
+)
+
+// Duration длительность, которая в YAML, JSON и переменных окружения записывается строкой: 5m, 1h30m
+type Duration time.Duration
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	v, err := time.ParseDuration(string(text))
+	if err != nil {
+		return fmt.Errorf("invalid duration %q, expected a duration such as 90s or 1h", text)
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// Config настройки мока. Источники по возрастанию приоритета: значения по умолчанию,
+// YAML-файл, переменные окружения ESIA_MOCK_*, флаги командной строки
+type Config struct {
+	Server    Server    `json:"server" yaml:"server"`
+	Log       Log       `json:"log" yaml:"log"`
+	Generator Generator `json:"generator" yaml:"generator"`
+	Storage   Storage   `json:"storage" yaml:"storage"`
+	OAuth     OAuth     `json:"oauth" yaml:"oauth"`
+	Features  Features  `json:"features" yaml:"features"`
+
+	// Profile профиль среды ЕСИА для запросов без префикса профиля и известного Host
+	Profile string `json:"profile" yaml:"profile"`
+	// Profiles профили сред в дополнение к встроенным test и prod или вместо них
+	Profiles []profiles.Profile `json:"profiles,omitempty" yaml:"profiles,omitempty"`
+
+	// Fixtures файлы и каталоги фикстур
+	Fixtures []string `json:"fixtures,omitempty" yaml:"fixtures,omitempty"`
+
+	// Clients зарегистрированные клиенты; пока реестр пуст, принимается любой client_id
+	Clients []clients.Client `json:"clients,omitempty" yaml:"clients,omitempty"`
+
+	Faults      []faults.Rule        `json:"faults,omitempty" yaml:"faults,omitempty"`
+	RateLimits  []ratelimit.Rule     `json:"rateLimits,omitempty" yaml:"rateLimits,omitempty"`
+	Maintenance maintenance.Settings `json:"maintenance" yaml:"maintenance"`
+}
+
+// Server настройки HTTP-сервера. Нулевой таймаут чтения, записи или простоя — без ограничения
+type Server struct {
+	Addr              string   `json:"addr" yaml:"addr"`
+	ReadHeaderTimeout Duration `json:"readHeaderTimeout" yaml:"readHeaderTimeout"`
+	ReadTimeout       Duration `json:"readTimeout" yaml:"readTimeout"`
+	WriteTimeout      Duration `json:"writeTimeout" yaml:"writeTimeout"`
+	IdleTimeout       Duration `json:"idleTimeout" yaml:"idleTimeout"`
+	// ShutdownTimeout время на завершение текущих запросов после SIGTERM
+	ShutdownTimeout Duration `json:"shutdownTimeout" yaml:"shutdownTimeout"`
+	TLS             TLS      `json:"tls" yaml:"tls"`
+}
+
+// TLS настройки HTTPS. Без certFile и keyFile сертификат выпускается самоподписанным CA
+// в каталоге dir; ca.pem из этого каталога добавляется в доверенные у клиентов
+type TLS struct {
+	Enabled  bool     `json:"enabled" yaml:"enabled"`
+	CertFile string   `json:"certFile,omitempty" yaml:"certFile,omitempty"`
+	KeyFile  string   `json:"keyFile,omitempty" yaml:"keyFile,omitempty"`
+	Dir      string   `json:"dir" yaml:"dir"`
+	Hosts    []string `json:"hosts" yaml:"hosts"` // имена и IP самоподписанного сертификата
+	// ClientAuth проверка сертификата клиента: none, optional или require
+	ClientAuth string `json:"clientAuth" yaml:"clientAuth"`
+}
+
+// Log настройки логов
+type Log struct {
+	Level  string `json:"level" yaml:"level"`   // debug, info, warn, error
+	Format string `json:"format" yaml:"format"` // json или console
+}
+
+// Generator настройки генерации персон
+type Generator struct {
+	Seed    string `json:"seed" yaml:"seed"`
+	Version int    `json:"version" yaml:"version"` // 0 — версия по умолчанию (1)
+}
+
+// Storage настройки хранилища кодов, токенов и персон
+type Storage struct {
+	Backend         string   `json:"backend" yaml:"backend"`
+	Path            string   `json:"path" yaml:"path"` // файл для bolt или URL для redis; пусто — по умолчанию для backend
+	MaxUsers        int      `json:"maxUsers" yaml:"maxUsers"`
+	JanitorInterval Duration `json:"janitorInterval" yaml:"janitorInterval"`
+}
+
+// OAuth сроки жизни кодов и токенов
+type OAuth struct {
+	CodeTTL  Duration `json:"codeTtl" yaml:"codeTtl"`
+	TokenTTL Duration `json:"tokenTtl" yaml:"tokenTtl"`
+}
+
+// Features включение подсистем мока
+type Features struct {
+	AdminAPI    bool `json:"adminApi" yaml:"adminApi"`
+	Faults      bool `json:"faults" yaml:"faults"`
+	RateLimits  bool `json:"rateLimits" yaml:"rateLimits"`
+	Maintenance bool `json:"maintenance" yaml:"maintenance"`
+}
+
+// Default возвращает настройки по умолчанию
+func Default() *Config {
+	return &Config{
+		Server: Server{
+			Addr:              ":8085",
+			ReadHeaderTimeout: Duration(10 * time.Second),
+			ReadTimeout:       Duration(30 * time.Second),
+			WriteTimeout:      Duration(time.Minute),
+			IdleTimeout:       Duration(2 * time.Minute),
+			ShutdownTimeout:   Duration(15 * time.Second),
+			TLS: TLS{
+				Dir:        "tls",
+				Hosts:      []string{"localhost", "127.0.0.1", "::1"},
+				ClientAuth: certs.ClientAuthNone,
+			},
+		},
+		Log: Log{Level: "info", Format: logger.FormatJSON},
+		Storage: Storage{
+			Backend:         storage.BackendMemory,
+			JanitorInterval: Duration(oauth.DefaultJanitorInterval),
+		},
+		OAuth: OAuth{
+			CodeTTL:  Duration(oauth.DefaultCodeTTL),
+			TokenTTL: Duration(oauth.DefaultTokenTTL),
+		},
+		Features: Features{
+			AdminAPI:    true,
+			Faults:      true,
+			RateLimits:  true,
+			Maintenance: true,
+		},
+		Profile: profiles.Test,
+	}
+}
+
+// LoadFile дополняет настройки значениями из YAML-файла; неизвестные ключи — ошибка
+func (c *Config) LoadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// StoragePath возвращает путь хранилища с учетом значения по умолчанию для backend
+func (c *Config) StoragePath() string {
+	if c.Storage.Path != "" {
+		return c.Storage.Path
+	}
+	if c.Storage.Backend == storage.BackendRedis {
+		return "redis://localhost:6379/0"
+	}
+	return "esia-mock.db"
+}
+
+// Validate проверяет значения, которые нельзя проверить при разборе
+func (c *Config) Validate() error {
+	if c.Server.Addr == "" {
+		return errors.New("server.addr is required")
+	}
+	if err := c.Server.TLS.validate(); err != nil {
+		return err
+	}
+
+	switch c.Log.Level {
+	case "debug", "info", "warn", "error":
+	default:
+		return fmt.Errorf("invalid log.level %q, expected debug, info, warn or error", c.Log.Level)
+	}
+	switch c.Log.Format {
+	case logger.FormatJSON, logger.FormatConsole:
+	default:
+		return fmt.Errorf("invalid log.format %q, expected %s or %s", c.Log.Format, logger.FormatJSON, logger.FormatConsole)
+	}
+
+	switch c.Storage.Backend {
+	case storage.BackendMemory, storage.BackendBolt, storage.BackendRedis:
+	default:
+		return fmt.Errorf("invalid storage.backend %q, expected %s, %s or %s",
+			c.Storage.Backend, storage.BackendMemory, storage.BackendBolt, storage.BackendRedis)
+	}
+	if c.Storage.MaxUsers < 0 {
+		return fmt.Errorf("invalid storage.maxUsers %d", c.Storage.MaxUsers)
+	}
+
+	timeouts := []struct {
+		name  string
+		value Duration
+	}{
+		{"server.readHeaderTimeout", c.Server.ReadHeaderTimeout},
+		{"server.readTimeout", c.Server.ReadTimeout},
+		{"server.writeTimeout", c.Server.WriteTimeout},
+		{"server.idleTimeout", c.Server.IdleTimeout},
+	}
+	for _, d := range timeouts {
+		if d.value < 0 {
+			return fmt.Errorf("%s must not be negative", d.name)
+		}
+	}
+
+	durations := []struct {
+		name  string
+		value Duration
+	}{
+		{"server.shutdownTimeout", c.Server.ShutdownTimeout},
+		{"storage.janitorInterval", c.Storage.JanitorInterval},
+		{"oauth.codeTtl", c.OAuth.CodeTTL},
+		{"oauth.tokenTtl", c.OAuth.TokenTTL},
+	}
+	for _, d := range durations {
+		if d.value <= 0 {
+			return fmt.Errorf("%s must be positive", d.name)
+		}
+	}
+
+	ids := map[string]bool{}
+	for i, client := range c.Clients {
+		if err := client.Validate(); err != nil {
+			return fmt.Errorf("clients[%d]: %w", i, err)
+		}
+		if ids[client.ID] {
+			return fmt.Errorf("clients[%d]: client %s is already defined", i, client.ID)
+		}
+		ids[client.ID] = true
+	}
+
+	for i := range c.Profiles {
+		if err := c.Profiles[i].Validate(); err != nil {
+			return fmt.Errorf("profiles[%d]: %w", i, err)
+		}
+	}
+	if _, err := profiles.NewSet(c.Profiles, c.Profile); err != nil {
+		return fmt.Errorf("profiles: %w", err)
+	}
+	return nil
+}
+
+func (t *TLS) validate() error {
+	switch t.ClientAuth {
+	case certs.ClientAuthNone, certs.ClientAuthOptional, certs.ClientAuthRequire:
+	default:
+		return fmt.Errorf("invalid server.tls.clientAuth %q, expected %s, %s or %s",
+			t.ClientAuth, certs.ClientAuthNone, certs.ClientAuthOptional, certs.ClientAuthRequire)
+	}
+	if !t.Enabled {
+		if t.ClientAuth != certs.ClientAuthNone {
+			return errors.New("server.tls.clientAuth requires server.tls.enabled")
+		}
+		return nil
+	}
+	if (t.CertFile == "") != (t.KeyFile == "") {
+		return errors.New("server.tls.certFile and server.tls.keyFile must be set together")
+	}
+	if t.CertFile == "" && (t.Dir == "" || len(t.Hosts) == 0) {
+		return errors.New("server.tls.dir and server.tls.hosts are required for a self-signed certificate")
+	}
+	return nil
+}
+
+// masked заменяет секрет в выводе настроек
+const masked = "*****"
+
+// Masked возвращает копию настроек для вывода: пароли в URL хранилища скрыты
+func (c *Config) Masked() *Config {
+	copied := *c
+	copied.Storage.Path = maskURL(c.Storage.Path)
+	return &copied
+}
+
+// maskURL скрывает пароль в URL; строки, не похожие на URL с паролем, не меняются
+func maskURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.User == nil {
+		return raw
+	}
+	password, hasPassword := u.User.Password()
+	if !hasPassword || password == "" {
+		return raw
+	}
+	// Пароль подставляется после сборки URL: иначе звездочки будут экранированы
+	const placeholder = "MASKEDPASSWORD"
+	u.User = url.UserPassword(u.User.Username(), placeholder)
+	return strings.Replace(u.String(), placeholder, masked, 1)
+}
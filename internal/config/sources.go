@@ -0,0 +1,205 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EnvConfig переменная окружения с путем к YAML-файлу настроек
+const EnvConfig = "ESIA_MOCK_CONFIG"
+
+// setting настройка, которую можно задать переменной окружения и флагом
+type setting struct {
+	env   string
+	flag  string
+	usage string
+	set   func(c *Config, value string) error
+}
+
+// settings настройки в порядке вывода справки
+var settings = []setting{
+	{"ESIA_MOCK_ADDR", "addr", "адрес HTTP-сервера, например :8085", setString(func(c *Config) *string { return &c.Server.Addr })},
+	{"ESIA_MOCK_READ_HEADER_TIMEOUT", "read-header-timeout", "таймаут чтения заголовков запроса", setDuration(func(c *Config) *Duration { return &c.Server.ReadHeaderTimeout })},
+	{"ESIA_MOCK_READ_TIMEOUT", "read-timeout", "таймаут чтения запроса; 0 — без ограничения", setDuration(func(c *Config) *Duration { return &c.Server.ReadTimeout })},
+	{"ESIA_MOCK_WRITE_TIMEOUT", "write-timeout", "таймаут записи ответа; 0 — без ограничения", setDuration(func(c *Config) *Duration { return &c.Server.WriteTimeout })},
+	{"ESIA_MOCK_IDLE_TIMEOUT", "idle-timeout", "таймаут простоя keep-alive соединения", setDuration(func(c *Config) *Duration { return &c.Server.IdleTimeout })},
+	{"ESIA_MOCK_SHUTDOWN_TIMEOUT", "shutdown-timeout", "время на завершение запросов при остановке", setDuration(func(c *Config) *Duration { return &c.Server.ShutdownTimeout })},
+	{"ESIA_MOCK_TLS", "tls", "включить HTTPS", setBool(func(c *Config) *bool { return &c.Server.TLS.Enabled })},
+	{"ESIA_MOCK_TLS_CERT", "tls-cert", "сертификат сервера в PEM; пусто — самоподписанный", setString(func(c *Config) *string { return &c.Server.TLS.CertFile })},
+	{"ESIA_MOCK_TLS_KEY", "tls-key", "ключ сертификата сервера в PEM", setString(func(c *Config) *string { return &c.Server.TLS.KeyFile })},
+	{"ESIA_MOCK_TLS_DIR", "tls-dir", "каталог самоподписанных сертификатов", setString(func(c *Config) *string { return &c.Server.TLS.Dir })},
+	{"ESIA_MOCK_TLS_HOSTS", "tls-hosts", "имена и IP самоподписанного сертификата через запятую", setList(func(c *Config) *[]string { return &c.Server.TLS.Hosts })},
+	{"ESIA_MOCK_TLS_CLIENT_AUTH", "tls-client-auth", "сертификат клиента (mTLS): none, optional или require", setString(func(c *Config) *string { return &c.Server.TLS.ClientAuth })},
+	{"ESIA_MOCK_LOG_LEVEL", "log-level", "уровень логов: debug, info, warn, error", setString(func(c *Config) *string { return &c.Log.Level })},
+	{"ESIA_MOCK_LOG_FORMAT", "log-format", "формат логов: json или console", setString(func(c *Config) *string { return &c.Log.Format })},
+	{"ESIA_MOCK_SEED", "seed", "seed генерации персон", setString(func(c *Config) *string { return &c.Generator.Seed })},
+	{"ESIA_MOCK_GENERATOR_VERSION", "generator-version", "версия алгоритма генерации; 0 — версия по умолчанию (1)", setInt(func(c *Config) *int { return &c.Generator.Version })},
+	{"ESIA_MOCK_STORAGE", "storage", "хранилище: memory, bolt или redis", setString(func(c *Config) *string { return &c.Storage.Backend })},
+	{"ESIA_MOCK_STORAGE_PATH", "storage-path", "файл БД для bolt или URL для redis", setString(func(c *Config) *string { return &c.Storage.Path })},
+	{"ESIA_MOCK_MAX_USERS", "max-users", "максимум хранимых персон; 0 — без ограничения", setInt(func(c *Config) *int { return &c.Storage.MaxUsers })},
+	{"ESIA_MOCK_JANITOR_INTERVAL", "janitor-interval", "период удаления истекших кодов и токенов", setDuration(func(c *Config) *Duration { return &c.Storage.JanitorInterval })},
+	{"ESIA_MOCK_CODE_TTL", "code-ttl", "срок жизни кода авторизации", setDuration(func(c *Config) *Duration { return &c.OAuth.CodeTTL })},
+	{"ESIA_MOCK_TOKEN_TTL", "token-ttl", "срок жизни токена доступа", setDuration(func(c *Config) *Duration { return &c.OAuth.TokenTTL })},
+	{"ESIA_MOCK_PROFILE", "profile", "профиль среды ЕСИА по умолчанию: test, prod или из секции profiles", setString(func(c *Config) *string { return &c.Profile })},
+	{"ESIA_MOCK_PROFILES", "profiles", "файл с профилями сред (секция profiles)", setSection("profiles")},
+	{"ESIA_MOCK_FIXTURES", "fixtures", "файлы и каталоги фикстур через запятую", setList(func(c *Config) *[]string { return &c.Fixtures })},
+	{"ESIA_MOCK_CLIENTS", "clients", "файл с реестром клиентов (секция clients)", setSection("clients")},
+	{"ESIA_MOCK_FAULTS", "faults", "файл с правилами сбоев (секция faults)", setSection("faults")},
+	{"ESIA_MOCK_RATE_LIMITS", "rate-limits", "файл с лимитами запросов (секция rateLimits)", setSection("rateLimits")},
+	{"ESIA_MOCK_MAINTENANCE", "maintenance", "файл с настройками технических работ (секция maintenance)", setSection("maintenance")},
+	{"ESIA_MOCK_FEATURE_ADMIN_API", "feature-admin-api", "включить admin API", setBool(func(c *Config) *bool { return &c.Features.AdminAPI })},
+	{"ESIA_MOCK_FEATURE_FAULTS", "feature-faults", "включить внедрение сбоев", setBool(func(c *Config) *bool { return &c.Features.Faults })},
+	{"ESIA_MOCK_FEATURE_RATE_LIMITS", "feature-rate-limits", "включить лимиты запросов", setBool(func(c *Config) *bool { return &c.Features.RateLimits })},
+	{"ESIA_MOCK_FEATURE_MAINTENANCE", "feature-maintenance", "включить режим технических работ", setBool(func(c *Config) *bool { return &c.Features.Maintenance })},
+}
+
+// Options результат разбора командной строки, кроме самих настроек
+type Options struct {
+	ConfigFile  string // путь к YAML-файлу настроек
+	PrintConfig bool   // вывести итоговые настройки и завершиться
+}
+
+// Load собирает настройки: значения по умолчанию, затем YAML-файл (-config или ESIA_MOCK_CONFIG),
+// затем переменные окружения и флаги. lookupEnv обычно os.LookupEnv
+func Load(args []string, lookupEnv func(string) (string, bool), output io.Writer) (*Config, Options, error) {
+	var opts Options
+
+	fs := flag.NewFlagSet("esia-mock", flag.ContinueOnError)
+	fs.SetOutput(output)
+	fs.StringVar(&opts.ConfigFile, "config", "", "YAML-файл настроек (или "+EnvConfig+")")
+	fs.BoolVar(&opts.PrintConfig, "print-config", false, "вывести итоговые настройки в YAML и завершиться")
+
+	// Флаги применяются после файла и окружения, поэтому сначала только запоминаются
+	flagValues := map[string]string{}
+	for _, s := range settings {
+		name := s.flag
+		fs.Func(name, s.usage+" ("+s.env+")", func(value string) error {
+			flagValues[name] = value
+			return nil
+		})
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, opts, err
+	}
+	if fs.NArg() > 0 {
+		return nil, opts, fmt.Errorf("unexpected argument %q", fs.Arg(0))
+	}
+
+	if opts.ConfigFile == "" {
+		opts.ConfigFile, _ = lookupEnv(EnvConfig)
+	}
+
+	cfg := Default()
+	if opts.ConfigFile != "" {
+		if err := cfg.LoadFile(opts.ConfigFile); err != nil {
+			return nil, opts, err
+		}
+	}
+
+	for _, s := range settings {
+		if value, ok := lookupEnv(s.env); ok && value != "" {
+			if err := s.set(cfg, value); err != nil {
+				return nil, opts, fmt.Errorf("%s: %w", s.env, err)
+			}
+		}
+	}
+	for _, s := range settings {
+		if value, ok := flagValues[s.flag]; ok {
+			if err := s.set(cfg, value); err != nil {
+				return nil, opts, fmt.Errorf("-%s: %w", s.flag, err)
+			}
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, opts, err
+	}
+	return cfg, opts, nil
+}
+
+func setString(field func(*Config) *string) func(*Config, string) error {
+	return func(c *Config, value string) error {
+		*field(c) = value
+		return nil
+	}
+}
+
+func setInt(field func(*Config) *int) func(*Config, string) error {
+	return func(c *Config, value string) error {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", value)
+		}
+		*field(c) = n
+		return nil
+	}
+}
+
+func setBool(field func(*Config) *bool) func(*Config, string) error {
+	return func(c *Config, value string) error {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q, expected true or false", value)
+		}
+		*field(c) = b
+		return nil
+	}
+}
+
+func setDuration(field func(*Config) *Duration) func(*Config, string) error {
+	return func(c *Config, value string) error {
+		return field(c).UnmarshalText([]byte(value))
+	}
+}
+
+func setList(field func(*Config) *[]string) func(*Config, string) error {
+	return func(c *Config, value string) error {
+		var list []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		*field(c) = list
+		return nil
+	}
+}
+
+// setSection заменяет секцию настроек одноименной секцией из отдельного файла
+func setSection(section string) func(*Config, string) error {
+	return func(c *Config, path string) error {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		var file Config
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(&file); err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+
+		switch section {
+		case "profiles":
+			c.Profiles = file.Profiles
+		case "clients":
+			c.Clients = file.Clients
+		case "faults":
+			c.Faults = file.Faults
+		case "rateLimits":
+			c.RateLimits = file.RateLimits
+		case "maintenance":
+			c.Maintenance = file.Maintenance
+		}
+		return nil
+	}
+}
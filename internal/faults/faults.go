@@ -0,0 +1,321 @@
+package faults
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vibe-gaming/esia-mock/internal/logger"
+	"github.com/vibe-gaming/esia-mock/internal/rules"
+	"github.com/vibe-gaming/esia-mock/internal/stats"
+	"github.com/vibe-gaming/esia-mock/internal/storage"
+	"go.uber.org/zap"
+)
+
+// Искажения тела ответа
+const (
+	BodyTruncated = "truncated" // ответ обрезается на середине
+	BodyMalformed = "malformed" // ответ перестает быть корректным JSON
+)
+
+var injectedFaults = stats.New("injected_faults")
+
+// Rule правило внедрения сбоя. Условия (endpoint, method, clientId, phone, header)
+// объединяются по И; пустое условие выполняется всегда. Действия применяются вместе:
+// задержка, затем обрыв соединения, ошибка или искажение ответа
+type Rule struct {
+	ID string `json:"id" yaml:"id,omitempty"`
+
+	// Условия
+	Endpoint string            `json:"endpoint,omitempty" yaml:"endpoint,omitempty"` // путь; "*" в конце — префикс
+	Method   string            `json:"method,omitempty" yaml:"method,omitempty"`
+	ClientID string            `json:"clientId,omitempty" yaml:"clientId,omitempty"`
+	Phone    string            `json:"phone,omitempty" yaml:"phone,omitempty"`
+	Header   map[string]string `json:"header,omitempty" yaml:"header,omitempty"` // пустое значение — заголовок просто присутствует
+	Nth      int               `json:"nth,omitempty" yaml:"nth,omitempty"`       // сработать только на N-м подходящем запросе
+
+	// Действия
+	Status           int    `json:"status,omitempty" yaml:"status,omitempty"`
+	Error            string `json:"error,omitempty" yaml:"error,omitempty"` // код ошибки ЕСИА/OAuth2, например invalid_grant
+	ErrorDescription string `json:"errorDescription,omitempty" yaml:"errorDescription,omitempty"`
+	Latency          string `json:"latency,omitempty" yaml:"latency,omitempty"` // например 1500ms
+	Body             string `json:"body,omitempty" yaml:"body,omitempty"`       // truncated или malformed
+	Drop             bool   `json:"drop,omitempty" yaml:"drop,omitempty"`       // закрыть соединение без ответа
+
+	// Matched число подходящих запросов, Fired — число срабатываний
+	Matched int `json:"matched" yaml:"-"`
+	Fired   int `json:"fired" yaml:"-"`
+
+	latency time.Duration
+}
+
+// Validate проверяет правило и разбирает задержку
+func (r *Rule) Validate() error {
+	if r.Latency != "" {
+		latency, err := time.ParseDuration(r.Latency)
+		if err != nil || latency < 0 {
+			return fmt.Errorf("invalid latency %q, expected a duration such as 500ms", r.Latency)
+		}
+		r.latency = latency
+	}
+	if r.Status != 0 && (r.Status < 100 || r.Status > 599) {
+		return fmt.Errorf("invalid status %d", r.Status)
+	}
+	switch r.Body {
+	case "", BodyTruncated, BodyMalformed:
+	default:
+		return fmt.Errorf("invalid body %q, expected %s or %s", r.Body, BodyTruncated, BodyMalformed)
+	}
+	if r.Nth < 0 {
+		return fmt.Errorf("invalid nth %d", r.Nth)
+	}
+	if r.Status == 0 && r.Error == "" && r.latency == 0 && r.Body == "" && !r.Drop {
+		return fmt.Errorf("rule has no action: set status, error, latency, body or drop")
+	}
+	r.Method = strings.ToUpper(r.Method)
+	if r.Phone != "" {
+		r.Phone = storage.NormalizePhone(r.Phone)
+	}
+	return nil
+}
+
+// Identity клиент и телефон, к которым относится запрос
+type Identity = rules.Identity
+
+// Engine хранит правила и применяет их к запросам
+type Engine struct {
+	mu    sync.Mutex
+	rules *rules.List[Rule]
+}
+
+// NewEngine создает движок без правил
+func NewEngine() *Engine {
+	return &Engine{rules: rules.NewList(func(r *Rule) *string { return &r.ID })}
+}
+
+// Add добавляет правило; без ID правило получает свободный порядковый номер
+func (e *Engine) Add(rule Rule) (Rule, error) {
+	if err := rule.Validate(); err != nil {
+		return Rule{}, err
+	}
+	rule.Matched, rule.Fired = 0, 0
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if err := e.rules.Add(&rule); err != nil {
+		return Rule{}, err
+	}
+	return rule, nil
+}
+
+// Remove удаляет правило по ID
+func (e *Engine) Remove(id string) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.rules.Remove(id)
+}
+
+// Clear удаляет все правила
+func (e *Engine) Clear() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.rules.Clear()
+}
+
+// ResetCounters обнуляет счетчики подходящих запросов и срабатываний; правила остаются.
+// Правило с nth снова сработает на N-м запросе после сброса
+func (e *Engine) ResetCounters() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, rule := range e.rules.Items() {
+		rule.Matched, rule.Fired = 0, 0
+	}
+}
+
+// List возвращает копии правил в порядке добавления
+func (e *Engine) List() []Rule {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.rules.Copy()
+}
+
+// Middleware применяет правила перед обработчиком next. identity вызывается,
+// только если правилу нужны client_id или телефон
+func (e *Engine) Middleware(next http.Handler, identity Identity) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if rules.IsAdmin(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		rule, ok := e.match(r, identity)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		injectedFaults.Inc()
+		logger.Info("Injecting fault",
+			zap.String("rule", rule.ID),
+			zap.String("path", r.URL.Path))
+
+		rule.apply(w, r, next)
+	})
+}
+
+// match возвращает первое сработавшее правило. Клиент и телефон определяются вне блокировки:
+// identity может читать тело запроса и обращаться к хранилищу
+func (e *Engine) match(r *http.Request, identity Identity) (Rule, bool) {
+	var clientID, phone string
+	if identity != nil && e.needsIdentity(r) {
+		clientID, phone = identity(r)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for _, rule := range e.rules.Items() {
+		if !rule.matchesRequest(r) {
+			continue
+		}
+		if rule.ClientID != "" && rule.ClientID != clientID {
+			continue
+		}
+		if rule.Phone != "" && rule.Phone != phone {
+			continue
+		}
+
+		rule.Matched++
+		if rule.Nth > 0 && rule.Matched != rule.Nth {
+			continue
+		}
+		rule.Fired++
+		return *rule, true
+	}
+	return Rule{}, false
+}
+
+// needsIdentity проверяет, есть ли для запроса правила с условием на клиента или телефон
+func (e *Engine) needsIdentity(r *http.Request) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for _, rule := range e.rules.Items() {
+		if (rule.ClientID != "" || rule.Phone != "") && rule.matchesRequest(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesRequest проверяет условия, не требующие разбора тела запроса
+func (r *Rule) matchesRequest(req *http.Request) bool {
+	if !rules.MatchEndpoint(r.Endpoint, req.URL.Path) {
+		return false
+	}
+	if r.Method != "" && req.Method != r.Method {
+		return false
+	}
+	for name, value := range r.Header {
+		got, present := req.Header[http.CanonicalHeaderKey(name)]
+		if !present || (value != "" && (len(got) == 0 || got[0] != value)) {
+			return false
+		}
+	}
+	return true
+}
+
+// apply выполняет действия правила
+func (r Rule) apply(w http.ResponseWriter, req *http.Request, next http.Handler) {
+	if r.latency > 0 {
+		select {
+		case <-time.After(r.latency):
+		case <-req.Context().Done():
+			return
+		}
+	}
+
+	if r.Drop {
+		// Сервер закрывает соединение, не отправляя ответ
+		panic(http.ErrAbortHandler)
+	}
+
+	if r.Error != "" || r.Status != 0 {
+		status := r.Status
+		if status == 0 {
+			status = http.StatusBadRequest
+		}
+		body := []byte(http.StatusText(status))
+		contentType := "text/plain; charset=utf-8"
+		if r.Error != "" {
+			body, _ = json.Marshal(map[string]string{
+				"error":             r.Error,
+				"error_description": r.ErrorDescription,
+			})
+			contentType = "application/json"
+		}
+		if r.Body != "" {
+			body = corrupt(body, r.Body)
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.WriteHeader(status)
+		w.Write(body)
+		return
+	}
+
+	if r.Body == "" {
+		next.ServeHTTP(w, req)
+		return
+	}
+
+	// Искажаем настоящий ответ обработчика
+	buf := &bufferedResponse{header: http.Header{}, status: http.StatusOK}
+	next.ServeHTTP(buf, req)
+	for key, values := range buf.header {
+		w.Header()[key] = values
+	}
+	w.Header().Del("Content-Length")
+	w.WriteHeader(buf.status)
+	w.Write(corrupt(buf.body.Bytes(), r.Body))
+}
+
+// bufferedResponse накапливает ответ обработчика, чтобы его можно было исказить
+type bufferedResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (b *bufferedResponse) Header() http.Header {
+	return b.header
+}
+
+func (b *bufferedResponse) WriteHeader(status int) {
+	b.status = status
+}
+
+func (b *bufferedResponse) Write(p []byte) (int, error) {
+	return b.body.Write(p)
+}
+
+// corrupt искажает тело ответа
+func corrupt(body []byte, mode string) []byte {
+	switch mode {
+	case BodyTruncated:
+		return body[:len(body)/2]
+	case BodyMalformed:
+		// Замена первого ':' ломает JSON для любого парсера
+		if i := bytes.IndexByte(body, ':'); i >= 0 {
+			malformed := append([]byte(nil), body...)
+			malformed[i] = '='
+			return malformed
+		}
+		return append([]byte("{"), body...)
+	}
+	return body
+}
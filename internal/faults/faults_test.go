@@ -0,0 +1,245 @@
+package faults
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vibe-gaming/esia-mock/internal/logger"
+)
+
+func TestMain(m *testing.M) {
+	logger.Init("error")
+	os.Exit(m.Run())
+}
+
+// serve пропускает запрос через middleware над обработчиком, отвечающим {"ok":true}
+func serve(t *testing.T, e *Engine, req *http.Request, identity Identity) *httptest.ResponseRecorder {
+	t.Helper()
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"ok":true,"value":"abcdef"}`)
+	})
+	rec := httptest.NewRecorder()
+	e.Middleware(next, identity).ServeHTTP(rec, req)
+	return rec
+}
+
+func mustAdd(t *testing.T, e *Engine, rule Rule) Rule {
+	t.Helper()
+	added, err := e.Add(rule)
+	if err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	return added
+}
+
+func TestErrorRuleMatching(t *testing.T) {
+	e := NewEngine()
+	mustAdd(t, e, Rule{Endpoint: "/aas/oauth2/te", Method: "post", Error: "invalid_grant", ErrorDescription: "code expired"})
+
+	rec := serve(t, e, httptest.NewRequest(http.MethodPost, "/aas/oauth2/te", nil), nil)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400", rec.Code)
+	}
+	var body map[string]string
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("error body is not JSON: %v", err)
+	}
+	if body["error"] != "invalid_grant" || body["error_description"] != "code expired" {
+		t.Errorf("body = %v", body)
+	}
+
+	// Другой метод и другой путь проходят без изменений
+	for _, req := range []*http.Request{
+		httptest.NewRequest(http.MethodGet, "/aas/oauth2/te", nil),
+		httptest.NewRequest(http.MethodPost, "/userinfo", nil),
+	} {
+		if rec := serve(t, e, req, nil); rec.Code != http.StatusOK {
+			t.Errorf("%s %s: status = %d, want 200", req.Method, req.URL.Path, rec.Code)
+		}
+	}
+}
+
+func TestRuleConditions(t *testing.T) {
+	e := NewEngine()
+	mustAdd(t, e, Rule{Endpoint: "/rs/prns/*", ClientID: "crm", Phone: "+7 (916) 123-45-67", Status: http.StatusServiceUnavailable})
+	mustAdd(t, e, Rule{Header: map[string]string{"X-Fault": "yes"}, Status: http.StatusTeapot})
+
+	identity := func(r *http.Request) (string, string) {
+		return r.URL.Query().Get("client"), "79161234567"
+	}
+
+	tests := []struct {
+		name   string
+		url    string
+		header string
+		want   int
+	}{
+		{"prefix and identity", "/rs/prns/1000/docs?client=crm", "", http.StatusServiceUnavailable},
+		{"other client", "/rs/prns/1000?client=lk", "", http.StatusOK},
+		{"other path", "/userinfo?client=crm", "", http.StatusOK},
+		{"header", "/userinfo", "yes", http.StatusTeapot},
+		{"header value differs", "/userinfo", "no", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			if tt.header != "" {
+				req.Header.Set("X-Fault", tt.header)
+			}
+			if rec := serve(t, e, req, identity); rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestIdentityResolvedOutsideLock(t *testing.T) {
+	e := NewEngine()
+	mustAdd(t, e, Rule{Endpoint: "/userinfo", ClientID: "crm", Status: http.StatusServiceUnavailable})
+
+	// identity может обращаться к движку: вызов под блокировкой привел бы к взаимоблокировке
+	calls := 0
+	identity := func(r *http.Request) (string, string) {
+		calls++
+		return "crm", strconv.Itoa(len(e.List()))
+	}
+
+	done := make(chan int)
+	go func() {
+		done <- serve(t, e, httptest.NewRequest(http.MethodGet, "/userinfo", nil), identity).Code
+	}()
+	select {
+	case status := <-done:
+		if status != http.StatusServiceUnavailable {
+			t.Errorf("status = %d, want 503", status)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("identity is called under the engine lock")
+	}
+
+	// Без правил на клиента или телефон тело запроса не разбирается
+	serve(t, e, httptest.NewRequest(http.MethodGet, "/rs/prns/1", nil), identity)
+	if calls != 1 {
+		t.Errorf("identity called %d times, want 1", calls)
+	}
+}
+
+func TestNthRequest(t *testing.T) {
+	e := NewEngine()
+	rule := mustAdd(t, e, Rule{Endpoint: "/userinfo", Nth: 3, Status: http.StatusInternalServerError})
+
+	var statuses []int
+	for i := 0; i < 4; i++ {
+		rec := serve(t, e, httptest.NewRequest(http.MethodGet, "/userinfo", nil), nil)
+		statuses = append(statuses, rec.Code)
+	}
+	want := []int{200, 200, 500, 200}
+	for i := range want {
+		if statuses[i] != want[i] {
+			t.Fatalf("statuses = %v, want %v", statuses, want)
+		}
+	}
+
+	listed := e.List()
+	if len(listed) != 1 || listed[0].ID != rule.ID || listed[0].Matched != 4 || listed[0].Fired != 1 {
+		t.Errorf("counters = %+v, want matched 4, fired 1", listed)
+	}
+
+	// После сброса счетчиков правило снова срабатывает на третьем запросе
+	e.ResetCounters()
+	if listed := e.List(); listed[0].Matched != 0 || listed[0].Fired != 0 {
+		t.Errorf("counters after reset = %+v", listed[0])
+	}
+	for i := 1; i <= 3; i++ {
+		rec := serve(t, e, httptest.NewRequest(http.MethodGet, "/userinfo", nil), nil)
+		want := http.StatusOK
+		if i == 3 {
+			want = http.StatusInternalServerError
+		}
+		if rec.Code != want {
+			t.Errorf("request %d after reset: status %d, want %d", i, rec.Code, want)
+		}
+	}
+}
+
+func TestLatency(t *testing.T) {
+	e := NewEngine()
+	mustAdd(t, e, Rule{Latency: "50ms"})
+
+	start := time.Now()
+	rec := serve(t, e, httptest.NewRequest(http.MethodGet, "/userinfo", nil), nil)
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("elapsed = %v, want at least 50ms", elapsed)
+	}
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"ok":true`) {
+		t.Errorf("latency-only rule changed the response: %d %s", rec.Code, rec.Body)
+	}
+}
+
+func TestCorruptedBody(t *testing.T) {
+	for _, mode := range []string{BodyTruncated, BodyMalformed} {
+		t.Run(mode, func(t *testing.T) {
+			e := NewEngine()
+			mustAdd(t, e, Rule{Body: mode})
+
+			rec := serve(t, e, httptest.NewRequest(http.MethodGet, "/userinfo", nil), nil)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, want 200", rec.Code)
+			}
+			var v interface{}
+			if err := json.Unmarshal(rec.Body.Bytes(), &v); err == nil {
+				t.Errorf("body %q is still valid JSON", rec.Body)
+			}
+		})
+	}
+}
+
+func TestAdminPathsSkipped(t *testing.T) {
+	e := NewEngine()
+	mustAdd(t, e, Rule{Status: http.StatusInternalServerError})
+
+	if rec := serve(t, e, httptest.NewRequest(http.MethodGet, "/admin/faults", nil), nil); rec.Code != http.StatusOK {
+		t.Errorf("admin status = %d, want 200", rec.Code)
+	}
+}
+
+func TestEngineManagement(t *testing.T) {
+	e := NewEngine()
+	if _, err := e.Add(Rule{Endpoint: "/userinfo"}); err == nil {
+		t.Error("rule without action accepted")
+	}
+	if _, err := e.Add(Rule{Latency: "soon"}); err == nil {
+		t.Error("invalid latency accepted")
+	}
+	if _, err := e.Add(Rule{Body: "empty"}); err == nil {
+		t.Error("invalid body mode accepted")
+	}
+
+	mustAdd(t, e, Rule{ID: "slow", Latency: "1s"})
+	if _, err := e.Add(Rule{ID: "slow", Drop: true}); err == nil {
+		t.Error("duplicate id accepted")
+	}
+	mustAdd(t, e, Rule{ID: "2", Drop: true})
+	if generated := mustAdd(t, e, Rule{Drop: true}); generated.ID != "1" {
+		t.Errorf("generated id %q, want 1", generated.ID)
+	}
+	if generated := mustAdd(t, e, Rule{Drop: true}); generated.ID != "3" {
+		t.Errorf("generated id %q, want 3 (2 is taken)", generated.ID)
+	}
+
+	if !e.Remove("slow") || e.Remove("slow") {
+		t.Error("Remove should succeed once")
+	}
+	e.Clear()
+	if len(e.List()) != 0 {
+		t.Error("Clear left rules")
+	}
+}
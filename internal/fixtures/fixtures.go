@@ -0,0 +1,383 @@
+package fixtures
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/vibe-gaming/esia-mock/internal/clients"
+	"github.com/vibe-gaming/esia-mock/internal/oauth"
+	"github.com/vibe-gaming/esia-mock/internal/storage"
+	"gopkg.in/yaml.v3"
+)
+
+// Extensions расширения файлов фикстур, загружаемых из каталога
+var Extensions = []string{".yaml", ".yml", ".json"}
+
+// Persona персона: номер телефона и явно заданные поля (часть полей или все)
+type Persona struct {
+	Phone string `json:"phone"`
+	storage.Override
+}
+
+// Client зарегистрированный клиент с политиками адресов возврата, scope и типов выдачи маркера
+type Client = clients.Client
+
+// Token заранее выданный токен доступа
+type Token struct {
+	AccessToken string `json:"accessToken"` // пусто — сгенерировать
+	ClientID    string `json:"clientId"`
+	Phone       string `json:"phone"`
+	Scope       string `json:"scope"`
+	ExpiresIn   string `json:"expiresIn"` // срок жизни, например 24h; пусто — стандартный
+}
+
+// Set фикстуры, собранные из всех файлов
+type Set struct {
+	Personas []Persona
+	Clients  []Client
+	Tokens   []Token
+}
+
+// TokensWhere возвращает фикстуры только с токенами, для которых match возвращает true
+func (s *Set) TokensWhere(match func(clientID, phone string) bool) *Set {
+	result := &Set{}
+	if s == nil {
+		return result
+	}
+	for _, t := range s.Tokens {
+		if match(t.ClientID, t.Phone) {
+			result.Tokens = append(result.Tokens, t)
+		}
+	}
+	return result
+}
+
+// ForPhone возвращает фикстуры персоны и токенов телефона
+func (s *Set) ForPhone(phone string) *Set {
+	result := s.TokensWhere(func(_, tokenPhone string) bool { return tokenPhone == phone })
+	if s == nil {
+		return result
+	}
+	for _, p := range s.Personas {
+		if p.Phone == phone {
+			result.Personas = append(result.Personas, p)
+		}
+	}
+	return result
+}
+
+// Error ошибка в файле фикстур с указанием места
+type Error struct {
+	File string
+	Line int
+	Err  error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s:%d: %v", e.File, e.Line, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Load читает фикстуры из файлов и каталогов. Из каталога берутся файлы с расширениями
+// Extensions в алфавитном порядке. YAML и JSON разбираются одинаково: JSON — подмножество YAML
+func Load(paths []string) (*Set, error) {
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if !entry.IsDir() && hasExtension(entry.Name()) {
+				files = append(files, filepath.Join(path, entry.Name()))
+			}
+		}
+	}
+
+	l := &loader{
+		set:     &Set{},
+		phones:  map[string]string{},
+		clients: map[string]string{},
+		tokens:  map[string]string{},
+	}
+	for _, file := range files {
+		if err := l.loadFile(file); err != nil {
+			return nil, err
+		}
+	}
+	return l.set, nil
+}
+
+// Apply загружает фикстуры в хранилища: персоны — как заданные поля, затем клиентов и токены
+func Apply(set *Set, users *storage.Cache, oauthStore *oauth.Store, registry *clients.Registry) error {
+	for _, p := range set.Personas {
+		if _, err := users.SetOverride(p.Phone, p.Override); err != nil {
+			return fmt.Errorf("persona %s: %w", p.Phone, err)
+		}
+	}
+
+	for _, c := range set.Clients {
+		client := c
+		if err := registry.Save(&client); err != nil {
+			return fmt.Errorf("client %s: %w", c.ID, err)
+		}
+	}
+
+	for _, t := range set.Tokens {
+		now := time.Now()
+		token := &oauth.Token{
+			AccessToken: t.AccessToken,
+			ClientID:    t.ClientID,
+			Scope:       t.Scope,
+			PhoneNumber: t.Phone,
+			CreatedAt:   now,
+		}
+		if t.ExpiresIn != "" {
+			ttl, _ := time.ParseDuration(t.ExpiresIn) // проверено при загрузке
+			token.ExpiresAt = now.Add(ttl)
+		}
+		if err := oauthStore.SaveToken(token); err != nil {
+			return fmt.Errorf("token for client %s: %w", t.ClientID, err)
+		}
+	}
+	return nil
+}
+
+// loader собирает фикстуры и проверяет уникальность ключей между файлами
+type loader struct {
+	set     *Set
+	phones  map[string]string // телефон -> место первого определения
+	clients map[string]string
+	tokens  map[string]string
+}
+
+// loadFile разбирает файл; документов YAML в файле может быть несколько
+func (l *loader) loadFile(file string) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var doc yaml.Node
+		err := dec.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return &Error{File: file, Line: yamlErrorLine(err), Err: err}
+		}
+		if err := l.loadDocument(file, &doc); err != nil {
+			return err
+		}
+	}
+}
+
+// loadDocument разбирает секции personas, clients и tokens
+func (l *loader) loadDocument(file string, doc *yaml.Node) error {
+	if len(doc.Content) == 0 {
+		return nil
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return &Error{File: file, Line: root.Line, Err: errors.New("expected a mapping with personas, clients or tokens")}
+	}
+
+	sections := map[string]*yaml.Node{}
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		key, value := root.Content[i], root.Content[i+1]
+		switch key.Value {
+		case "personas", "clients", "tokens":
+		default:
+			return &Error{File: file, Line: key.Line, Err: fmt.Errorf("unknown section %q, expected personas, clients or tokens", key.Value)}
+		}
+		if value.Kind != yaml.SequenceNode {
+			return &Error{File: file, Line: value.Line, Err: fmt.Errorf("section %s must be a list", key.Value)}
+		}
+		sections[key.Value] = value
+	}
+
+	adders := []struct {
+		name string
+		add  func(node *yaml.Node, at string) error
+	}{
+		{"personas", l.addPersona},
+		{"clients", l.addClient},
+		{"tokens", l.addToken},
+	}
+	for _, adder := range adders {
+		section, ok := sections[adder.name]
+		if !ok {
+			continue
+		}
+		for i, item := range section.Content {
+			if err := adder.add(item, fmt.Sprintf("%s:%d", file, item.Line)); err != nil {
+				return &Error{File: file, Line: item.Line, Err: fmt.Errorf("%s[%d]: %w", adder.name, i, err)}
+			}
+		}
+	}
+	return nil
+}
+
+func (l *loader) addPersona(node *yaml.Node, at string) error {
+	var p Persona
+	if err := decodeNode(node, &p); err != nil {
+		return err
+	}
+
+	p.Phone = storage.NormalizePhone(p.Phone)
+	if len(p.Phone) != 11 {
+		return errors.New("phone must contain 11 digits")
+	}
+	if prev, ok := l.phones[p.Phone]; ok {
+		return fmt.Errorf("persona %s is already defined at %s", p.Phone, prev)
+	}
+	if err := p.Override.Normalize(); err != nil {
+		return err
+	}
+
+	l.phones[p.Phone] = at
+	l.set.Personas = append(l.set.Personas, p)
+	return nil
+}
+
+func (l *loader) addClient(node *yaml.Node, at string) error {
+	var c Client
+	if err := decodeNode(node, &c); err != nil {
+		return err
+	}
+
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	if prev, ok := l.clients[c.ID]; ok {
+		return fmt.Errorf("client %s is already defined at %s", c.ID, prev)
+	}
+
+	l.clients[c.ID] = at
+	l.set.Clients = append(l.set.Clients, c)
+	return nil
+}
+
+func (l *loader) addToken(node *yaml.Node, at string) error {
+	var t Token
+	if err := decodeNode(node, &t); err != nil {
+		return err
+	}
+
+	if t.ClientID == "" {
+		return errors.New("clientId is required")
+	}
+	t.Phone = storage.NormalizePhone(t.Phone)
+	if len(t.Phone) != 11 {
+		return errors.New("phone must contain 11 digits")
+	}
+	if t.ExpiresIn != "" {
+		ttl, err := time.ParseDuration(t.ExpiresIn)
+		if err != nil || ttl <= 0 {
+			return fmt.Errorf("invalid expiresIn %q, expected a positive duration such as 24h", t.ExpiresIn)
+		}
+	}
+	if t.AccessToken != "" {
+		if prev, ok := l.tokens[t.AccessToken]; ok {
+			return fmt.Errorf("token %s is already defined at %s", t.AccessToken, prev)
+		}
+		l.tokens[t.AccessToken] = at
+	}
+
+	l.set.Tokens = append(l.set.Tokens, t)
+	return nil
+}
+
+// decodeNode разбирает элемент фикстуры в структуру с JSON-тегами, отклоняя неизвестные поля
+func decodeNode(node *yaml.Node, v interface{}) error {
+	data, err := json.Marshal(plainValue(node))
+	if err != nil {
+		return err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return fmt.Errorf("%s", strings.TrimPrefix(err.Error(), "json: "))
+	}
+	return nil
+}
+
+// plainValue переводит узел YAML в значения для encoding/json. Все скаляры, кроме
+// true/false и null, считаются строками: телефон +79161234567 или СНИЛС без кавычек
+// не превращаются в числа
+func plainValue(node *yaml.Node) interface{} {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		if len(node.Content) == 0 {
+			return nil
+		}
+		return plainValue(node.Content[0])
+	case yaml.AliasNode:
+		return plainValue(node.Alias)
+	case yaml.MappingNode:
+		m := make(map[string]interface{}, len(node.Content)/2)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			m[node.Content[i].Value] = plainValue(node.Content[i+1])
+		}
+		return m
+	case yaml.SequenceNode:
+		list := make([]interface{}, 0, len(node.Content))
+		for _, item := range node.Content {
+			list = append(list, plainValue(item))
+		}
+		return list
+	}
+
+	switch node.Tag {
+	case "!!null":
+		return nil
+	case "!!bool":
+		var b bool
+		if err := node.Decode(&b); err == nil {
+			return b
+		}
+	}
+	return node.Value
+}
+
+// yamlErrorLine извлекает номер строки из ошибки разбора YAML
+func yamlErrorLine(err error) int {
+	var line int
+	if _, scanErr := fmt.Sscanf(err.Error(), "yaml: line %d:", &line); scanErr == nil {
+		return line
+	}
+	return 0
+}
+
+// hasExtension проверяет, что файл — фикстура
+func hasExtension(name string) bool {
+	ext := strings.ToLower(filepath.Ext(name))
+	for _, e := range Extensions {
+		if ext == e {
+			return true
+		}
+	}
+	return false
+}
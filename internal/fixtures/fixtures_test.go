@@ -0,0 +1,131 @@
+package fixtures
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/vibe-gaming/esia-mock/internal/clients"
+	"github.com/vibe-gaming/esia-mock/internal/oauth"
+	"github.com/vibe-gaming/esia-mock/internal/storage"
+)
+
+func TestLoadAndApply(t *testing.T) {
+	set, err := Load([]string{"testdata"})
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(set.Personas) != 2 || len(set.Clients) != 1 || len(set.Tokens) != 1 {
+		t.Fatalf("loaded %d personas, %d clients, %d tokens; want 2, 1, 1",
+			len(set.Personas), len(set.Clients), len(set.Tokens))
+	}
+
+	backend := storage.NewMemoryBackend()
+	users, err := storage.New(storage.Options{Backend: backend})
+	if err != nil {
+		t.Fatalf("storage.New: %v", err)
+	}
+	store := oauth.NewStore(backend, 0, 0)
+	registry := clients.NewRegistry(backend)
+
+	if err := Apply(set, users, store, registry); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+
+	anna := users.GetOrCreate("79161234567")
+	if anna.FirstName != "Анна" || anna.BirthDate != "15.03.2010" || anna.Trusted {
+		t.Errorf("partial persona not applied: %+v", anna)
+	}
+
+	// Числа без кавычек в YAML остаются строками
+	full := users.GetOrCreate("79261234567")
+	if full.OID != "1000000042" || full.SNILS != "12345678901" {
+		t.Errorf("OID/SNILS = %s/%s", full.OID, full.SNILS)
+	}
+	if len(full.Documents) != 1 || full.Documents[0].Series != "4510" || !full.Documents[0].Verified {
+		t.Errorf("documents not applied: %+v", full.Documents)
+	}
+
+	if client, err := registry.Get("my-rp"); err != nil || len(client.RedirectURIs) != 1 {
+		t.Errorf("client = %+v, %v", client, err)
+	}
+
+	token, err := store.LookupToken("fixture-token")
+	if err != nil {
+		t.Fatalf("LookupToken: %v", err)
+	}
+	if token.PhoneNumber != "79161234567" || token.ExpiresIn != 24*3600 {
+		t.Errorf("token = %+v", token)
+	}
+}
+
+func TestLoadReportsFileAndLine(t *testing.T) {
+	for _, tc := range []struct {
+		name, content, want string
+		line                int
+	}{
+		{
+			name:    "unknown field",
+			content: "personas:\n  - phone: \"79161234567\"\n  - phone: \"79161234568\"\n    frstName: Анна\n",
+			line:    3,
+			want:    `unknown field "frstName"`,
+		},
+		{
+			name:    "invalid birth date",
+			content: "personas:\n  - phone: \"79161234567\"\n    birthDate: 2010\n",
+			line:    2,
+			want:    "invalid birthDate",
+		},
+		{
+			name:    "unknown section",
+			content: "clients: []\nusers: []\n",
+			line:    2,
+			want:    `unknown section "users"`,
+		},
+		{
+			name:    "syntax error",
+			content: "clients:\n  - id: a\n    name: b: c\n",
+			line:    3,
+			want:    "yaml:",
+		},
+		{
+			name:    "duplicate phone",
+			content: "personas:\n  - phone: \"79161234567\"\n  - phone: \"+7 (916) 123-45-67\"\n",
+			line:    3,
+			want:    "already defined at",
+		},
+		{
+			name:    "invalid expiresIn",
+			content: "tokens:\n  - clientId: a\n    phone: \"79161234567\"\n    expiresIn: tomorrow\n",
+			line:    2,
+			want:    "invalid expiresIn",
+		},
+		{
+			name:    "unknown grant type",
+			content: "clients:\n  - id: a\n    grantTypes: [password]\n",
+			line:    2,
+			want:    `unknown grant type "password"`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "fixture.yaml")
+			if err := os.WriteFile(path, []byte(tc.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err := Load([]string{path})
+			var fixtureErr *Error
+			if !errors.As(err, &fixtureErr) {
+				t.Fatalf("Load error = %v, want *Error", err)
+			}
+			if fixtureErr.File != path || fixtureErr.Line != tc.line {
+				t.Errorf("error at %s:%d, want %s:%d", fixtureErr.File, fixtureErr.Line, path, tc.line)
+			}
+			if !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("error %q does not contain %q", err, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/vibe-gaming/esia-mock/internal/logger"
+	"github.com/vibe-gaming/esia-mock/internal/snapshot"
+	"go.uber.org/zap"
+)
+
+// Snapshot выгружает (GET) или загружает (POST, ?mode=merge|replace) полное состояние мока
+func (h *Handler) Snapshot(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		doc, err := h.snapshots.Export()
+		if err != nil {
+			logger.Error("Failed to export snapshot", zap.Error(err))
+			writeJSONError(w, http.StatusInternalServerError, "server_error", err.Error())
+			return
+		}
+
+		logger.Info("Snapshot exported",
+			zap.Int("personas", len(doc.Personas)),
+			zap.Int("codes", len(doc.Codes)),
+			zap.Int("tokens", len(doc.Tokens)),
+			zap.Int("clients", len(doc.Clients)))
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(doc)
+
+	case http.MethodPost:
+		var doc snapshot.Document
+		if err := json.NewDecoder(r.Body).Decode(&doc); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "invalid_request", err.Error())
+			return
+		}
+
+		mode := r.URL.Query().Get("mode")
+		if err := h.snapshots.Import(&doc, mode); err != nil {
+			logger.Error("Failed to import snapshot", zap.Error(err))
+			writeJSONError(w, http.StatusBadRequest, "invalid_request", err.Error())
+			return
+		}
+
+		logger.Info("Snapshot imported",
+			zap.String("mode", mode),
+			zap.Int("personas", len(doc.Personas)),
+			zap.Int("codes", len(doc.Codes)),
+			zap.Int("tokens", len(doc.Tokens)),
+			zap.Int("clients", len(doc.Clients)))
+
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// writeJSONError отвечает ошибкой в формате OAuth2
+func writeJSONError(w http.ResponseWriter, status int, code, description string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{
+		"error":             code,
+		"error_description": description,
+	})
+}
@@ -0,0 +1,304 @@
+package handler
+
+import (
+	"html"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/vibe-gaming/esia-mock/internal/clients"
+	"github.com/vibe-gaming/esia-mock/internal/logger"
+	"github.com/vibe-gaming/esia-mock/internal/stats"
+	"github.com/vibe-gaming/esia-mock/internal/storage"
+	"go.uber.org/zap"
+)
+
+// clientsPath путь admin API реестра клиентов
+const clientsPath = "/admin/clients"
+
+// clientRejections число запросов, отклоненных политиками реестра клиентов
+var clientRejections = stats.New("client_rejections")
+
+// policyError нарушение политики зарегистрированного клиента
+type policyError struct {
+	Error       string // код ошибки OAuth2
+	Description string // описание ошибки в формате ЕСИА
+	// Redirect ошибку можно вернуть в redirect_uri: клиент и адрес возврата проверены
+	Redirect bool
+}
+
+// Ошибки политик клиента
+var (
+	errUnknownClient = policyError{
+		Error:       "invalid_client",
+		Description: "ESIA-007002: Информационная система не зарегистрирована",
+	}
+	errRedirectURI = policyError{
+		Error:       "invalid_request",
+		Description: "ESIA-007009: Адрес возврата (redirect_uri) не зарегистрирован для информационной системы",
+	}
+	errCertificate = policyError{
+		Error:       "invalid_client",
+		Description: "ESIA-007003: Сертификат клиента не соответствует информационной системе",
+	}
+	errGrantType = policyError{
+		Error:       "unauthorized_client",
+		Description: "ESIA-007013: Информационной системе не разрешен этот способ получения маркера доступа",
+		Redirect:    true,
+	}
+)
+
+// errScope ошибка запроса областей доступа, не разрешенных клиенту
+func errScope(disallowed []string) policyError {
+	return policyError{
+		Error:       "invalid_scope",
+		Description: "ESIA-007006: Запрошенная область доступа (scope) указана неверно: " + strings.Join(disallowed, " "),
+		Redirect:    true,
+	}
+}
+
+// checkClient проверяет запрос по политикам клиента из реестра. Пустая redirectURI или scope
+// не проверяются. Пока реестр пуст, любой запрос допустим. Нарушение политики возвращается
+// как *policyError, сбой хранилища — как error
+func (h *Handler) checkClient(clientID, redirectURI, scope, grantType string) (*policyError, error) {
+	client, enforced, err := h.clients.Lookup(clientID)
+	if err != nil || !enforced {
+		return nil, err
+	}
+
+	var violation policyError
+	switch {
+	case client == nil:
+		violation = errUnknownClient
+	case redirectURI != "" && !client.AllowsRedirect(redirectURI):
+		violation = errRedirectURI
+	case !client.AllowsGrant(grantType):
+		violation = errGrantType
+	case len(client.DisallowedScopes(scope)) > 0:
+		violation = errScope(client.DisallowedScopes(scope))
+	default:
+		return nil, nil
+	}
+
+	clientRejections.Inc()
+	logger.Info("Client policy violation",
+		zap.String("client_id", clientID),
+		zap.String("redirect_uri", redirectURI),
+		zap.String("scope", scope),
+		zap.String("grant_type", grantType),
+		zap.String("error", violation.Error))
+	return &violation, nil
+}
+
+// checkCertificate опознает клиента по сертификату TLS, если включен mTLS. Предъявленный
+// сертификат должен быть зарегистрирован за clientID, а клиент с зарегистрированным
+// сертификатом обязан его предъявить. В режиме require сертификат обязателен для всех клиентов
+func (h *Handler) checkCertificate(r *http.Request, clientID string) (*policyError, error) {
+	if !h.mutualTLS {
+		return nil, nil
+	}
+
+	if r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
+		owner, err := h.clients.FindByCertificate(r.TLS.PeerCertificates[0].Raw)
+		if err != nil {
+			return nil, err
+		}
+		if owner != nil && owner.ID == clientID {
+			return nil, nil
+		}
+	} else if !h.requireCert {
+		client, err := h.clients.Get(clientID)
+		if storage.IsNotFound(err) || (err == nil && client.Certificate == "") {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	clientRejections.Inc()
+	logger.Info("Client certificate mismatch", zap.String("client_id", clientID))
+	return &errCertificate, nil
+}
+
+// allowLogin проверяет запрос входа по политикам клиента; при нарушении сам отвечает
+func (h *Handler) allowLogin(w http.ResponseWriter, r *http.Request, clientID, redirectURI, scope, state string) bool {
+	violation, err := h.checkClient(clientID, redirectURI, scope, clients.GrantAuthorizationCode)
+	if err != nil {
+		logger.Error("Failed to check client", zap.Error(err))
+		http.Error(w, "server_error", http.StatusInternalServerError)
+		return false
+	}
+	if violation != nil {
+		rejectLogin(w, r, violation, redirectURI, state)
+		return false
+	}
+	return true
+}
+
+// rejectLogin отвечает на запрос входа, нарушивший политику клиента. Непроверенный адрес
+// возврата не используется: вместо перенаправления показывается страница ошибки
+func rejectLogin(w http.ResponseWriter, r *http.Request, violation *policyError, redirectURI, state string) {
+	if violation.Redirect {
+		params := url.Values{}
+		params.Set("error", violation.Error)
+		params.Set("error_description", violation.Description)
+		if state != "" {
+			params.Set("state", state)
+		}
+		if redirectURL, err := withQuery(redirectURI, params); err == nil {
+			http.Redirect(w, r, redirectURL, http.StatusFound)
+			return
+		}
+	}
+	writeErrorPage(w, http.StatusBadRequest, violation.Description)
+}
+
+// writeErrorPage отдает страницу ошибки входа вместо формы
+func writeErrorPage(w http.ResponseWriter, status int, message string) {
+	page := `<!DOCTYPE html>
+<html lang="ru">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>Ошибка — Госуслуги</title>
+    <style>
+        body {
+            font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Roboto, 'Helvetica Neue', Arial, sans-serif;
+            background: linear-gradient(135deg, #0d47a1 0%, #1976d2 100%);
+            min-height: 100vh;
+            margin: 0;
+            display: flex;
+            align-items: center;
+            justify-content: center;
+            padding: 20px;
+            box-sizing: border-box;
+        }
+        .container {
+            background: white;
+            padding: 40px;
+            border-radius: 12px;
+            box-shadow: 0 10px 40px rgba(0, 0, 0, 0.2);
+            max-width: 440px;
+            text-align: center;
+        }
+        h1 {
+            color: #333;
+            font-size: 24px;
+            margin: 0 0 16px;
+        }
+        p {
+            color: #666;
+            font-size: 15px;
+            line-height: 1.5;
+            margin: 0;
+        }
+    </style>
+</head>
+<body>
+    <div class="container">
+        <h1>Ошибка входа</h1>
+        <p>` + html.EscapeString(message) + `</p>
+    </div>
+</body>
+</html>
+`
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	w.Write([]byte(page))
+}
+
+// Clients admin API реестра клиентов:
+//
+//	GET    /admin/clients       — зарегистрированные клиенты
+//	POST   /admin/clients       — регистрация клиента
+//	GET    /admin/clients/{id}  — клиент по client_id
+//	PUT    /admin/clients/{id}  — создание или замена клиента
+//	DELETE /admin/clients/{id}  — удаление клиента
+func (h *Handler) Clients(w http.ResponseWriter, r *http.Request) {
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, clientsPath), "/")
+
+	switch {
+	case id == "" && r.Method == http.MethodGet:
+		list, err := h.clients.List()
+		if err != nil {
+			logger.Error("Failed to list clients", zap.Error(err))
+			writeJSONError(w, http.StatusInternalServerError, "server_error", err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, list)
+
+	case id == "" && r.Method == http.MethodPost:
+		var client clients.Client
+		if !decodeJSONBody(w, r, &client) {
+			return
+		}
+		if _, err := h.clients.Get(client.ID); err == nil {
+			writeJSONError(w, http.StatusConflict, "already_exists", "client "+client.ID+" is already registered, use PUT")
+			return
+		}
+		h.saveClient(w, &client, http.StatusCreated)
+
+	case id != "" && r.Method == http.MethodGet:
+		client, err := h.clients.Get(id)
+		if err != nil {
+			h.clientError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, client)
+
+	case id != "" && r.Method == http.MethodPut:
+		var client clients.Client
+		if !decodeJSONBody(w, r, &client) {
+			return
+		}
+		if client.ID != "" && client.ID != id {
+			writeJSONError(w, http.StatusBadRequest, "invalid_request", "client id in the body does not match the path")
+			return
+		}
+		client.ID = id
+		h.saveClient(w, &client, http.StatusOK)
+
+	case id != "" && r.Method == http.MethodDelete:
+		if _, err := h.clients.Get(id); err != nil {
+			h.clientError(w, err)
+			return
+		}
+		if err := h.clients.Delete(id); err != nil {
+			h.clientError(w, err)
+			return
+		}
+		logger.Info("Client removed", zap.String("client_id", id))
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *Handler) saveClient(w http.ResponseWriter, client *clients.Client, status int) {
+	if err := client.Validate(); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+	if err := h.clients.Save(client); err != nil {
+		h.clientError(w, err)
+		return
+	}
+
+	logger.Info("Client saved",
+		zap.String("client_id", client.ID),
+		zap.Strings("redirect_uris", client.RedirectURIs),
+		zap.Strings("scopes", client.Scopes))
+	writeJSON(w, status, client)
+}
+
+// clientError отвечает на ошибку реестра: отсутствие клиента — 404, остальное — 500
+func (h *Handler) clientError(w http.ResponseWriter, err error) {
+	if storage.IsNotFound(err) {
+		writeNotFound(w)
+		return
+	}
+	logger.Error("Client registry error", zap.Error(err))
+	writeJSONError(w, http.StatusInternalServerError, "server_error", err.Error())
+}
@@ -0,0 +1,240 @@
+package handler
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vibe-gaming/esia-mock/internal/clients"
+)
+
+// testCertificate самоподписанный сертификат ИС
+func testCertificate(t *testing.T) *x509.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "crm"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+// registerClients регистрирует клиентов; после этого политики реестра действуют
+func registerClients(t *testing.T, h *Handler, list ...*clients.Client) {
+	t.Helper()
+	for _, client := range list {
+		if err := h.clients.Save(client); err != nil {
+			t.Fatalf("Save %s: %v", client.ID, err)
+		}
+	}
+}
+
+func TestLoginClientPolicies(t *testing.T) {
+	h := newTestHandler(t)
+	registerClients(t, h,
+		&clients.Client{ID: "crm", RedirectURIs: []string{"http://crm/cb"}, Scopes: []string{"openid", "fullname"}},
+		&clients.Client{ID: "batch", RedirectURIs: []string{"http://batch/cb"}, GrantTypes: []string{clients.GrantClientCredentials}},
+	)
+
+	tests := []struct {
+		name        string
+		clientID    string
+		redirectURI string
+		scope       string
+		wantError   string // ошибка в redirect_uri; пусто — страница ошибки или успех
+		wantPage    string // описание на странице ошибки
+	}{
+		{"allowed", "crm", "http://crm/cb", "openid fullname", "", ""},
+		{"unknown client", "other", "http://crm/cb", "openid", "", "ESIA-007002"},
+		{"disallowed redirect", "crm", "http://evil/cb", "openid", "", "ESIA-007009"},
+		{"disallowed scope", "crm", "http://crm/cb", "openid snils", "invalid_scope", ""},
+		{"grant type", "batch", "http://batch/cb", "openid", "unauthorized_client", ""},
+	}
+	for _, tt := range tests {
+		params := url.Values{
+			"client_id": {tt.clientID}, "redirect_uri": {tt.redirectURI}, "scope": {tt.scope},
+			"state": {"s1"}, "phone": {"79991234567"},
+		}
+
+		t.Run(tt.name+"/authorize", func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			h.Authorize(rec, httptest.NewRequest(http.MethodGet, "/aas/oauth2/ac?"+params.Encode(), nil))
+			checkLoginResponse(t, rec, tt.redirectURI, tt.wantError, tt.wantPage, http.StatusOK)
+		})
+		t.Run(tt.name+"/submit", func(t *testing.T) {
+			rec := postForm(h.AuthorizeSubmit, "/aas/oauth2/authorize", params)
+			checkLoginResponse(t, rec, tt.redirectURI, tt.wantError, tt.wantPage, http.StatusFound)
+		})
+	}
+}
+
+// checkLoginResponse проверяет ответ на запрос входа: ошибку в redirect_uri,
+// страницу ошибки без перенаправления или успешный ответ со статусом okStatus
+func checkLoginResponse(t *testing.T, rec *httptest.ResponseRecorder, redirectURI, wantError, wantPage string, okStatus int) {
+	t.Helper()
+	location := rec.Header().Get("Location")
+	switch {
+	case wantPage != "":
+		if rec.Code != http.StatusBadRequest || location != "" || !strings.Contains(rec.Body.String(), wantPage) {
+			t.Errorf("status %d, Location %q, want error page with %s", rec.Code, location, wantPage)
+		}
+	case wantError != "":
+		u, err := url.Parse(location)
+		if rec.Code != http.StatusFound || err != nil || !strings.HasPrefix(location, redirectURI+"?") {
+			t.Fatalf("status %d, Location %q, want redirect to %s", rec.Code, location, redirectURI)
+		}
+		if got := u.Query(); got.Get("error") != wantError || got.Get("state") != "s1" || got.Get("code") != "" {
+			t.Errorf("callback query = %v, want error %s", got, wantError)
+		}
+	default:
+		if rec.Code != okStatus {
+			t.Errorf("status %d, want %d; body %s", rec.Code, okStatus, rec.Body)
+		}
+	}
+}
+
+func TestAuthorizeSubmitValidatesRedirectBeforeCancel(t *testing.T) {
+	h := newTestHandler(t)
+	for _, redirectURI := range []string{"", "crm/cb", "http://crm/cb#frag"} {
+		rec := postForm(h.AuthorizeSubmit, "/aas/oauth2/authorize", url.Values{
+			"client_id": {"crm"}, "redirect_uri": {redirectURI}, "action": {"cancel"},
+		})
+		if rec.Code != http.StatusBadRequest || rec.Header().Get("Location") != "" {
+			t.Errorf("redirect_uri %q: status %d, Location %q; want 400 without redirect",
+				redirectURI, rec.Code, rec.Header().Get("Location"))
+		}
+	}
+	rec := postForm(h.AuthorizeSubmit, "/aas/oauth2/authorize", url.Values{
+		"redirect_uri": {"http://crm/cb"}, "action": {"cancel"},
+	})
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("missing client_id: status %d, want 400", rec.Code)
+	}
+}
+
+func TestTokenClientChecks(t *testing.T) {
+	h := newTestHandler(t)
+	h.SetMutualTLS(true, false)
+	cert := testCertificate(t)
+	registerClients(t, h, &clients.Client{
+		ID:           "crm",
+		RedirectURIs: []string{"http://crm/cb"},
+		Certificate:  string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})),
+	})
+	location := submitLogin(t, h, url.Values{
+		"client_id": {"crm"}, "redirect_uri": {"http://crm/cb"}, "phone": {"79991234567"},
+	})
+	code := location.Query().Get("code")
+
+	tests := []struct {
+		name      string
+		clientID  string
+		peer      *x509.Certificate
+		status    int
+		wantError string
+	}{
+		// Отклоненные запросы не тратят код
+		{"unknown client", "other", cert, http.StatusBadRequest, "invalid_client"},
+		{"no certificate", "crm", nil, http.StatusBadRequest, "invalid_client"},
+		{"foreign certificate", "crm", testCertificate(t), http.StatusBadRequest, "invalid_client"},
+		{"registered certificate", "crm", cert, http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{
+				"grant_type": {"authorization_code"}, "client_id": {tt.clientID},
+				"code": {code}, "redirect_uri": {"http://crm/cb"},
+			}
+			req := httptest.NewRequest(http.MethodPost, "/aas/oauth2/te", strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			if tt.peer != nil {
+				req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{tt.peer}}
+			}
+			rec := httptest.NewRecorder()
+			h.Token(rec, req)
+
+			if rec.Code != tt.status {
+				t.Fatalf("status %d, want %d; body %s", rec.Code, tt.status, rec.Body)
+			}
+			if tt.wantError != "" && decodeError(t, rec) != tt.wantError {
+				t.Errorf("error, want %s", tt.wantError)
+			}
+		})
+	}
+}
+
+func TestTokenRequireCertificate(t *testing.T) {
+	h := newTestHandler(t)
+	h.SetMutualTLS(true, true)
+	cert := testCertificate(t)
+	registerClients(t, h,
+		&clients.Client{ID: "plain", RedirectURIs: []string{"http://plain/cb"}},
+		&clients.Client{
+			ID:           "crm",
+			RedirectURIs: []string{"http://crm/cb"},
+			Certificate:  string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})),
+		},
+	)
+
+	tests := []struct {
+		clientID string
+		peer     *x509.Certificate
+		status   int
+	}{
+		// В режиме require сертификат нужен и клиенту без зарегистрированного сертификата
+		{"plain", nil, http.StatusBadRequest},
+		{"crm", nil, http.StatusBadRequest},
+		{"crm", cert, http.StatusOK},
+	}
+	for _, tt := range tests {
+		redirectURI := "http://" + tt.clientID + "/cb"
+		location := submitLogin(t, h, url.Values{
+			"client_id": {tt.clientID}, "redirect_uri": {redirectURI}, "phone": {"79991234567"},
+		})
+		form := url.Values{
+			"grant_type": {"authorization_code"}, "client_id": {tt.clientID},
+			"code": {location.Query().Get("code")}, "redirect_uri": {redirectURI},
+		}
+		req := httptest.NewRequest(http.MethodPost, "/aas/oauth2/te", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if tt.peer != nil {
+			req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{tt.peer}}
+		}
+		rec := httptest.NewRecorder()
+		h.Token(rec, req)
+		if rec.Code != tt.status {
+			t.Errorf("%s, certificate %v: status %d, want %d; body %s", tt.clientID, tt.peer != nil, rec.Code, tt.status, rec.Body)
+		}
+	}
+
+	// Проверки состояния и форма входа не требуют сертификата
+	rec := httptest.NewRecorder()
+	h.Healthz(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("healthz: status %d, want 200", rec.Code)
+	}
+}
@@ -0,0 +1,94 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/vibe-gaming/esia-mock/internal/faults"
+	"github.com/vibe-gaming/esia-mock/internal/logger"
+	"github.com/vibe-gaming/esia-mock/internal/storage"
+	"go.uber.org/zap"
+)
+
+// faultsPath путь admin API правил внедрения сбоев
+const faultsPath = "/admin/faults"
+
+// Faults admin API правил внедрения сбоев:
+//
+//	GET    /admin/faults       — правила со счетчиками срабатываний
+//	POST   /admin/faults       — добавление правила
+//	DELETE /admin/faults       — удаление всех правил
+//	DELETE /admin/faults/{id}  — удаление правила
+func (h *Handler) Faults(w http.ResponseWriter, r *http.Request) {
+	if h.faults == nil {
+		writeNotFound(w)
+		return
+	}
+
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, faultsPath), "/")
+
+	switch {
+	case id == "" && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, h.faults.List())
+
+	case id == "" && r.Method == http.MethodPost:
+		var rule faults.Rule
+		if !decodeJSONBody(w, r, &rule) {
+			return
+		}
+		added, err := h.faults.Add(rule)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "invalid_request", err.Error())
+			return
+		}
+
+		logger.Info("Fault rule added", zap.String("id", added.ID), zap.String("endpoint", added.Endpoint))
+		writeJSON(w, http.StatusCreated, added)
+
+	case id == "" && r.Method == http.MethodDelete:
+		h.faults.Clear()
+		logger.Info("Fault rules cleared")
+		w.WriteHeader(http.StatusNoContent)
+
+	case id != "" && r.Method == http.MethodDelete:
+		if !h.faults.Remove(id) {
+			writeNotFound(w)
+			return
+		}
+		logger.Info("Fault rule removed", zap.String("id", id))
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// RequestIdentity определяет клиента и телефон запроса для правил сбоев: из параметров
+// формы, из кода авторизации при обмене или из Bearer токена. Телефон приводится к виду
+// 7XXXXXXXXXX, как в правилах
+func (h *Handler) RequestIdentity(r *http.Request) (clientID, phone string) {
+	clientID = r.FormValue("client_id")
+	phone = storage.NormalizePhone(r.FormValue("phone"))
+	if phone == "" {
+		if hintPhone, _, err := parseHint(r.Form); err == nil {
+			phone = hintPhone
+		}
+	}
+
+	if code := r.FormValue("code"); code != "" && phone == "" {
+		if authCode, err := h.oauth.LookupCode(code); err == nil {
+			phone = storage.NormalizePhone(authCode.PhoneNumber)
+			if clientID == "" {
+				clientID = authCode.ClientID
+			}
+		}
+	}
+
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		if token, err := h.oauth.LookupToken(strings.TrimPrefix(auth, "Bearer ")); err == nil {
+			phone = storage.NormalizePhone(phoneFromToken(token))
+			clientID = token.ClientID
+		}
+	}
+	return clientID, phone
+}
@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/vibe-gaming/esia-mock/internal/faults"
+)
+
+func TestRequestIdentityNormalizesPhone(t *testing.T) {
+	h := newTestHandler(t)
+	location := submitLogin(t, h, url.Values{
+		"client_id": {"rp"}, "redirect_uri": {"http://rp/cb"}, "phone": {"+7 999 123-45-67"},
+	})
+	form := url.Values{
+		"grant_type": {"authorization_code"}, "client_id": {"rp"},
+		"code": {location.Query().Get("code")}, "redirect_uri": {"http://rp/cb"},
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/aas/oauth2/te", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if clientID, phone := h.RequestIdentity(req); clientID != "rp" || phone != "79991234567" {
+		t.Errorf("code identity = %q, %q; want rp, 79991234567", clientID, phone)
+	}
+
+	rec := postForm(h.Token, "/aas/oauth2/te", form)
+	var token TokenResponse
+	if err := json.NewDecoder(rec.Body).Decode(&token); err != nil || token.AccessToken == "" {
+		t.Fatalf("token: status %d, %v", rec.Code, err)
+	}
+
+	// Правило сбоя по телефону срабатывает на запросы с токеном этого телефона
+	engine := faults.NewEngine()
+	if _, err := engine.Add(faults.Rule{Endpoint: "/rs/prns/*", Phone: "+7 (999) 123-45-67", Status: http.StatusServiceUnavailable}); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	req = httptest.NewRequest(http.MethodGet, "/rs/prns/1", nil)
+	req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+	if _, phone := h.RequestIdentity(req); phone != "79991234567" {
+		t.Errorf("token identity phone = %q, want 79991234567", phone)
+	}
+	rec = httptest.NewRecorder()
+	engine.Middleware(http.HandlerFunc(h.Persons), h.RequestIdentity).ServeHTTP(rec, req)
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("phone rule: status %d, want 503", rec.Code)
+	}
+}
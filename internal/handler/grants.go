@@ -0,0 +1,254 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/vibe-gaming/esia-mock/internal/logger"
+	"github.com/vibe-gaming/esia-mock/internal/oauth"
+	"github.com/vibe-gaming/esia-mock/internal/storage"
+	"go.uber.org/zap"
+)
+
+// Пути admin API кодов и токенов
+const (
+	codesPath  = "/admin/codes"
+	tokensPath = "/admin/tokens"
+)
+
+// PersonaSummary персона, к которой привязан код или токен
+type PersonaSummary struct {
+	OID        string `json:"oid"`
+	FirstName  string `json:"firstName"`
+	LastName   string `json:"lastName"`
+	MiddleName string `json:"middleName"`
+}
+
+// CodeInfo код авторизации в admin API
+type CodeInfo struct {
+	Code        string          `json:"code"`
+	ClientID    string          `json:"clientId"`
+	RedirectURI string          `json:"redirectUri"`
+	State       string          `json:"state,omitempty"`
+	Scopes      []string        `json:"scopes"`
+	Phone       string          `json:"phone"`
+	IssuedAt    time.Time       `json:"issuedAt"`
+	ExpiresAt   time.Time       `json:"expiresAt"`
+	Profile     string          `json:"profile,omitempty"`
+	Persona     *PersonaSummary `json:"persona,omitempty"`
+}
+
+// TokenInfo токен доступа в admin API
+type TokenInfo struct {
+	AccessToken string          `json:"accessToken"`
+	ClientID    string          `json:"clientId"`
+	Scopes      []string        `json:"scopes"`
+	Phone       string          `json:"phone"`
+	IssuedAt    time.Time       `json:"issuedAt"`
+	ExpiresAt   time.Time       `json:"expiresAt"`
+	Profile     string          `json:"profile,omitempty"`
+	Persona     *PersonaSummary `json:"persona,omitempty"`
+}
+
+// grantFilter отбор кодов и токенов по query-параметрам client_id, phone и oid
+type grantFilter struct {
+	clientID string
+	phone    string
+	oid      string
+}
+
+// parseGrantFilter читает фильтр из query-параметров
+func parseGrantFilter(values url.Values) grantFilter {
+	return grantFilter{
+		clientID: values.Get("client_id"),
+		phone:    storage.NormalizePhone(values.Get("phone")),
+		oid:      values.Get("oid"),
+	}
+}
+
+// matches проверяет код или токен с данным клиентом и персоной
+func (f grantFilter) matches(clientID, phone string, persona *PersonaSummary) bool {
+	if f.clientID != "" && clientID != f.clientID {
+		return false
+	}
+	if f.phone != "" && phone != f.phone {
+		return false
+	}
+	if f.oid != "" && (persona == nil || persona.OID != f.oid) {
+		return false
+	}
+	return true
+}
+
+// Codes admin API кодов авторизации:
+//
+//	GET    /admin/codes?client_id=&phone=&oid=  — действующие коды
+//	GET    /admin/codes/{code}                  — код без обмена
+//	DELETE /admin/codes/{code}                  — отзыв кода
+func (h *Handler) Codes(w http.ResponseWriter, r *http.Request) {
+	code := strings.Trim(strings.TrimPrefix(r.URL.Path, codesPath), "/")
+
+	switch {
+	case code == "" && r.Method == http.MethodGet:
+		codes, _, err := h.oauth.Export()
+		if err != nil {
+			logger.Error("Failed to list codes", zap.Error(err))
+			writeJSONError(w, http.StatusInternalServerError, "server_error", err.Error())
+			return
+		}
+
+		filter := parseGrantFilter(r.URL.Query())
+		result := []CodeInfo{}
+		for _, c := range codes {
+			info := h.toCodeInfo(c)
+			if filter.matches(info.ClientID, info.Phone, info.Persona) {
+				result = append(result, info)
+			}
+		}
+		writeJSON(w, http.StatusOK, result)
+
+	case code != "" && r.Method == http.MethodGet:
+		authCode, err := h.oauth.LookupCode(code)
+		if errors.Is(err, oauth.ErrInvalidCode) {
+			writeNotFound(w)
+			return
+		}
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, "server_error", err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, h.toCodeInfo(authCode))
+
+	case code != "" && r.Method == http.MethodDelete:
+		err := h.oauth.RevokeCode(code)
+		if errors.Is(err, oauth.ErrInvalidCode) {
+			writeNotFound(w)
+			return
+		}
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, "server_error", err.Error())
+			return
+		}
+
+		logger.Info("Authorization code revoked", zap.String("code", code))
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// Tokens admin API токенов доступа:
+//
+//	GET    /admin/tokens?client_id=&phone=&oid=  — действующие токены
+//	GET    /admin/tokens/{access_token}          — токен
+//	DELETE /admin/tokens/{access_token}          — отзыв токена
+func (h *Handler) Tokens(w http.ResponseWriter, r *http.Request) {
+	accessToken := strings.Trim(strings.TrimPrefix(r.URL.Path, tokensPath), "/")
+
+	switch {
+	case accessToken == "" && r.Method == http.MethodGet:
+		_, tokens, err := h.oauth.Export()
+		if err != nil {
+			logger.Error("Failed to list tokens", zap.Error(err))
+			writeJSONError(w, http.StatusInternalServerError, "server_error", err.Error())
+			return
+		}
+
+		filter := parseGrantFilter(r.URL.Query())
+		result := []TokenInfo{}
+		for _, t := range tokens {
+			info := h.toTokenInfo(t)
+			if filter.matches(info.ClientID, info.Phone, info.Persona) {
+				result = append(result, info)
+			}
+		}
+		writeJSON(w, http.StatusOK, result)
+
+	case accessToken != "" && r.Method == http.MethodGet:
+		token, err := h.oauth.LookupToken(accessToken)
+		if errors.Is(err, oauth.ErrInvalidToken) {
+			writeNotFound(w)
+			return
+		}
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, "server_error", err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, h.toTokenInfo(token))
+
+	case accessToken != "" && r.Method == http.MethodDelete:
+		token, err := h.oauth.LookupToken(accessToken)
+		if errors.Is(err, oauth.ErrInvalidToken) {
+			writeNotFound(w)
+			return
+		}
+		if err == nil {
+			err = h.oauth.Revoke(accessToken)
+		}
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, "server_error", err.Error())
+			return
+		}
+
+		logger.Info("Access token revoked",
+			zap.String("client_id", token.ClientID),
+			zap.String("phone", phoneFromToken(token)))
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// toCodeInfo переводит код в представление admin API
+func (h *Handler) toCodeInfo(code *oauth.AuthCode) CodeInfo {
+	return CodeInfo{
+		Code:        code.Code,
+		ClientID:    code.ClientID,
+		RedirectURI: code.RedirectURI,
+		State:       code.State,
+		Scopes:      strings.Fields(code.Scope),
+		Phone:       code.PhoneNumber,
+		IssuedAt:    code.CreatedAt,
+		ExpiresAt:   code.ExpiresAt,
+		Profile:     code.Profile,
+		Persona:     h.personaSummary(code.PhoneNumber, code.Hint),
+	}
+}
+
+// toTokenInfo переводит токен в представление admin API
+func (h *Handler) toTokenInfo(token *oauth.Token) TokenInfo {
+	phone := phoneFromToken(token)
+	return TokenInfo{
+		AccessToken: token.AccessToken,
+		ClientID:    token.ClientID,
+		Scopes:      strings.Fields(token.Scope),
+		Phone:       phone,
+		IssuedAt:    token.CreatedAt,
+		ExpiresAt:   token.ExpiresAt,
+		Profile:     token.Profile,
+		Persona:     h.personaSummary(phone, token.Hint),
+	}
+}
+
+// personaSummary возвращает персону телефона; та же персона вернется по токену из /userinfo.
+// Просмотр выдач не создает персон: nil, если персоны нет (удалена или вытеснена)
+func (h *Handler) personaSummary(phone string, hint storage.Hint) *PersonaSummary {
+	if phone == "" {
+		return nil
+	}
+	user, ok := h.userCache.GetWithHint(phone, hint)
+	if !ok {
+		return nil
+	}
+	return &PersonaSummary{
+		OID:        user.OID,
+		FirstName:  user.FirstName,
+		LastName:   user.LastName,
+		MiddleName: user.MiddleName,
+	}
+}
@@ -0,0 +1,25 @@
+package handler
+
+import (
+	"testing"
+
+	"github.com/vibe-gaming/esia-mock/internal/oauth"
+)
+
+func TestPersonaSummaryDoesNotCreatePersona(t *testing.T) {
+	h := newTestHandler(t)
+	code := &oauth.AuthCode{Code: "c0de", ClientID: "rp", PhoneNumber: "79991234567"}
+
+	if info := h.toCodeInfo(code); info.Persona != nil {
+		t.Errorf("persona of unknown phone = %+v, want nil", info.Persona)
+	}
+	if _, ok := h.userCache.Get("79991234567"); ok {
+		t.Fatal("listing grants created a persona")
+	}
+
+	user := h.userCache.GetOrCreate("79991234567")
+	info := h.toCodeInfo(code)
+	if info.Persona == nil || info.Persona.OID != user.OID {
+		t.Errorf("persona = %+v, want OID %s", info.Persona, user.OID)
+	}
+}
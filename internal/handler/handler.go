@@ -1,45 +1,44 @@
 package handler
 
 import (
-	"crypto/rand"
-	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"html"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
-	"sync"
-	"time"
+	"sync/atomic"
 
+	"github.com/vibe-gaming/esia-mock/internal/clients"
+	"github.com/vibe-gaming/esia-mock/internal/faults"
+	"github.com/vibe-gaming/esia-mock/internal/fixtures"
 	"github.com/vibe-gaming/esia-mock/internal/logger"
+	"github.com/vibe-gaming/esia-mock/internal/maintenance"
+	"github.com/vibe-gaming/esia-mock/internal/oauth"
+	"github.com/vibe-gaming/esia-mock/internal/profiles"
+	"github.com/vibe-gaming/esia-mock/internal/ratelimit"
+	"github.com/vibe-gaming/esia-mock/internal/snapshot"
+	"github.com/vibe-gaming/esia-mock/internal/stats"
 	"github.com/vibe-gaming/esia-mock/internal/storage"
 	"go.uber.org/zap"
 )
 
+// Handler не хранит состояния: коды и токены живут в oauth.Store, персоны — в storage.Cache
 type Handler struct {
-	codes        map[string]*AuthCode
-	tokens       map[string]*Token
-	userSessions map[string]string // code -> phone number
-	userCache    *storage.Cache    // кеш с моковыми данными пользователей
-	mu           sync.RWMutex
-}
-
-type AuthCode struct {
-	Code        string
-	ClientID    string
-	RedirectURI string
-	State       string
-	PhoneNumber string
-	CreatedAt   time.Time
-}
-
-type Token struct {
-	AccessToken  string
-	RefreshToken string
-	IDToken      string
-	ExpiresIn    int
-	TokenType    string
-	PhoneNumber  string // Номер телефона пользователя
-	CreatedAt    time.Time
+	oauth       *oauth.Store       // коды авторизации и токены
+	userCache   *storage.Cache     // кеш с моковыми данными пользователей
+	snapshots   *snapshot.Manager  // выгрузка и загрузка состояния
+	clients     *clients.Registry  // зарегистрированные клиенты
+	fixtures    *fixtures.Set      // фикстуры, восстанавливаемые при сбросе состояния
+	faults      *faults.Engine     // правила внедрения сбоев
+	rateLimits  *ratelimit.Limiter // лимиты запросов клиентов
+	maintenance *maintenance.Mode  // режим технических работ
+	mutualTLS   bool               // клиенты опознаются по сертификату TLS
+	requireCert bool               // обмен кода без сертификата TLS отклоняется
+	ready       atomic.Bool        // сервер принимает запросы
+	readyCheck  func() error       // проверка зависимостей для /readyz
 }
 
 type TokenResponse struct {
@@ -57,7 +56,7 @@ type UserInfo struct {
 	MiddleName    string   `json:"middleName,omitempty"`
 	BirthDate     string   `json:"birthDate"`
 	Gender        string   `json:"gender"`
-	SNILS         string   `json:"snils"`
+	SNILS         string   `json:"snils,omitempty"`
 	INN           string   `json:"inn,omitempty"`
 	Email         string   `json:"email,omitempty"`
 	Mobile        string   `json:"mobile,omitempty"`
@@ -65,19 +64,132 @@ type UserInfo struct {
 	Verified      bool     `json:"verified"`
 	Citizenship   string   `json:"citizenship,omitempty"`
 	Status        string   `json:"status"`
+	RIdDoc        string   `json:"rIdDoc,omitempty"`
 	Addresses     []string `json:"addresses,omitempty"`
 	Documents     []string `json:"documents,omitempty"`
 	Kids          []string `json:"kids,omitempty"`
 	Organizations []string `json:"organizations,omitempty"`
 }
 
-func New() *Handler {
+// DocumentInfo документ пользователя в формате REST API ЕСИА
+type DocumentInfo struct {
+	StateFacts   []string `json:"stateFacts"`
+	ID           string   `json:"id"`
+	Type         string   `json:"type"`
+	VrfStu       string   `json:"vrfStu"`
+	Series       string   `json:"series,omitempty"`
+	Number       string   `json:"number"`
+	IssueDate    string   `json:"issueDate"`
+	IssueID      string   `json:"issueId,omitempty"`
+	IssuedBy     string   `json:"issuedBy,omitempty"`
+	ExpiryDate   string   `json:"expiryDate,omitempty"`
+	IssueCountry string   `json:"issueCountry,omitempty"`
+}
+
+// AddressInfo адрес пользователя в формате REST API ЕСИА
+type AddressInfo struct {
+	StateFacts []string `json:"stateFacts"`
+	ID         string   `json:"id"`
+	Type       string   `json:"type"`
+	AddressStr string   `json:"addressStr"`
+	CountryID  string   `json:"countryId"`
+	ZipCode    string   `json:"zipCode"`
+	Region     string   `json:"region"`
+	City       string   `json:"city"`
+	Street     string   `json:"street"`
+	House      string   `json:"house"`
+	Flat       string   `json:"flat,omitempty"`
+}
+
+// Collection коллекция ресурсов в формате REST API ЕСИА
+type Collection struct {
+	StateFacts []string      `json:"stateFacts"`
+	Size       int           `json:"size"`
+	Elements   []interface{} `json:"elements"`
+}
+
+// MockInfo сведения о настройках мока
+type MockInfo struct {
+	Seed                       string `json:"seed"`
+	GeneratorVersion           int    `json:"generatorVersion"`
+	SupportedGeneratorVersions []int  `json:"supportedGeneratorVersions"`
+	CachedUsers                int    `json:"cachedUsers"`
+	// Profile профиль среды, обслуживший запрос к /mock/info
+	Profile *ProfileInfo `json:"profile,omitempty"`
+}
+
+func New(userCache *storage.Cache, oauthStore *oauth.Store, registry *clients.Registry) *Handler {
 	return &Handler{
-		codes:        make(map[string]*AuthCode),
-		tokens:       make(map[string]*Token),
-		userSessions: make(map[string]string),
-		userCache:    storage.New(),
+		oauth:     oauthStore,
+		userCache: userCache,
+		snapshots: snapshot.NewManager(userCache, oauthStore, registry),
+		clients:   registry,
+	}
+}
+
+// SetFaults задает движок правил внедрения сбоев, управляемый через admin API
+func (h *Handler) SetFaults(engine *faults.Engine) {
+	h.faults = engine
+}
+
+// SetRateLimits задает ограничитель запросов, управляемый через admin API
+func (h *Handler) SetRateLimits(limiter *ratelimit.Limiter) {
+	h.rateLimits = limiter
+}
+
+// SetMaintenance задает режим технических работ, управляемый через admin API
+func (h *Handler) SetMaintenance(mode *maintenance.Mode) {
+	h.maintenance = mode
+}
+
+// SetMutualTLS включает проверку сертификата клиента при обмене кода на токен. С required
+// сертификат обязан предъявить любой клиент, а не только клиент с зарегистрированным сертификатом
+func (h *Handler) SetMutualTLS(enabled, required bool) {
+	h.mutualTLS = enabled
+	h.requireCert = enabled && required
+}
+
+// SetFixtures задает фикстуры, загруженные при старте: сброс состояния возвращает их
+func (h *Handler) SetFixtures(set *fixtures.Set) {
+	h.fixtures = set
+}
+
+// MockStats счетчики событий и текущий размер состояния мока
+type MockStats struct {
+	Counters    map[string]int64 `json:"counters"`
+	Codes       int              `json:"codes"`
+	Tokens      int              `json:"tokens"`
+	CachedUsers int              `json:"cachedUsers"`
+}
+
+// Stats возвращает статистику мока: число вытеснений и размер хранимого состояния
+func (h *Handler) Stats(w http.ResponseWriter, r *http.Request) {
+	codes, tokens, err := h.oauth.Counts()
+	if err != nil {
+		logger.Error("Failed to count codes and tokens", zap.Error(err))
 	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(MockStats{
+		Counters:    stats.Snapshot(),
+		Codes:       codes,
+		Tokens:      tokens,
+		CachedUsers: h.userCache.Count(),
+	})
+}
+
+// Info возвращает настройки мока, влияющие на генерацию данных
+func (h *Handler) Info(w http.ResponseWriter, r *http.Request) {
+	info := MockInfo{
+		Seed:                       h.userCache.Seed(),
+		GeneratorVersion:           h.userCache.GeneratorVersion(),
+		SupportedGeneratorVersions: storage.GeneratorVersions(),
+		CachedUsers:                h.userCache.Count(),
+		Profile:                    profileInfo(r),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(info)
 }
 
 // OAuth2 Authorization endpoint
@@ -98,13 +210,43 @@ func (h *Handler) Authorize(w http.ResponseWriter, r *http.Request) {
 		zap.String("response_type", responseType),
 	)
 
-	if clientID == "" || redirectURI == "" {
+	if clientID == "" || !validRedirectURI(redirectURI) {
+		http.Error(w, "invalid_request", http.StatusBadRequest)
+		return
+	}
+	if !h.allowLogin(w, r, clientID, redirectURI, scope, state) {
+		return
+	}
+
+	// Подсказки выбора персоны передаются в форму и применяются при ее отправке
+	hintPhone, hint, err := parseHint(r.URL.Query())
+	if err != nil {
+		logger.Debug("Invalid persona hint", zap.Error(err))
 		http.Error(w, "invalid_request", http.StatusBadRequest)
 		return
 	}
 
-	// Показываем форму для ввода номера телефона
-	html := `
+	hintInfo := ""
+	if !hint.IsZero() {
+		hintInfo = "<br>Персона: " + html.EscapeString(hint.String())
+	}
+
+	// Сценарии отказа есть только в тестовой среде
+	outcomeSelect := ""
+	if sandbox(r) {
+		outcomeSelect = `
+            <details class="mock-outcome">
+                <summary>Сценарий тестовой среды</summary>
+                <select name="mock_outcome">
+                    <option value="">Успешный вход</option>` + outcomeOptions() + `
+                </select>
+            </details>
+            `
+	}
+
+	// Показываем форму для ввода номера телефона. Параметры RP экранируются: state
+	// с кавычкой не обрезается и не встраивает разметку в страницу
+	page := `
 <!DOCTYPE html>
 <html lang="ru">
 <head>
@@ -203,6 +345,30 @@ func (h *Handler) Authorize(w http.ResponseWriter, r *http.Request) {
         button:active {
             background: #0a3a7f;
         }
+        button.secondary {
+            margin-top: 10px;
+            background: white;
+            color: #0d47a1;
+            border: 2px solid #0d47a1;
+        }
+        button.secondary:hover {
+            background: #e3f2fd;
+        }
+        .mock-outcome {
+            margin-top: 20px;
+            font-size: 13px;
+            color: #666;
+        }
+        .mock-outcome summary {
+            cursor: pointer;
+        }
+        .mock-outcome select {
+            width: 100%;
+            margin-top: 8px;
+            padding: 8px;
+            border: 2px solid #e0e0e0;
+            border-radius: 8px;
+        }
         .info {
             margin-top: 20px;
             padding: 12px;
@@ -221,12 +387,14 @@ func (h *Handler) Authorize(w http.ResponseWriter, r *http.Request) {
         </div>
         <h1>Вход через Госуслуги</h1>
         <p class="subtitle">Введите номер телефона для авторизации</p>
-        <form method="POST" action="/aas/oauth2/authorize">
-            <input type="hidden" name="client_id" value="` + clientID + `">
-            <input type="hidden" name="redirect_uri" value="` + redirectURI + `">
-            <input type="hidden" name="state" value="` + state + `">
-            <input type="hidden" name="scope" value="` + scope + `">
-            <input type="hidden" name="response_type" value="` + responseType + `">
+        <form method="POST" action="` + profiles.PrefixFromContext(r.Context()) + `/aas/oauth2/authorize">
+            <input type="hidden" name="client_id" value="` + html.EscapeString(clientID) + `">
+            <input type="hidden" name="redirect_uri" value="` + html.EscapeString(redirectURI) + `">
+            <input type="hidden" name="state" value="` + html.EscapeString(state) + `">
+            <input type="hidden" name="scope" value="` + html.EscapeString(scope) + `">
+            <input type="hidden" name="response_type" value="` + html.EscapeString(responseType) + `">
+            <input type="hidden" name="persona_age" value="` + html.EscapeString(hintAge(hint)) + `">
+            <input type="hidden" name="persona_gender" value="` + html.EscapeString(hint.Gender) + `">
             
             <div class="form-group">
                 <label for="phone">Номер телефона</label>
@@ -236,15 +404,17 @@ func (h *Handler) Authorize(w http.ResponseWriter, r *http.Request) {
                     placeholder="+7 (999) 123-45-67" 
                     required
                     autocomplete="tel"
+                    value="` + html.EscapeString(hintPhone) + `"
                 >
                 <input type="hidden" id="phone" name="phone">
             </div>
             
             <button type="submit">Продолжить</button>
-            
+            <button type="submit" class="secondary" name="action" value="cancel" formnovalidate>Отмена</button>
+` + outcomeSelect + `
             <div class="info">
-                🔒 Тестовая среда ЕСИА<br>
-                Введите любой номер телефона
+                ` + environmentNote(r) + `
+                Введите любой номер телефона` + hintInfo + `
             </div>
         </form>
     </div>
@@ -286,6 +456,11 @@ func (h *Handler) Authorize(w http.ResponseWriter, r *http.Request) {
         
         // При отправке формы - форматируем в "79644223811"
         form.addEventListener('submit', function(e) {
+            // Отмена входа не требует номера телефона
+            if (e.submitter && e.submitter.value === 'cancel') {
+                return true;
+            }
+
             const displayValue = phoneInput.value.replace(/\D/g, '');
             
             // Проверяем, что введено 11 цифр
@@ -307,7 +482,7 @@ func (h *Handler) Authorize(w http.ResponseWriter, r *http.Request) {
 `
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 	w.WriteHeader(http.StatusOK)
-	w.Write([]byte(html))
+	w.Write([]byte(page))
 }
 
 // AuthorizeSubmit обрабатывает отправку формы авторизации
@@ -326,41 +501,122 @@ func (h *Handler) AuthorizeSubmit(w http.ResponseWriter, r *http.Request) {
 	clientID := r.FormValue("client_id")
 	redirectURI := r.FormValue("redirect_uri")
 	state := r.FormValue("state")
-	phoneNumber := r.FormValue("phone")
+	scope := r.FormValue("scope")
+	// Телефон приводится к виду 7XXXXXXXXXX: персона и код не зависят от формата ввода
+	phoneNumber := storage.NormalizePhone(r.FormValue("phone"))
+
+	// Форма могла быть изменена: адрес возврата и политики клиента проверяются повторно,
+	// в том числе перед отменой, которая перенаправляет в redirect_uri
+	if clientID == "" || !validRedirectURI(redirectURI) {
+		http.Error(w, "invalid_request", http.StatusBadRequest)
+		return
+	}
+	if !h.allowLogin(w, r, clientID, redirectURI, scope, state) {
+		return
+	}
+
+	// Отмена входа и сценарии отказа не выдают код: RP получает ошибку в redirect_uri.
+	// Сценарии отказа есть только в тестовой среде, отмена — в любой
+	outcome := ""
+	if sandbox(r) {
+		outcome = r.FormValue("mock_outcome")
+	}
+	if r.FormValue("action") == "cancel" {
+		outcome = outcomeCancel
+	}
+	if outcome != "" {
+		h.denyLogin(w, r, clientID, redirectURI, state, outcome)
+		return
+	}
 
 	logger.Info("Authorization form submitted",
 		zap.String("client_id", clientID),
 		zap.String("phone", phoneNumber),
 	)
 
-	if clientID == "" || redirectURI == "" || phoneNumber == "" {
+	if phoneNumber == "" {
 		http.Error(w, "invalid_request", http.StatusBadRequest)
 		return
 	}
 
-	code := h.generateCode()
+	_, hint, err := parseHint(r.Form)
+	if err != nil {
+		logger.Debug("Invalid persona hint", zap.Error(err))
+		http.Error(w, "invalid_request", http.StatusBadRequest)
+		return
+	}
 
-	h.mu.Lock()
-	h.codes[code] = &AuthCode{
-		Code:        code,
+	authCode, err := h.login(r, loginRequest{
 		ClientID:    clientID,
 		RedirectURI: redirectURI,
 		State:       state,
+		Scope:       scope,
 		PhoneNumber: phoneNumber,
-		CreatedAt:   time.Now(),
+		Hint:        hint,
+	})
+	if err != nil {
+		logger.Error("Failed to issue code", zap.Error(err))
+		http.Error(w, "server_error", http.StatusInternalServerError)
+		return
 	}
-	h.userSessions[code] = phoneNumber
-	h.mu.Unlock()
 
-	redirectURL := fmt.Sprintf("%s?code=%s", redirectURI, code)
-	if state != "" {
-		redirectURL += fmt.Sprintf("&state=%s", state)
-	}
+	redirectURL := callbackURL(authCode)
 
 	logger.Info("Redirecting with code", zap.String("redirect_url", redirectURL))
 	http.Redirect(w, r, redirectURL, http.StatusFound)
 }
 
+// loginRequest вход пользователя: параметры RP и введенный телефон
+type loginRequest struct {
+	ClientID    string
+	RedirectURI string
+	State       string
+	Scope       string
+	PhoneNumber string
+	Hint        storage.Hint
+}
+
+// login выбирает персону и выдает код авторизации в профиле среды запроса. Общий
+// для формы авторизации и headless-входа через admin API
+func (h *Handler) login(r *http.Request, req loginRequest) (*oauth.AuthCode, error) {
+	// Подсказка сохраняется в коде и токене: по ним отдается вариант персоны с ограничениями
+	if !req.Hint.IsZero() {
+		userData := h.userCache.GetOrCreateWithHint(req.PhoneNumber, req.Hint)
+		logger.Info("Persona selected by hint",
+			zap.String("phone", req.PhoneNumber),
+			zap.String("hint", req.Hint.String()),
+			zap.String("oid", userData.OID))
+	}
+
+	return h.oauthFor(r).IssueCode(oauth.CodeRequest{
+		ClientID:    req.ClientID,
+		RedirectURI: req.RedirectURI,
+		State:       req.State,
+		Scope:       req.Scope,
+		PhoneNumber: req.PhoneNumber,
+		Hint:        req.Hint,
+	})
+}
+
+// validRedirectURI проверяет, что адрес возврата — абсолютный URL без фрагмента
+func validRedirectURI(uri string) bool {
+	u, err := url.Parse(uri)
+	return err == nil && u.IsAbs() && u.Fragment == ""
+}
+
+// callbackURL строит адрес возврата в RP с кодом авторизации. Собственный query адреса
+// сохраняется, code и state экранируются
+func callbackURL(code *oauth.AuthCode) string {
+	params := url.Values{}
+	params.Set("code", code.Code)
+	if code.State != "" {
+		params.Set("state", code.State)
+	}
+	// Адрес проверен validRedirectURI до выдачи кода
+	redirectURL, _ := withQuery(code.RedirectURI, params)
+	return redirectURL
+}
+
 // OAuth2 Token endpoint
 func (h *Handler) Token(w http.ResponseWriter, r *http.Request) {
 	logger.Info("Token request", zap.String("path", r.URL.Path))
@@ -395,11 +651,28 @@ func (h *Handler) Token(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	h.mu.RLock()
-	authCode, exists := h.codes[code]
-	h.mu.RUnlock()
+	violation, err := h.checkClient(clientID, "", "", grantType)
+	if err == nil && violation == nil {
+		violation, err = h.checkCertificate(r, clientID)
+	}
+	if err != nil {
+		logger.Error("Failed to check client", zap.Error(err))
+		writeJSONError(w, http.StatusInternalServerError, "server_error", err.Error())
+		return
+	}
+	if violation != nil {
+		writeJSONError(w, http.StatusBadRequest, violation.Error, violation.Description)
+		return
+	}
 
-	if !exists {
+	// Код удаляется в момент проверки: повторный или конкурентный обмен получит invalid_grant.
+	// Код, выданный в другом профиле среды, тоже недействителен
+	store := h.oauthFor(r)
+	authCode, err := store.ConsumeCode(code)
+	if err != nil {
+		if !errors.Is(err, oauth.ErrInvalidCode) {
+			logger.Error("Failed to consume code", zap.Error(err))
+		}
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusBadRequest)
 		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
@@ -413,34 +686,34 @@ func (h *Handler) Token(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	accessToken := h.generateToken()
-	refreshToken := h.generateToken()
-	idToken := h.generateIDToken()
-
-	token := &Token{
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken,
-		IDToken:      idToken,
-		ExpiresIn:    3600,
-		TokenType:    "Bearer",
-		PhoneNumber:  authCode.PhoneNumber,
-		CreatedAt:    time.Now(),
+	// RFC 6749, 4.1.3: redirect_uri должен совпадать с переданным при запросе кода
+	if authCode.RedirectURI != redirectURI {
+		logger.Info("Redirect URI mismatch",
+			zap.String("client_id", clientID),
+			zap.String("expected", authCode.RedirectURI),
+			zap.String("redirect_uri", redirectURI))
+		writeJSONError(w, http.StatusBadRequest, "invalid_grant", "redirect_uri does not match the authorization request")
+		return
 	}
 
-	h.mu.Lock()
-	h.tokens[accessToken] = token
-	delete(h.codes, code)
-	h.mu.Unlock()
+	token, err := store.IssueToken(authCode)
+	if err != nil {
+		logger.Error("Failed to issue token", zap.Error(err))
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "server_error"})
+		return
+	}
 
 	response := TokenResponse{
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken,
-		IDToken:      idToken,
-		ExpiresIn:    3600,
-		TokenType:    "Bearer",
+		AccessToken:  token.AccessToken,
+		RefreshToken: token.RefreshToken,
+		IDToken:      token.IDToken,
+		ExpiresIn:    token.ExpiresIn,
+		TokenType:    token.TokenType,
 	}
 
-	logger.Info("Token issued", zap.String("access_token", accessToken[:10]+"..."))
+	logger.Info("Token issued", zap.String("access_token", token.AccessToken[:10]+"..."))
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
@@ -450,113 +723,305 @@ func (h *Handler) Token(w http.ResponseWriter, r *http.Request) {
 func (h *Handler) UserInfo(w http.ResponseWriter, r *http.Request) {
 	logger.Info("UserInfo request", zap.String("path", r.URL.Path))
 
-	auth := r.Header.Get("Authorization")
-	if auth == "" {
-		http.Error(w, "unauthorized", http.StatusUnauthorized)
-		return
-	}
-
-	parts := strings.Split(auth, " ")
-	if len(parts) != 2 || parts[0] != "Bearer" {
-		http.Error(w, "invalid_token", http.StatusUnauthorized)
-		return
-	}
-
-	accessToken := parts[1]
-
-	h.mu.RLock()
-	token, exists := h.tokens[accessToken]
-	h.mu.RUnlock()
-
-	if !exists {
-		http.Error(w, "invalid_token", http.StatusUnauthorized)
+	token, ok := h.tokenFromRequest(w, r)
+	if !ok {
 		return
 	}
 
 	// Используем номер телефона из токена
-	phoneNumber := token.PhoneNumber
-	if phoneNumber == "" {
-		phoneNumber = "+79991234567" // дефолтный номер если не задан
-	}
+	phoneNumber := phoneFromToken(token)
 
 	// Получаем или создаем уникальные моковые данные для этого телефона
-	userData := h.userCache.GetOrCreate(phoneNumber)
+	userData := h.userCache.GetOrCreateWithHint(phoneNumber, token.Hint)
 
 	logger.Info("UserInfo data", zap.Any("userData", userData))
 
 	// Возвращаем мок данные пользователя
-	userInfo := UserInfo{
-		OID:         userData.OID,
-		FirstName:   userData.FirstName,
-		LastName:    userData.LastName,
-		MiddleName:  userData.MiddleName,
-		BirthDate:   userData.BirthDate,
-		Gender:      userData.Gender,
-		SNILS:       userData.SNILS,
-		INN:         userData.INN,
-		Email:       userData.Email,
-		Mobile:      userData.Mobile,
-		Trusted:     userData.Trusted,
-		Verified:    userData.Verified,
-		Citizenship: userData.Citizenship,
-		Status:      userData.Status,
-	}
+	userInfo := toUserInfo(userData, userData.OID, profiles.PrefixFromContext(r.Context()))
 
 	logger.Info("UserInfo response",
 		zap.String("oid", userInfo.OID),
 		zap.String("phone", phoneNumber),
-		zap.Int("cached_users", h.userCache.Count()))
+		zap.String("citizenship", userInfo.Citizenship))
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(userInfo)
 }
 
+// Persons разбирает путь REST API персон по сегментам:
+//
+//	/rs/prns/{oid}              — персона
+//	/rs/prns/{oid}/docs[/{id}]  — документы
+//	/rs/prns/{oid}/addrs[/{id}] — адреса
+func (h *Handler) Persons(w http.ResponseWriter, r *http.Request) {
+	segments := personPathSegments(r.URL.Path)
+	collection := ""
+	if n := len(segments); n == 2 || n == 3 {
+		collection = segments[1]
+	}
+
+	switch {
+	case segments[0] == "":
+		writeNotFound(w)
+	case len(segments) == 1:
+		h.GetPerson(w, r)
+	case collection == "docs":
+		h.GetPersonDocs(w, r)
+	case collection == "addrs":
+		h.GetPersonAddrs(w, r)
+	default:
+		writeNotFound(w)
+	}
+}
+
 // Person by OID endpoint
 func (h *Handler) GetPerson(w http.ResponseWriter, r *http.Request) {
 	logger.Info("GetPerson request", zap.String("path", r.URL.Path))
 
-	auth := r.Header.Get("Authorization")
-	if auth == "" {
-		http.Error(w, "unauthorized", http.StatusUnauthorized)
+	token, ok := h.tokenFromRequest(w, r)
+	if !ok {
 		return
 	}
 
-	parts := strings.Split(auth, " ")
-	if len(parts) != 2 || parts[0] != "Bearer" {
-		http.Error(w, "invalid_token", http.StatusUnauthorized)
+	// OID из пути URL, например /rs/prns/1000000001
+	pathParts := strings.Split(r.URL.Path, "/")
+	oid := pathParts[len(pathParts)-1]
+
+	userData := h.userCache.GetOrCreateWithHint(phoneFromToken(token), token.Hint)
+
+	// Используем OID из URL, но данные берем из кеша
+	userInfo := toUserInfo(userData, oid, profiles.PrefixFromContext(r.Context()))
+
+	logger.Info("GetPerson response",
+		zap.String("oid", userInfo.OID),
+		zap.String("phone", userData.Mobile),
+		zap.String("citizenship", userData.Citizenship))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(userInfo)
+}
+
+// GetPersonDocs возвращает документы пользователя:
+// /rs/prns/{oid}/docs — коллекция, /rs/prns/{oid}/docs/{id} — отдельный документ
+func (h *Handler) GetPersonDocs(w http.ResponseWriter, r *http.Request) {
+	logger.Info("GetPersonDocs request", zap.String("path", r.URL.Path))
+
+	token, ok := h.tokenFromRequest(w, r)
+	if !ok {
 		return
 	}
 
-	accessToken := parts[1]
+	oid, id, ok := parsePersonPath(r.URL.Path, "docs")
+	if !ok {
+		http.Error(w, "not_found", http.StatusNotFound)
+		return
+	}
 
-	h.mu.RLock()
-	_, exists := h.tokens[accessToken]
-	h.mu.RUnlock()
+	userData := h.userCache.GetOrCreateWithHint(phoneFromToken(token), token.Hint)
 
-	if !exists {
-		http.Error(w, "invalid_token", http.StatusUnauthorized)
+	if id != "" {
+		doc, found := userData.Document(id)
+		if !found {
+			writeNotFound(w)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(toDocumentInfo(doc))
 		return
 	}
 
-	// OID из пути URL, например /rs/prns/1000000001
-	pathParts := strings.Split(r.URL.Path, "/")
-	oid := pathParts[len(pathParts)-1]
+	elements := make([]interface{}, 0, len(userData.Documents))
+	for i := range userData.Documents {
+		if embedElements(r) {
+			elements = append(elements, toDocumentInfo(&userData.Documents[i]))
+		} else {
+			elements = append(elements, documentURL(profiles.PrefixFromContext(r.Context()), oid, userData.Documents[i].ID))
+		}
+	}
+
+	logger.Info("GetPersonDocs response",
+		zap.String("oid", oid),
+		zap.Int("size", len(elements)))
+
+	writeCollection(w, elements)
+}
+
+// GetPersonAddrs возвращает адреса пользователя:
+// /rs/prns/{oid}/addrs — коллекция, /rs/prns/{oid}/addrs/{id} — отдельный адрес
+func (h *Handler) GetPersonAddrs(w http.ResponseWriter, r *http.Request) {
+	logger.Info("GetPersonAddrs request", zap.String("path", r.URL.Path))
 
-	// Получаем номер телефона из токена
-	h.mu.RLock()
-	token, exists := h.tokens[accessToken]
-	h.mu.RUnlock()
+	token, ok := h.tokenFromRequest(w, r)
+	if !ok {
+		return
+	}
 
-	phoneNumber := "+79991234567" // дефолтный номер
-	if exists && token.PhoneNumber != "" {
-		phoneNumber = token.PhoneNumber
+	oid, id, ok := parsePersonPath(r.URL.Path, "addrs")
+	if !ok {
+		http.Error(w, "not_found", http.StatusNotFound)
+		return
 	}
 
-	// Получаем или создаем уникальные моковые данные для этого телефона
-	userData := h.userCache.GetOrCreate(phoneNumber)
+	userData := h.userCache.GetOrCreateWithHint(phoneFromToken(token), token.Hint)
+
+	if id != "" {
+		addr, found := userData.Address(id)
+		if !found {
+			writeNotFound(w)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(toAddressInfo(addr))
+		return
+	}
 
+	elements := make([]interface{}, 0, len(userData.Addresses))
+	for i := range userData.Addresses {
+		if embedElements(r) {
+			elements = append(elements, toAddressInfo(&userData.Addresses[i]))
+		} else {
+			elements = append(elements, addressURL(profiles.PrefixFromContext(r.Context()), oid, userData.Addresses[i].ID))
+		}
+	}
+
+	logger.Info("GetPersonAddrs response",
+		zap.String("oid", oid),
+		zap.Int("size", len(elements)))
+
+	writeCollection(w, elements)
+}
+
+// parsePersonPath разбирает путь вида /rs/prns/{oid}/{collection}[/{id}]
+func parsePersonPath(path, collection string) (oid, id string, ok bool) {
+	pathParts := personPathSegments(path)
+	if len(pathParts) < 2 || len(pathParts) > 3 || pathParts[1] != collection {
+		return "", "", false
+	}
+	if len(pathParts) == 3 {
+		id = pathParts[2]
+	}
+	return pathParts[0], id, true
+}
+
+// personPathSegments возвращает сегменты пути после /rs/prns/
+func personPathSegments(path string) []string {
+	return strings.Split(strings.Trim(strings.TrimPrefix(path, "/rs/prns/"), "/"), "/")
+}
+
+// embedElements проверяет ?embed=(elements): ЕСИА разворачивает элементы коллекции вместо ссылок
+func embedElements(r *http.Request) bool {
+	return strings.Contains(r.URL.Query().Get("embed"), "elements")
+}
+
+// writeCollection отвечает коллекцией в формате REST API ЕСИА
+func writeCollection(w http.ResponseWriter, elements []interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(Collection{
+		StateFacts: []string{"hasSize"},
+		Size:       len(elements),
+		Elements:   elements,
+	})
+}
+
+// writeNotFound отвечает 404 в формате JSON
+func writeNotFound(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusNotFound)
+	json.NewEncoder(w).Encode(map[string]string{"error": "not_found"})
+}
+
+// parseHint разбирает подсказки выбора персоны. login_hint содержит номер телефона
+// либо пары key=value через ';' (phone, persona_age, persona_gender);
+// отдельные параметры persona_age и persona_gender имеют приоритет над login_hint
+func parseHint(values url.Values) (string, storage.Hint, error) {
+	var phone string
+	var hint storage.Hint
+	params := map[string]string{}
+
+	for _, part := range strings.Split(values.Get("login_hint"), ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		key, value, found := strings.Cut(part, "=")
+		if !found {
+			key, value = "phone", part
+		}
+		params[strings.TrimSpace(key)] = value
+	}
+	for _, key := range []string{"persona_age", "persona_gender"} {
+		if v := values.Get(key); v != "" {
+			params[key] = v
+		}
+	}
+
+	if v, ok := params["phone"]; ok {
+		phone = storage.NormalizePhone(v)
+	}
+	if v, ok := params["persona_age"]; ok {
+		age, err := storage.ParseAge(v)
+		if err != nil {
+			return "", hint, err
+		}
+		hint.Age = age
+	}
+	if v, ok := params["persona_gender"]; ok {
+		gender, err := storage.ParseGender(v)
+		if err != nil {
+			return "", hint, err
+		}
+		hint.Gender = gender
+	}
+
+	return phone, hint, nil
+}
+
+// hintAge возвращает возраст из подсказки для подстановки в форму
+func hintAge(hint storage.Hint) string {
+	if hint.Age == 0 {
+		return ""
+	}
+	return strconv.Itoa(hint.Age)
+}
+
+// tokenFromRequest проверяет Bearer токен в профиле среды запроса; при ошибке сам отвечает 401
+func (h *Handler) tokenFromRequest(w http.ResponseWriter, r *http.Request) (*oauth.Token, bool) {
+	auth := r.Header.Get("Authorization")
+	if auth == "" {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return nil, false
+	}
+
+	parts := strings.Split(auth, " ")
+	if len(parts) != 2 || parts[0] != "Bearer" {
+		http.Error(w, "invalid_token", http.StatusUnauthorized)
+		return nil, false
+	}
+
+	token, err := h.oauthFor(r).LookupToken(parts[1])
+	if err != nil {
+		if !errors.Is(err, oauth.ErrInvalidToken) {
+			logger.Error("Failed to load token", zap.Error(err))
+		}
+		http.Error(w, "invalid_token", http.StatusUnauthorized)
+		return nil, false
+	}
+
+	return token, true
+}
+
+// phoneFromToken возвращает номер телефона из токена или дефолтный номер
+func phoneFromToken(token *oauth.Token) string {
+	if token.PhoneNumber == "" {
+		return "+79991234567"
+	}
+	return token.PhoneNumber
+}
+
+// toUserInfo собирает ответ с данными пользователя
+func toUserInfo(userData *storage.UserData, oid, prefix string) UserInfo {
 	userInfo := UserInfo{
-		OID:         oid, // Используем OID из URL, но данные берем из кеша
+		OID:         oid,
 		FirstName:   userData.FirstName,
 		LastName:    userData.LastName,
 		MiddleName:  userData.MiddleName,
@@ -572,30 +1037,65 @@ func (h *Handler) GetPerson(w http.ResponseWriter, r *http.Request) {
 		Status:      userData.Status,
 	}
 
-	logger.Info("GetPerson response",
-		zap.String("oid", userInfo.OID),
-		zap.String("phone", phoneNumber))
+	for _, doc := range userData.Documents {
+		userInfo.Documents = append(userInfo.Documents, documentURL(prefix, oid, doc.ID))
+	}
+	if len(userData.Documents) > 0 {
+		// rIdDoc — основной документ, удостоверяющий личность
+		userInfo.RIdDoc = userData.Documents[0].ID
+	}
+	for _, addr := range userData.Addresses {
+		userInfo.Addresses = append(userInfo.Addresses, addressURL(prefix, oid, addr.ID))
+	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(userInfo)
+	return userInfo
 }
 
-func (h *Handler) generateCode() string {
-	b := make([]byte, 32)
-	rand.Read(b)
-	return base64.URLEncoding.EncodeToString(b)
+// toDocumentInfo собирает ответ с данными документа
+func toDocumentInfo(doc *storage.Document) DocumentInfo {
+	vrfStu := "NOT_VERIFIED"
+	if doc.Verified {
+		vrfStu = "VERIFIED"
+	}
+
+	return DocumentInfo{
+		StateFacts:   []string{"EntityRoot"},
+		ID:           doc.ID,
+		Type:         doc.Type,
+		VrfStu:       vrfStu,
+		Series:       doc.Series,
+		Number:       doc.Number,
+		IssueDate:    doc.IssueDate,
+		IssueID:      doc.IssueID,
+		IssuedBy:     doc.IssuedBy,
+		ExpiryDate:   doc.ExpiryDate,
+		IssueCountry: doc.IssueCountry,
+	}
+}
+
+// toAddressInfo собирает ответ с данными адреса
+func toAddressInfo(addr *storage.Address) AddressInfo {
+	return AddressInfo{
+		StateFacts: []string{"Identifiable"},
+		ID:         addr.ID,
+		Type:       addr.Type,
+		AddressStr: addr.String(),
+		CountryID:  storage.CitizenshipRUS,
+		ZipCode:    addr.ZipCode,
+		Region:     addr.Region,
+		City:       addr.City,
+		Street:     addr.Street,
+		House:      addr.House,
+		Flat:       addr.Flat,
+	}
 }
 
-func (h *Handler) generateToken() string {
-	b := make([]byte, 32)
-	rand.Read(b)
-	return base64.URLEncoding.EncodeToString(b)
+// addressURL ссылка на адрес в REST API; prefix — префикс профиля среды запроса
+func addressURL(prefix, oid, addrID string) string {
+	return fmt.Sprintf("%s/rs/prns/%s/addrs/%s", prefix, oid, addrID)
 }
 
-func (h *Handler) generateIDToken() string {
-	// Простой мок JWT токена
-	header := base64.URLEncoding.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`))
-	payload := base64.URLEncoding.EncodeToString([]byte(`{"sub":"1000000001","aud":"mock","iat":` + fmt.Sprintf("%d", time.Now().Unix()) + `,"exp":` + fmt.Sprintf("%d", time.Now().Add(time.Hour).Unix()) + `}`))
-	signature := base64.URLEncoding.EncodeToString([]byte("mock_signature"))
-	return fmt.Sprintf("%s.%s.%s", header, payload, signature)
+// documentURL ссылка на документ в REST API
+func documentURL(prefix, oid, docID string) string {
+	return fmt.Sprintf("%s/rs/prns/%s/docs/%s", prefix, oid, docID)
 }
@@ -0,0 +1,264 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/vibe-gaming/esia-mock/internal/clients"
+	"github.com/vibe-gaming/esia-mock/internal/logger"
+	"github.com/vibe-gaming/esia-mock/internal/oauth"
+	"github.com/vibe-gaming/esia-mock/internal/storage"
+)
+
+func TestMain(m *testing.M) {
+	logger.Init("error")
+	os.Exit(m.Run())
+}
+
+// newTestHandler создает обработчик с хранилищем в памяти
+func newTestHandler(t *testing.T) *Handler {
+	t.Helper()
+	backend := storage.NewMemoryBackend()
+	cache, err := storage.New(storage.Options{Backend: backend})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return New(cache, oauth.NewStore(backend, 0, 0), clients.NewRegistry(backend))
+}
+
+// postForm отправляет форму в обработчик
+func postForm(handler http.HandlerFunc, path string, form url.Values) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	return rec
+}
+
+// submitLogin отправляет форму входа и возвращает адрес перенаправления в RP
+func submitLogin(t *testing.T, h *Handler, form url.Values) *url.URL {
+	t.Helper()
+	rec := postForm(h.AuthorizeSubmit, "/aas/oauth2/authorize", form)
+	if rec.Code != http.StatusFound {
+		t.Fatalf("authorize: status %d, body %s", rec.Code, rec.Body)
+	}
+	location, err := url.Parse(rec.Header().Get("Location"))
+	if err != nil {
+		t.Fatalf("Location: %v", err)
+	}
+	return location
+}
+
+// exchangeCode обменивает код клиента rp с адресом возврата http://rp/cb на токен
+func exchangeCode(t *testing.T, h *Handler, code string) TokenResponse {
+	t.Helper()
+	rec := postForm(h.Token, "/aas/oauth2/te", url.Values{
+		"grant_type": {"authorization_code"}, "client_id": {"rp"},
+		"code": {code}, "redirect_uri": {"http://rp/cb"},
+	})
+	var token TokenResponse
+	if err := json.NewDecoder(rec.Body).Decode(&token); err != nil || token.AccessToken == "" {
+		t.Fatalf("token: status %d, %v", rec.Code, err)
+	}
+	return token
+}
+
+// getUserInfo запрашивает /userinfo с токеном
+func getUserInfo(t *testing.T, h *Handler, accessToken string) UserInfo {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/userinfo", nil)
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	rec := httptest.NewRecorder()
+	h.UserInfo(rec, req)
+	var info UserInfo
+	if err := json.NewDecoder(rec.Body).Decode(&info); err != nil {
+		t.Fatalf("userinfo: status %d, %v", rec.Code, err)
+	}
+	return info
+}
+
+// decodeError читает JSON-ошибку OAuth2
+func decodeError(t *testing.T, rec *httptest.ResponseRecorder) string {
+	t.Helper()
+	var body struct {
+		Error string `json:"error"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("decode error body: %v", err)
+	}
+	return body.Error
+}
+
+func TestCallbackURL(t *testing.T) {
+	tests := []struct {
+		name        string
+		redirectURI string
+		state       string
+		want        url.Values
+	}{
+		{"plain", "http://rp.example.ru/cb", "xyz", url.Values{"code": {"c0de"}, "state": {"xyz"}}},
+		{"own query", "http://rp.example.ru/cb?tenant=1&lang=ru", "xyz",
+			url.Values{"tenant": {"1"}, "lang": {"ru"}, "code": {"c0de"}, "state": {"xyz"}}},
+		{"special state", "http://rp.example.ru/cb?tenant=1", `a&b=c#d "e" f`,
+			url.Values{"tenant": {"1"}, "code": {"c0de"}, "state": {`a&b=c#d "e" f`}}},
+		{"no state", "http://rp.example.ru/cb", "", url.Values{"code": {"c0de"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw := callbackURL(&oauth.AuthCode{Code: "c0de", RedirectURI: tt.redirectURI, State: tt.state})
+			u, err := url.Parse(raw)
+			if err != nil {
+				t.Fatalf("parse %q: %v", raw, err)
+			}
+			if u.Fragment != "" || u.Host != "rp.example.ru" || u.Path != "/cb" {
+				t.Errorf("callback = %q", raw)
+			}
+			if got := u.Query(); got.Encode() != tt.want.Encode() {
+				t.Errorf("query = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithQuery(t *testing.T) {
+	tests := []struct {
+		name   string
+		raw    string
+		params url.Values
+		want   string
+	}{
+		{"empty query", "http://rp/cb", url.Values{"error": {"access_denied"}}, "http://rp/cb?error=access_denied"},
+		{"keeps query", "http://rp/cb?tenant=1", url.Values{"code": {"x"}}, "http://rp/cb?code=x&tenant=1"},
+		{"replaces param", "http://rp/cb?state=old", url.Values{"state": {"new"}}, "http://rp/cb?state=new"},
+		{"escapes", "http://rp/cb", url.Values{"state": {`a&b#c "d"`}}, "http://rp/cb?state=a%26b%23c+%22d%22"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := withQuery(tt.raw, tt.params)
+			if err != nil || got != tt.want {
+				t.Errorf("withQuery = %q, %v; want %q", got, err, tt.want)
+			}
+		})
+	}
+
+	if _, err := withQuery("http://rp/%zz", nil); err == nil {
+		t.Error("expected error for invalid URL")
+	}
+}
+
+func TestAuthorizeEscapesParams(t *testing.T) {
+	h := newTestHandler(t)
+	state := `x"><script>alert(1)</script>`
+
+	query := url.Values{"client_id": {"rp"}, "redirect_uri": {"http://rp/cb?a=1&b=2"}, "state": {state}}
+	rec := httptest.NewRecorder()
+	h.Authorize(rec, httptest.NewRequest(http.MethodGet, "/aas/oauth2/ac?"+query.Encode(), nil))
+
+	body := rec.Body.String()
+	if rec.Code != http.StatusOK || strings.Contains(body, "<script>alert") {
+		t.Fatalf("status %d, state is not escaped:\n%s", rec.Code, body)
+	}
+	if !strings.Contains(body, `name="state" value="x&#34;&gt;&lt;script&gt;alert(1)&lt;/script&gt;"`) ||
+		!strings.Contains(body, `name="redirect_uri" value="http://rp/cb?a=1&amp;b=2"`) {
+		t.Errorf("hidden inputs are not escaped:\n%s", body)
+	}
+
+	// Браузер возвращает исходные значения: state доходит до RP целиком
+	location := submitLogin(t, h, url.Values{
+		"client_id": {"rp"}, "redirect_uri": {"http://rp/cb?a=1&b=2"}, "state": {state}, "phone": {"79991234567"},
+	})
+	if got := location.Query().Get("state"); got != state {
+		t.Errorf("state = %q, want %q", got, state)
+	}
+}
+
+func TestTokenRedirectURIMismatch(t *testing.T) {
+	h := newTestHandler(t)
+	location := submitLogin(t, h, url.Values{
+		"client_id": {"rp"}, "redirect_uri": {"http://rp/cb"}, "phone": {"79991234567"},
+	})
+
+	tests := []struct {
+		name        string
+		redirectURI string
+	}{
+		{"other uri", "http://rp/other"},
+		{"missing uri", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Каждый обмен тратит код, поэтому у каждого случая свой код
+			location := submitLogin(t, h, url.Values{
+				"client_id": {"rp"}, "redirect_uri": {"http://rp/cb"}, "phone": {"79991234567"},
+			})
+			rec := postForm(h.Token, "/aas/oauth2/te", url.Values{
+				"grant_type": {"authorization_code"}, "client_id": {"rp"},
+				"code": {location.Query().Get("code")}, "redirect_uri": {tt.redirectURI},
+			})
+			if rec.Code != http.StatusBadRequest || decodeError(t, rec) != "invalid_grant" {
+				t.Errorf("status %d, want 400 invalid_grant", rec.Code)
+			}
+		})
+	}
+
+	rec := postForm(h.Token, "/aas/oauth2/te", url.Values{
+		"grant_type": {"authorization_code"}, "client_id": {"rp"},
+		"code": {location.Query().Get("code")}, "redirect_uri": {"http://rp/cb"},
+	})
+	if rec.Code != http.StatusOK {
+		t.Errorf("matching redirect_uri: status %d, body %s", rec.Code, rec.Body)
+	}
+}
+
+func TestHintedPersonaKeepsPhonePersona(t *testing.T) {
+	h := newTestHandler(t)
+	login := func(extra url.Values) UserInfo {
+		form := url.Values{"client_id": {"rp"}, "redirect_uri": {"http://rp/cb"}, "phone": {"79991234567"}}
+		for key, values := range extra {
+			form[key] = values
+		}
+		location := submitLogin(t, h, form)
+		return getUserInfo(t, h, exchangeCode(t, h, location.Query().Get("code")).AccessToken)
+	}
+
+	plain := login(nil)
+	hinted := login(url.Values{"persona_age": {"7"}})
+	if hinted.BirthDate == plain.BirthDate {
+		t.Fatalf("hint ignored: birth date %s", hinted.BirthDate)
+	}
+	if hinted.OID != plain.OID {
+		t.Errorf("hinted OID = %s, want %s", hinted.OID, plain.OID)
+	}
+
+	// Вход без подсказки по тому же телефону возвращает прежнюю персону
+	if again := login(nil); again.BirthDate != plain.BirthDate || again.FirstName != plain.FirstName {
+		t.Errorf("phone persona = %s %s, want %s %s", again.FirstName, again.BirthDate, plain.FirstName, plain.BirthDate)
+	}
+}
+
+func TestAuthorizeSubmitNormalizesPhone(t *testing.T) {
+	h := newTestHandler(t)
+	var oids []string
+	for _, phone := range []string{"+7 (999) 123-45-67", "79991234567"} {
+		location := submitLogin(t, h, url.Values{
+			"client_id": {"rp"}, "redirect_uri": {"http://rp/cb"}, "phone": {phone},
+		})
+		code, err := h.oauth.LookupCode(location.Query().Get("code"))
+		if err != nil || code.PhoneNumber != "79991234567" {
+			t.Fatalf("%s: code phone = %+v, %v; want 79991234567", phone, code, err)
+		}
+		oids = append(oids, getUserInfo(t, h, exchangeCode(t, h, code.Code).AccessToken).OID)
+	}
+
+	if oids[0] != oids[1] {
+		t.Errorf("OIDs = %v, want one persona for both formats", oids)
+	}
+	if users := h.userCache.GetAll(); len(users) != 1 || users["79991234567"] == nil {
+		t.Errorf("stored personas = %v, want only 79991234567", users)
+	}
+}
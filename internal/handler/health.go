@@ -0,0 +1,47 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/vibe-gaming/esia-mock/internal/logger"
+	"go.uber.org/zap"
+)
+
+// HealthStatus ответ проверок состояния
+type HealthStatus struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// SetReady отмечает готовность принимать запросы: true после запуска сервера,
+// false в начале остановки, чтобы балансировщик перестал направлять запросы
+func (h *Handler) SetReady(ready bool) {
+	h.ready.Store(ready)
+}
+
+// SetReadyCheck задает проверку зависимостей для /readyz, например доступности хранилища
+func (h *Handler) SetReadyCheck(check func() error) {
+	h.readyCheck = check
+}
+
+// Healthz проверка живости (GET /healthz): процесс отвечает на запросы
+func (h *Handler) Healthz(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, HealthStatus{Status: "ok"})
+}
+
+// Readyz проверка готовности (GET /readyz): сервер запущен, не останавливается
+// и хранилище доступно. Иначе — 503
+func (h *Handler) Readyz(w http.ResponseWriter, r *http.Request) {
+	if !h.ready.Load() {
+		writeJSON(w, http.StatusServiceUnavailable, HealthStatus{Status: "unavailable", Error: "server is not serving"})
+		return
+	}
+	if h.readyCheck != nil {
+		if err := h.readyCheck(); err != nil {
+			logger.Error("Readiness check failed", zap.Error(err))
+			writeJSON(w, http.StatusServiceUnavailable, HealthStatus{Status: "unavailable", Error: err.Error()})
+			return
+		}
+	}
+	writeJSON(w, http.StatusOK, HealthStatus{Status: "ok"})
+}
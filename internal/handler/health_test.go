@@ -0,0 +1,52 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestReadyz(t *testing.T) {
+	h := newTestHandler(t)
+	var checkErr error
+	h.SetReadyCheck(func() error { return checkErr })
+
+	readyz := func() (int, HealthStatus) {
+		rec := httptest.NewRecorder()
+		h.Readyz(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		var status HealthStatus
+		if err := json.NewDecoder(rec.Body).Decode(&status); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		return rec.Code, status
+	}
+
+	if code, status := readyz(); code != http.StatusServiceUnavailable || status.Status != "unavailable" {
+		t.Errorf("before SetReady: %d %+v, want 503", code, status)
+	}
+
+	h.SetReady(true)
+	if code, status := readyz(); code != http.StatusOK || status.Status != "ok" {
+		t.Errorf("ready: %d %+v, want 200", code, status)
+	}
+
+	checkErr = errors.New("redis: connection refused")
+	if code, status := readyz(); code != http.StatusServiceUnavailable || status.Error != checkErr.Error() {
+		t.Errorf("failed check: %d %+v, want 503 with the check error", code, status)
+	}
+	checkErr = nil
+
+	// Остановка: балансировщик должен перестать направлять запросы
+	h.SetReady(false)
+	if code, _ := readyz(); code != http.StatusServiceUnavailable {
+		t.Errorf("after SetReady(false): %d, want 503", code)
+	}
+
+	rec := httptest.NewRecorder()
+	h.Healthz(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("healthz while not ready: %d, want 200", rec.Code)
+	}
+}
@@ -0,0 +1,189 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/vibe-gaming/esia-mock/internal/clients"
+	"github.com/vibe-gaming/esia-mock/internal/logger"
+	"github.com/vibe-gaming/esia-mock/internal/storage"
+	"go.uber.org/zap"
+)
+
+// loginParams параметры headless-входа: те же, что у формы авторизации
+var loginParams = []string{"client_id", "redirect_uri", "scope", "state", "phone", "login_hint", "persona_age", "persona_gender"}
+
+// LoginResponse результат headless-входа: код и адрес возврата в RP
+type LoginResponse struct {
+	Code        string    `json:"code"`
+	RedirectURL string    `json:"redirectUrl"`
+	State       string    `json:"state,omitempty"`
+	ExpiresAt   time.Time `json:"expiresAt"`
+	OID         string    `json:"oid"`
+}
+
+// LoginTokenResponse результат headless-входа с выдачей токена
+type LoginTokenResponse struct {
+	TokenResponse
+	OID string `json:"oid"`
+}
+
+// Login выполняет вход без браузера (POST /admin/login): принимает client_id, redirect_uri,
+// scope, state и phone (JSON или форма) и возвращает код авторизации и полный адрес возврата.
+// Код обменивается на токен через /aas/oauth2/te как обычно
+func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
+	req, ok := h.loginRequest(w, r)
+	if !ok {
+		return
+	}
+
+	authCode, err := h.login(r, req)
+	if err != nil {
+		logger.Error("Failed to issue code", zap.Error(err))
+		writeJSONError(w, http.StatusInternalServerError, "server_error", err.Error())
+		return
+	}
+
+	logger.Info("Headless login",
+		zap.String("client_id", req.ClientID),
+		zap.String("phone", req.PhoneNumber))
+
+	writeJSON(w, http.StatusOK, LoginResponse{
+		Code:        authCode.Code,
+		RedirectURL: callbackURL(authCode),
+		State:       authCode.State,
+		ExpiresAt:   authCode.ExpiresAt,
+		OID:         h.userCache.GetOrCreateWithHint(req.PhoneNumber, req.Hint).OID,
+	})
+}
+
+// LoginToken выполняет вход без браузера и сразу обменивает код на токен
+// (POST /admin/login/token). Ответ совпадает с ответом /aas/oauth2/te
+func (h *Handler) LoginToken(w http.ResponseWriter, r *http.Request) {
+	req, ok := h.loginRequest(w, r)
+	if !ok {
+		return
+	}
+
+	store := h.oauthFor(r)
+	authCode, err := h.login(r, req)
+	if err == nil {
+		authCode, err = store.ConsumeCode(authCode.Code)
+	}
+	if err != nil {
+		logger.Error("Failed to issue code", zap.Error(err))
+		writeJSONError(w, http.StatusInternalServerError, "server_error", err.Error())
+		return
+	}
+
+	token, err := store.IssueToken(authCode)
+	if err != nil {
+		logger.Error("Failed to issue token", zap.Error(err))
+		writeJSONError(w, http.StatusInternalServerError, "server_error", err.Error())
+		return
+	}
+
+	logger.Info("Headless login with token",
+		zap.String("client_id", req.ClientID),
+		zap.String("phone", req.PhoneNumber))
+
+	writeJSON(w, http.StatusOK, LoginTokenResponse{
+		TokenResponse: TokenResponse{
+			AccessToken:  token.AccessToken,
+			RefreshToken: token.RefreshToken,
+			IDToken:      token.IDToken,
+			ExpiresIn:    token.ExpiresIn,
+			TokenType:    token.TokenType,
+		},
+		OID: h.userCache.GetOrCreateWithHint(req.PhoneNumber, req.Hint).OID,
+	})
+}
+
+// loginRequest читает параметры headless-входа; при ошибке сам отвечает 400
+func (h *Handler) loginRequest(w http.ResponseWriter, r *http.Request) (loginRequest, bool) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return loginRequest{}, false
+	}
+
+	values, err := readLoginParams(r)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid_request", err.Error())
+		return loginRequest{}, false
+	}
+
+	phone, hint, err := parseHint(values)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid_request", err.Error())
+		return loginRequest{}, false
+	}
+	if v := values.Get("phone"); v != "" {
+		phone = storage.NormalizePhone(v)
+	}
+
+	req := loginRequest{
+		ClientID:    values.Get("client_id"),
+		RedirectURI: values.Get("redirect_uri"),
+		State:       values.Get("state"),
+		Scope:       values.Get("scope"),
+		PhoneNumber: phone,
+		Hint:        hint,
+	}
+	if req.ClientID == "" || req.RedirectURI == "" || req.PhoneNumber == "" {
+		writeJSONError(w, http.StatusBadRequest, "invalid_request", "client_id, redirect_uri and phone are required")
+		return loginRequest{}, false
+	}
+	if !validRedirectURI(req.RedirectURI) {
+		writeJSONError(w, http.StatusBadRequest, "invalid_request", "redirect_uri must be an absolute URL without a fragment")
+		return loginRequest{}, false
+	}
+
+	violation, err := h.checkClient(req.ClientID, req.RedirectURI, req.Scope, clients.GrantAuthorizationCode)
+	if err != nil {
+		logger.Error("Failed to check client", zap.Error(err))
+		writeJSONError(w, http.StatusInternalServerError, "server_error", err.Error())
+		return loginRequest{}, false
+	}
+	if violation != nil {
+		writeJSONError(w, http.StatusBadRequest, violation.Error, violation.Description)
+		return loginRequest{}, false
+	}
+	return req, true
+}
+
+// readLoginParams читает параметры из JSON-объекта или формы. Формат определяется по телу,
+// а не по Content-Type: curl -d отправляет JSON с типом формы
+func readLoginParams(r *http.Request) (url.Values, error) {
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		return url.ParseQuery(string(data))
+	}
+
+	// UseNumber сохраняет номер телефона, переданный числом, без экспоненты
+	var body map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&body); err != nil {
+		return nil, fmt.Errorf("invalid JSON body: %w", err)
+	}
+
+	values := url.Values{}
+	for _, key := range loginParams {
+		if v, ok := body[key]; ok && v != nil {
+			values.Set(key, fmt.Sprint(v))
+			delete(body, key)
+		}
+	}
+	for key := range body {
+		return nil, fmt.Errorf("unknown parameter %q", key)
+	}
+	return values, nil
+}
@@ -0,0 +1,104 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// postLogin отправляет JSON в обработчик headless-входа
+func postLogin(handler http.HandlerFunc, path, body string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)))
+	return rec
+}
+
+// getPerson запрашивает /rs/prns/{oid} с токеном
+func getPerson(t *testing.T, h *Handler, oid, accessToken string) UserInfo {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/rs/prns/"+oid, nil)
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	rec := httptest.NewRecorder()
+	h.Persons(rec, req)
+	var person UserInfo
+	if rec.Code != http.StatusOK {
+		t.Fatalf("person: status %d, body %s", rec.Code, rec.Body)
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&person); err != nil {
+		t.Fatalf("person: %v", err)
+	}
+	return person
+}
+
+func TestHeadlessLogin(t *testing.T) {
+	h := newTestHandler(t)
+	rec := postLogin(h.Login, "/admin/login",
+		`{"client_id":"rp","redirect_uri":"http://rp/cb","state":"s1","phone":"+7 999 123-45-67"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("login: status %d, body %s", rec.Code, rec.Body)
+	}
+	var login LoginResponse
+	if err := json.NewDecoder(rec.Body).Decode(&login); err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	location, err := url.Parse(login.RedirectURL)
+	if err != nil || location.Query().Get("code") != login.Code || location.Query().Get("state") != "s1" {
+		t.Fatalf("redirectUrl = %q, code %q", login.RedirectURL, login.Code)
+	}
+
+	// Код обменивается как после формы, токен открывает персону с выданным OID
+	token := exchangeCode(t, h, login.Code)
+	person := getPerson(t, h, login.OID, token.AccessToken)
+	if person.OID != login.OID || person.Mobile != "79991234567" {
+		t.Errorf("person = %s %s, want %s for 79991234567", person.OID, person.Mobile, login.OID)
+	}
+	if info := getUserInfo(t, h, token.AccessToken); info.OID != login.OID {
+		t.Errorf("userinfo OID = %s, want %s", info.OID, login.OID)
+	}
+}
+
+func TestHeadlessLoginToken(t *testing.T) {
+	h := newTestHandler(t)
+	rec := postLogin(h.LoginToken, "/admin/login/token", url.Values{
+		"client_id": {"rp"}, "redirect_uri": {"http://rp/cb"},
+		"login_hint": {"phone=79991234567;persona_gender=F"},
+	}.Encode())
+	if rec.Code != http.StatusOK {
+		t.Fatalf("login: status %d, body %s", rec.Code, rec.Body)
+	}
+	var login LoginTokenResponse
+	if err := json.NewDecoder(rec.Body).Decode(&login); err != nil || login.AccessToken == "" || login.OID == "" {
+		t.Fatalf("login: %+v, %v", login, err)
+	}
+
+	person := getPerson(t, h, login.OID, login.AccessToken)
+	if person.OID != login.OID || person.Gender != "F" {
+		t.Errorf("person = %s/%s, want %s/F", person.OID, person.Gender, login.OID)
+	}
+}
+
+func TestHeadlessLoginInvalid(t *testing.T) {
+	h := newTestHandler(t)
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"no phone", `{"client_id":"rp","redirect_uri":"http://rp/cb"}`},
+		{"relative redirect", `{"client_id":"rp","redirect_uri":"/cb","phone":"79991234567"}`},
+		{"unknown parameter", `{"client_id":"rp","redirect_uri":"http://rp/cb","phone":"79991234567","code":"x"}`},
+		{"invalid hint", `{"client_id":"rp","redirect_uri":"http://rp/cb","phone":"79991234567","persona_age":"old"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for path, handler := range map[string]http.HandlerFunc{"/admin/login": h.Login, "/admin/login/token": h.LoginToken} {
+				rec := postLogin(handler, path, tt.body)
+				if rec.Code != http.StatusBadRequest || decodeError(t, rec) != "invalid_request" {
+					t.Errorf("%s: status %d, want 400 invalid_request", path, rec.Code)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/vibe-gaming/esia-mock/internal/logger"
+	"github.com/vibe-gaming/esia-mock/internal/maintenance"
+	"go.uber.org/zap"
+)
+
+// Maintenance admin API режима технических работ:
+//
+//	GET    /admin/maintenance  — настройки и недоступные сейчас группы эндпоинтов
+//	PUT    /admin/maintenance  — замена настроек: ручное включение, группы, окна по расписанию
+//	DELETE /admin/maintenance  — выключение ручного режима; окна по расписанию сохраняются
+func (h *Handler) Maintenance(w http.ResponseWriter, r *http.Request) {
+	if h.maintenance == nil {
+		writeNotFound(w)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, h.maintenance.Status())
+
+	case http.MethodPut:
+		var settings maintenance.Settings
+		if !decodeJSONBody(w, r, &settings) {
+			return
+		}
+		if err := h.maintenance.Set(settings); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "invalid_request", err.Error())
+			return
+		}
+
+		status := h.maintenance.Status()
+		logger.Info("Maintenance mode updated",
+			zap.Bool("enabled", settings.Enabled),
+			zap.Int("windows", len(settings.Windows)),
+			zap.Strings("active", status.Active))
+		writeJSON(w, http.StatusOK, status)
+
+	case http.MethodDelete:
+		settings := h.maintenance.Settings()
+		settings.Enabled = false
+		settings.Until = nil
+		if err := h.maintenance.Set(settings); err != nil {
+			writeJSONError(w, http.StatusInternalServerError, "server_error", err.Error())
+			return
+		}
+
+		logger.Info("Maintenance mode disabled")
+		writeJSON(w, http.StatusOK, h.maintenance.Status())
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"html"
+	"net/http"
+	"net/url"
+
+	"github.com/vibe-gaming/esia-mock/internal/logger"
+	"go.uber.org/zap"
+)
+
+// outcomeCancel пользователь нажал «Отмена» на форме входа
+const outcomeCancel = "cancel"
+
+// loginOutcome исход входа, при котором RP получает ошибку вместо кода
+type loginOutcome struct {
+	ID          string
+	Title       string // подпись в форме; пусто — не показывается в списке сценариев
+	Error       string // код ошибки OAuth2
+	Description string // описание ошибки в формате ЕСИА
+}
+
+// loginOutcomes исходы входа: отмена и сценарии, которые в тестовой среде выбираются на форме
+var loginOutcomes = []loginOutcome{
+	{
+		ID:          outcomeCancel,
+		Error:       "access_denied",
+		Description: "ESIA-007004: Владелец ресурса отказал в доступе к ресурсу",
+	},
+	{
+		ID:          "session_expired",
+		Title:       "Сессия истекла",
+		Error:       "access_denied",
+		Description: "ESIA-007012: Время сессии пользователя истекло",
+	},
+	{
+		ID:          "too_many_attempts",
+		Title:       "Превышено число попыток входа",
+		Error:       "access_denied",
+		Description: "ESIA-007011: Превышено допустимое число попыток входа",
+	},
+	{
+		ID:          "unavailable",
+		Title:       "Сервис авторизации недоступен",
+		Error:       "temporarily_unavailable",
+		Description: "ESIA-007008: Сервис авторизации в настоящее время не может обработать запрос",
+	},
+}
+
+// findOutcome ищет исход входа по идентификатору
+func findOutcome(id string) (loginOutcome, bool) {
+	for _, o := range loginOutcomes {
+		if o.ID == id {
+			return o, true
+		}
+	}
+	return loginOutcome{}, false
+}
+
+// outcomeOptions варианты сценариев для выпадающего списка формы входа
+func outcomeOptions() string {
+	var options string
+	for _, o := range loginOutcomes {
+		if o.Title != "" {
+			options += `
+                    <option value="` + o.ID + `">` + html.EscapeString(o.Title) + `</option>`
+		}
+	}
+	return options
+}
+
+// denyLogin возвращает пользователя в RP с ошибкой вместо кода авторизации; state сохраняется
+func (h *Handler) denyLogin(w http.ResponseWriter, r *http.Request, clientID, redirectURI, state, outcomeID string) {
+	outcome, ok := findOutcome(outcomeID)
+	if !ok || clientID == "" || redirectURI == "" {
+		http.Error(w, "invalid_request", http.StatusBadRequest)
+		return
+	}
+
+	params := url.Values{}
+	params.Set("error", outcome.Error)
+	params.Set("error_description", outcome.Description)
+	if state != "" {
+		params.Set("state", state)
+	}
+
+	redirectURL, err := withQuery(redirectURI, params)
+	if err != nil {
+		http.Error(w, "invalid_request", http.StatusBadRequest)
+		return
+	}
+
+	logger.Info("Login denied",
+		zap.String("client_id", clientID),
+		zap.String("outcome", outcome.ID),
+		zap.String("redirect_url", redirectURL))
+	http.Redirect(w, r, redirectURL, http.StatusFound)
+}
+
+// withQuery добавляет параметры к адресу, сохраняя его собственный query
+func withQuery(rawURL string, params url.Values) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	query := u.Query()
+	for key, values := range params {
+		query[key] = values
+	}
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
@@ -0,0 +1,121 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/vibe-gaming/esia-mock/internal/profiles"
+)
+
+// submitOutcome отправляет форму входа через профили и возвращает ответ
+func submitOutcome(t *testing.T, h *Handler, path string, form url.Values) *httptest.ResponseRecorder {
+	t.Helper()
+	set, err := profiles.NewSet(nil, profiles.Test)
+	if err != nil {
+		t.Fatalf("NewSet: %v", err)
+	}
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	set.Middleware(http.HandlerFunc(h.AuthorizeSubmit)).ServeHTTP(rec, req)
+	return rec
+}
+
+func TestLoginOutcomes(t *testing.T) {
+	tests := []struct {
+		name        string
+		form        url.Values
+		error       string
+		description string
+	}{
+		{"cancel", url.Values{"action": {"cancel"}},
+			"access_denied", "ESIA-007004: Владелец ресурса отказал в доступе к ресурсу"},
+		{"session expired", url.Values{"mock_outcome": {"session_expired"}},
+			"access_denied", "ESIA-007012: Время сессии пользователя истекло"},
+		{"too many attempts", url.Values{"mock_outcome": {"too_many_attempts"}},
+			"access_denied", "ESIA-007011: Превышено допустимое число попыток входа"},
+		{"unavailable", url.Values{"mock_outcome": {"unavailable"}},
+			"temporarily_unavailable", "ESIA-007008: Сервис авторизации в настоящее время не может обработать запрос"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestHandler(t)
+			form := url.Values{
+				"client_id": {"rp"}, "redirect_uri": {"http://rp/cb?tenant=1"},
+				"state": {"st&ate"}, "phone": {"79991234567"},
+			}
+			for key, values := range tt.form {
+				form[key] = values
+			}
+
+			rec := submitOutcome(t, h, "/test/aas/oauth2/ac", form)
+			if rec.Code != http.StatusFound {
+				t.Fatalf("status %d, body %s", rec.Code, rec.Body)
+			}
+			location, err := url.Parse(rec.Header().Get("Location"))
+			if err != nil {
+				t.Fatalf("Location: %v", err)
+			}
+			query := location.Query()
+			if location.Host != "rp" || location.Path != "/cb" || query.Get("tenant") != "1" {
+				t.Errorf("redirect = %s, want http://rp/cb?tenant=1", location)
+			}
+			if query.Get("error") != tt.error || query.Get("error_description") != tt.description {
+				t.Errorf("error = %q (%q), want %q (%q)", query.Get("error"), query.Get("error_description"), tt.error, tt.description)
+			}
+			if query.Get("state") != "st&ate" {
+				t.Errorf("state = %q, want original state", query.Get("state"))
+			}
+			if query.Has("code") {
+				t.Error("code issued despite the outcome")
+			}
+		})
+	}
+}
+
+func TestLoginOutcomesSandboxOnly(t *testing.T) {
+	h := newTestHandler(t)
+	set, err := profiles.NewSet(nil, profiles.Test)
+	if err != nil {
+		t.Fatalf("NewSet: %v", err)
+	}
+	query := url.Values{"client_id": {"rp"}, "redirect_uri": {"http://rp/cb"}}.Encode()
+
+	for _, tt := range []struct {
+		prefix   string
+		selector bool
+	}{
+		{"/test", true},
+		{"/prod", false},
+	} {
+		rec := httptest.NewRecorder()
+		set.Middleware(http.HandlerFunc(h.Authorize)).ServeHTTP(rec,
+			httptest.NewRequest(http.MethodGet, tt.prefix+"/aas/oauth2/ac?"+query, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: status %d", tt.prefix, rec.Code)
+		}
+		if got := strings.Contains(rec.Body.String(), `name="mock_outcome"`); got != tt.selector {
+			t.Errorf("%s: outcome selector shown = %v, want %v", tt.prefix, got, tt.selector)
+		}
+	}
+
+	// Вне тестовой среды сценарий из формы игнорируется и выдается код; отмена работает везде
+	rec := submitOutcome(t, h, "/prod/aas/oauth2/ac", url.Values{
+		"client_id": {"rp"}, "redirect_uri": {"http://rp/cb"}, "phone": {"79991234567"},
+		"mock_outcome": {"unavailable"},
+	})
+	location, _ := url.Parse(rec.Header().Get("Location"))
+	if rec.Code != http.StatusFound || location.Query().Get("code") == "" || location.Query().Has("error") {
+		t.Errorf("prod outcome: status %d, location %s; want a code", rec.Code, location)
+	}
+	rec = submitOutcome(t, h, "/prod/aas/oauth2/ac", url.Values{
+		"client_id": {"rp"}, "redirect_uri": {"http://rp/cb"}, "action": {"cancel"},
+	})
+	location, _ = url.Parse(rec.Header().Get("Location"))
+	if rec.Code != http.StatusFound || location.Query().Get("error") != "access_denied" {
+		t.Errorf("prod cancel: status %d, location %s", rec.Code, location)
+	}
+}
@@ -0,0 +1,224 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/vibe-gaming/esia-mock/internal/logger"
+	"github.com/vibe-gaming/esia-mock/internal/storage"
+	"go.uber.org/zap"
+)
+
+// personasPath путь admin API персон
+const personasPath = "/admin/personas"
+
+// PersonaRecord персона в admin API
+type PersonaRecord struct {
+	Phone     string            `json:"phone"`
+	Persona   *storage.UserData `json:"persona"`
+	Overrides *storage.Override `json:"overrides,omitempty"`
+}
+
+// PersonaRequest тело запроса на создание персоны: телефон и явно заданные поля
+type PersonaRequest struct {
+	Phone string `json:"phone"`
+	storage.Override
+}
+
+// Personas admin API персон:
+//
+//	GET    /admin/personas        — список сохраненных персон
+//	POST   /admin/personas        — создание персоны с заданными полями
+//	GET    /admin/personas/{key}  — персона по телефону или OID
+//	PATCH  /admin/personas/{key}  — изменение заданных полей; null снимает поле
+//	DELETE /admin/personas/{key}  — удаление персоны и заданных полей
+func (h *Handler) Personas(w http.ResponseWriter, r *http.Request) {
+	key := strings.Trim(strings.TrimPrefix(r.URL.Path, personasPath), "/")
+
+	switch {
+	case key == "" && r.Method == http.MethodGet:
+		h.listPersonas(w)
+	case key == "" && r.Method == http.MethodPost:
+		h.createPersona(w, r)
+	case key != "" && r.Method == http.MethodGet:
+		h.getPersona(w, key)
+	case key != "" && r.Method == http.MethodPatch:
+		h.patchPersona(w, r, key)
+	case key != "" && r.Method == http.MethodDelete:
+		h.deletePersona(w, key)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *Handler) listPersonas(w http.ResponseWriter) {
+	overrides, err := h.userCache.Overrides()
+	if err != nil {
+		logger.Error("Failed to list overrides", zap.Error(err))
+		writeJSONError(w, http.StatusInternalServerError, "server_error", err.Error())
+		return
+	}
+
+	records := []PersonaRecord{}
+	for phone, user := range h.userCache.GetAll() {
+		records = append(records, PersonaRecord{Phone: phone, Persona: user, Overrides: overrides[phone]})
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].Phone < records[j].Phone })
+
+	writeJSON(w, http.StatusOK, records)
+}
+
+func (h *Handler) createPersona(w http.ResponseWriter, r *http.Request) {
+	var req PersonaRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+
+	phone := storage.NormalizePhone(req.Phone)
+	if len(phone) != 11 {
+		writeJSONError(w, http.StatusBadRequest, "invalid_request", "phone must contain 11 digits")
+		return
+	}
+
+	if _, exists, err := h.userCache.Override(phone); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "server_error", err.Error())
+		return
+	} else if exists {
+		writeJSONError(w, http.StatusConflict, "already_exists", "persona "+phone+" already has overrides, use PATCH")
+		return
+	}
+
+	user, err := h.userCache.SetOverride(phone, req.Override)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+
+	logger.Info("Persona created", zap.String("phone", phone), zap.String("oid", user.OID))
+
+	w.Header().Set("Location", personasPath+"/"+phone)
+	h.writePersona(w, http.StatusCreated, phone, user)
+}
+
+func (h *Handler) getPersona(w http.ResponseWriter, key string) {
+	phone, ok := h.resolvePersona(key)
+	if !ok {
+		writeNotFound(w)
+		return
+	}
+
+	// Просмотр не создает персону: несохраненная показывается такой, какой будет сгенерирована
+	h.writePersona(w, http.StatusOK, phone, h.userCache.Peek(phone))
+}
+
+func (h *Handler) patchPersona(w http.ResponseWriter, r *http.Request, key string) {
+	phone, ok := h.resolvePersona(key)
+	if !ok {
+		writeNotFound(w)
+		return
+	}
+
+	var raw json.RawMessage
+	if !decodeJSONBody(w, r, &raw) {
+		return
+	}
+	// Поля со значением null снимаются, поэтому нужны и сами поля, и их наличие в теле
+	var patch storage.Override
+	var fields map[string]json.RawMessage
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&patch); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+
+	current, _, err := h.userCache.Override(phone)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "server_error", err.Error())
+		return
+	}
+
+	merged := current.Merge(patch)
+	for name, value := range fields {
+		if string(value) == "null" {
+			merged.Clear(name)
+		}
+	}
+
+	user, err := h.userCache.SetOverride(phone, merged)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+
+	logger.Info("Persona updated", zap.String("phone", phone), zap.String("oid", user.OID))
+
+	h.writePersona(w, http.StatusOK, phone, user)
+}
+
+func (h *Handler) deletePersona(w http.ResponseWriter, key string) {
+	phone, ok := h.resolvePersona(key)
+	if !ok {
+		writeNotFound(w)
+		return
+	}
+
+	if err := h.userCache.Delete(phone); err != nil {
+		logger.Error("Failed to delete persona", zap.String("phone", phone), zap.Error(err))
+		writeJSONError(w, http.StatusInternalServerError, "server_error", err.Error())
+		return
+	}
+
+	logger.Info("Persona deleted", zap.String("phone", phone))
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// resolvePersona определяет телефон персоны по ключу: номеру телефона или OID.
+// Телефон без сохраненной персоны допустим — персона будет сгенерирована
+func (h *Handler) resolvePersona(key string) (string, bool) {
+	phone := storage.NormalizePhone(key)
+	if _, ok := h.userCache.Get(phone); ok {
+		return phone, true
+	}
+	if phone, _, ok := h.userCache.FindByOID(key); ok {
+		return phone, true
+	}
+	return phone, len(phone) == 11
+}
+
+// writePersona отвечает персоной вместе с ее заданными полями
+func (h *Handler) writePersona(w http.ResponseWriter, status int, phone string, user *storage.UserData) {
+	record := PersonaRecord{Phone: phone, Persona: user}
+	if o, ok, err := h.userCache.Override(phone); err == nil && ok {
+		record.Overrides = &o
+	}
+	writeJSON(w, status, record)
+}
+
+// decodeJSONBody разбирает тело запроса, отклоняя неизвестные поля; при ошибке сам отвечает 400
+func decodeJSONBody(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid_request", err.Error())
+		return false
+	}
+	return true
+}
+
+// writeJSON отвечает значением в формате JSON. URL с параметрами выводятся как есть, без \u0026
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.Encode(v)
+}
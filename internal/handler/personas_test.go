@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// doPersonas выполняет запрос к admin API персон и разбирает персону из ответа
+func doPersonas(t *testing.T, h *Handler, method, path, body string) (int, PersonaRecord) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.Personas(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+
+	var record PersonaRecord
+	if rec.Code < 300 {
+		if err := json.NewDecoder(rec.Body).Decode(&record); err != nil {
+			t.Fatalf("%s %s: decode: %v", method, path, err)
+		}
+	}
+	return rec.Code, record
+}
+
+func TestGetPersonaDoesNotCreatePersona(t *testing.T) {
+	h := newTestHandler(t)
+
+	status, record := doPersonas(t, h, http.MethodGet, "/admin/personas/79991234567", "")
+	if status != http.StatusOK || record.Persona == nil {
+		t.Fatalf("status %d, persona %+v", status, record.Persona)
+	}
+	if _, ok := h.userCache.Get("79991234567"); ok {
+		t.Fatal("GET created a persona")
+	}
+
+	// Показанная персона совпадает с той, что будет сгенерирована при входе
+	if user := h.userCache.GetOrCreate("79991234567"); user.OID != record.Persona.OID {
+		t.Errorf("OID = %s, want %s", record.Persona.OID, user.OID)
+	}
+}
+
+func TestPatchPersonaClearsField(t *testing.T) {
+	h := newTestHandler(t)
+	generated := h.userCache.GetOrCreate("79991234567")
+
+	status, record := doPersonas(t, h, http.MethodPatch, "/admin/personas/79991234567",
+		`{"email": "anna@example.com", "firstName": "Анна"}`)
+	if status != http.StatusOK || record.Persona.Email != "anna@example.com" || record.Persona.FirstName != "Анна" {
+		t.Fatalf("status %d, persona %+v", status, record.Persona)
+	}
+
+	status, record = doPersonas(t, h, http.MethodPatch, "/admin/personas/79991234567", `{"email": null}`)
+	if status != http.StatusOK {
+		t.Fatalf("status %d", status)
+	}
+	if record.Persona.Email != generated.Email {
+		t.Errorf("email = %s, want generated %s", record.Persona.Email, generated.Email)
+	}
+	if record.Overrides == nil || record.Overrides.Email != nil || record.Overrides.FirstName == nil {
+		t.Errorf("overrides = %+v, want only firstName", record.Overrides)
+	}
+	if record.Persona.FirstName != "Анна" {
+		t.Errorf("firstName = %s, want the remaining override", record.Persona.FirstName)
+	}
+
+	if status, _ := doPersonas(t, h, http.MethodPatch, "/admin/personas/79991234567", `{"nickname": null}`); status != http.StatusBadRequest {
+		t.Errorf("unknown field: status %d, want 400", status)
+	}
+}
@@ -0,0 +1,124 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// newTestServer поднимает эндпоинты ЕСИА так же, как cmd/app
+func newTestServer(t *testing.T, h *Handler) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/aas/oauth2/ac", h.Authorize)
+	mux.HandleFunc("/aas/oauth2/authorize", h.AuthorizeSubmit)
+	mux.HandleFunc("/aas/oauth2/te", h.Token)
+	mux.HandleFunc("/rs/prns/", h.Persons)
+	mux.HandleFunc("/userinfo", h.UserInfo)
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestPersonDocsFlow(t *testing.T) {
+	srv := newTestServer(t, newTestHandler(t))
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	params := url.Values{
+		"client_id": {"rp"}, "redirect_uri": {"http://rp/cb"}, "scope": {"openid fullname"}, "state": {"s1"},
+	}
+
+	// Форма входа
+	resp, err := client.Get(srv.URL + "/aas/oauth2/ac?" + params.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("authorize: status %d", resp.StatusCode)
+	}
+
+	// Отправка формы: код в redirect_uri
+	params.Set("phone", "79991234567")
+	resp, err = client.PostForm(srv.URL+"/aas/oauth2/authorize", params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	location, err := url.Parse(resp.Header.Get("Location"))
+	if resp.StatusCode != http.StatusFound || err != nil || location.Query().Get("state") != "s1" {
+		t.Fatalf("submit: status %d, Location %q", resp.StatusCode, resp.Header.Get("Location"))
+	}
+
+	// Обмен кода на токен
+	resp, err = client.PostForm(srv.URL+"/aas/oauth2/te", url.Values{
+		"grant_type": {"authorization_code"}, "client_id": {"rp"},
+		"code": {location.Query().Get("code")}, "redirect_uri": {"http://rp/cb"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var token TokenResponse
+	json.NewDecoder(resp.Body).Decode(&token)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || token.AccessToken == "" {
+		t.Fatalf("token: status %d, %+v", resp.StatusCode, token)
+	}
+
+	get := func(path string, v interface{}) int {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+path, nil)
+		req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if v != nil && resp.StatusCode == http.StatusOK {
+			if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+				t.Fatalf("%s: decode: %v", path, err)
+			}
+		}
+		return resp.StatusCode
+	}
+
+	var person UserInfo
+	if status := get("/userinfo", &person); status != http.StatusOK || person.OID == "" {
+		t.Fatalf("userinfo: status %d, %+v", status, person)
+	}
+
+	var docs Collection
+	if status := get("/rs/prns/"+person.OID+"/docs", &docs); status != http.StatusOK || docs.Size == 0 {
+		t.Fatalf("docs: status %d, %+v", status, docs)
+	}
+	link, _ := docs.Elements[0].(string)
+	if !strings.HasPrefix(link, "/rs/prns/"+person.OID+"/docs/") {
+		t.Fatalf("document link = %v", docs.Elements[0])
+	}
+	var doc map[string]interface{}
+	if status := get(link, &doc); status != http.StatusOK || doc["type"] == nil {
+		t.Errorf("document: status %d, %v", status, doc)
+	}
+
+	tests := []struct {
+		path   string
+		status int
+	}{
+		{"/rs/prns/" + person.OID, http.StatusOK},
+		{"/rs/prns/" + person.OID + "/addrs", http.StatusOK},
+		{"/rs/prns/" + person.OID + "/docs/missing", http.StatusNotFound},
+		{"/rs/prns/" + person.OID + "/docs/1/2", http.StatusNotFound},
+		{"/rs/prns/" + person.OID + "/documents", http.StatusNotFound},
+		{"/rs/prns/" + person.OID + "/ctts", http.StatusNotFound},
+		{"/rs/prns/", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		if status := get(tt.path, nil); status != tt.status {
+			t.Errorf("%s: status %d, want %d", tt.path, status, tt.status)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package handler
+
+import (
+	"html"
+	"net/http"
+
+	"github.com/vibe-gaming/esia-mock/internal/oauth"
+	"github.com/vibe-gaming/esia-mock/internal/profiles"
+)
+
+// ProfileInfo профиль среды, обслуживший запрос
+type ProfileInfo struct {
+	Name       string `json:"name"`
+	Issuer     string `json:"issuer"`
+	PathPrefix string `json:"pathPrefix,omitempty"` // префикс, по которому выбран профиль
+	Sandbox    bool   `json:"sandbox"`
+}
+
+// oauthFor возвращает хранилище кодов и токенов в профиле среды запроса:
+// сроки жизни и издатель берутся из профиля, коды и токены других сред недействительны
+func (h *Handler) oauthFor(r *http.Request) *oauth.Store {
+	if p := profiles.FromContext(r.Context()); p != nil {
+		return h.oauth.In(p.Environment())
+	}
+	return h.oauth
+}
+
+// sandbox проверяет, что запрос обслуживает тестовая среда: форма входа предлагает сценарии отказа
+func sandbox(r *http.Request) bool {
+	p := profiles.FromContext(r.Context())
+	return p == nil || p.Sandbox
+}
+
+// profileInfo возвращает профиль запроса; nil — профили не используются
+func profileInfo(r *http.Request) *ProfileInfo {
+	p := profiles.FromContext(r.Context())
+	if p == nil {
+		return nil
+	}
+	return &ProfileInfo{
+		Name:       p.Name,
+		Issuer:     p.Issuer,
+		PathPrefix: profiles.PrefixFromContext(r.Context()),
+		Sandbox:    p.Sandbox,
+	}
+}
+
+// environmentNote подпись среды на форме входа
+func environmentNote(r *http.Request) string {
+	if sandbox(r) {
+		return "🔒 Тестовая среда ЕСИА<br>"
+	}
+	return "🔒 Мок ЕСИА, профиль " + html.EscapeString(profiles.FromContext(r.Context()).Name) + "<br>"
+}
@@ -0,0 +1,67 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/vibe-gaming/esia-mock/internal/logger"
+	"github.com/vibe-gaming/esia-mock/internal/ratelimit"
+	"go.uber.org/zap"
+)
+
+// rateLimitsPath путь admin API лимитов запросов
+const rateLimitsPath = "/admin/ratelimits"
+
+// RateLimits admin API лимитов запросов:
+//
+//	GET    /admin/ratelimits       — лимиты со счетчиками отклоненных запросов
+//	POST   /admin/ratelimits       — добавление лимита
+//	DELETE /admin/ratelimits       — удаление всех лимитов
+//	DELETE /admin/ratelimits/{id}  — удаление лимита
+func (h *Handler) RateLimits(w http.ResponseWriter, r *http.Request) {
+	if h.rateLimits == nil {
+		writeNotFound(w)
+		return
+	}
+
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, rateLimitsPath), "/")
+
+	switch {
+	case id == "" && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, h.rateLimits.List())
+
+	case id == "" && r.Method == http.MethodPost:
+		var rule ratelimit.Rule
+		if !decodeJSONBody(w, r, &rule) {
+			return
+		}
+		added, err := h.rateLimits.Add(rule)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "invalid_request", err.Error())
+			return
+		}
+
+		logger.Info("Rate limit added",
+			zap.String("id", added.ID),
+			zap.String("endpoint", added.Endpoint),
+			zap.String("client_id", added.ClientID),
+			zap.String("rate", added.Rate))
+		writeJSON(w, http.StatusCreated, added)
+
+	case id == "" && r.Method == http.MethodDelete:
+		h.rateLimits.Clear()
+		logger.Info("Rate limits cleared")
+		w.WriteHeader(http.StatusNoContent)
+
+	case id != "" && r.Method == http.MethodDelete:
+		if !h.rateLimits.Remove(id) {
+			writeNotFound(w)
+			return
+		}
+		logger.Info("Rate limit removed", zap.String("id", id))
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,127 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/vibe-gaming/esia-mock/internal/fixtures"
+	"github.com/vibe-gaming/esia-mock/internal/logger"
+	"github.com/vibe-gaming/esia-mock/internal/storage"
+	"go.uber.org/zap"
+)
+
+// resetPath путь сброса состояния
+const resetPath = "/admin/reset"
+
+// ResetResult число удаленных записей
+type ResetResult struct {
+	Codes    int `json:"codes"`
+	Tokens   int `json:"tokens"`
+	Personas int `json:"personas"`
+	Clients  int `json:"clients"`
+}
+
+// Reset сбрасывает состояние мока между тестами:
+//
+//	POST /admin/reset                    — все: коды, токены, персоны, клиенты, ведра лимитов и счетчики сбоев
+//	POST /admin/reset/tokens             — только коды и токены
+//	POST /admin/reset/clients/{client_id} — коды, токены и ведра лимитов клиента
+//	POST /admin/reset/phones/{phone}     — коды, токены и персона телефона
+//
+// Затронутые фикстуры загружаются заново: после сброса состояние совпадает с состоянием при старте.
+// Правила лимитов и сбоев не удаляются: они задаются настройками и снимаются отдельно
+func (h *Handler) Reset(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	target := strings.Trim(strings.TrimPrefix(r.URL.Path, resetPath), "/")
+	kind, value, _ := strings.Cut(target, "/")
+
+	var result ResetResult
+	var restore *fixtures.Set
+	var err error
+
+	switch {
+	case kind == "" && value == "":
+		result, err = h.resetAll()
+		restore = h.fixtures
+
+	case kind == "tokens" && value == "":
+		result.Codes, result.Tokens, err = h.oauth.RevokeMatching(func(string, string) bool { return true })
+		restore = h.fixtures.TokensWhere(func(string, string) bool { return true })
+
+	case kind == "clients" && value != "":
+		match := func(clientID, _ string) bool { return clientID == value }
+		result.Codes, result.Tokens, err = h.oauth.RevokeMatching(match)
+		restore = h.fixtures.TokensWhere(match)
+		if h.rateLimits != nil {
+			h.rateLimits.ResetClient(value)
+		}
+
+	case kind == "phones" && value != "":
+		phone := storage.NormalizePhone(value)
+		result.Codes, result.Tokens, err = h.oauth.RevokeMatching(func(_, p string) bool { return p == phone })
+		if err == nil {
+			if _, exists := h.userCache.Get(phone); exists {
+				result.Personas = 1
+			}
+			err = h.userCache.Delete(phone)
+		}
+		restore = h.fixtures.ForPhone(phone)
+
+	default:
+		writeNotFound(w)
+		return
+	}
+
+	if err == nil && restore != nil {
+		err = fixtures.Apply(restore, h.userCache, h.oauth, h.clients)
+	}
+	if err != nil {
+		logger.Error("Failed to reset state", zap.String("target", target), zap.Error(err))
+		writeJSONError(w, http.StatusInternalServerError, "server_error", err.Error())
+		return
+	}
+
+	logger.Info("State reset",
+		zap.String("target", target),
+		zap.Int("codes", result.Codes),
+		zap.Int("tokens", result.Tokens),
+		zap.Int("personas", result.Personas),
+		zap.Int("clients", result.Clients))
+
+	writeJSON(w, http.StatusOK, result)
+}
+
+// resetAll удаляет все коды, токены, персоны и клиентов, наполняет ведра лимитов
+// и обнуляет счетчики правил сбоев
+func (h *Handler) resetAll() (ResetResult, error) {
+	var result ResetResult
+	var err error
+
+	if h.rateLimits != nil {
+		h.rateLimits.Reset()
+	}
+	if h.faults != nil {
+		h.faults.ResetCounters()
+	}
+
+	result.Codes, result.Tokens, err = h.oauth.RevokeMatching(func(string, string) bool { return true })
+	if err != nil {
+		return result, err
+	}
+
+	result.Personas, err = h.userCache.Reset()
+	if err != nil {
+		return result, err
+	}
+
+	clientList, err := h.clients.List()
+	if err != nil {
+		return result, err
+	}
+	result.Clients = len(clientList)
+	return result, h.clients.Import(nil, true)
+}
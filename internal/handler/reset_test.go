@@ -0,0 +1,158 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/vibe-gaming/esia-mock/internal/clients"
+	"github.com/vibe-gaming/esia-mock/internal/faults"
+	"github.com/vibe-gaming/esia-mock/internal/ratelimit"
+)
+
+// resetFixture состояние для проверки сброса: код и токен клиента rp для телефона 79990000001,
+// код клиента other для телефона 79990000002, исчерпанные ведра лимита обоих клиентов
+// и сработавшее правило сбоя
+type resetFixture struct {
+	h      *Handler
+	token  string
+	limits *ratelimit.Limiter
+	faults *faults.Engine
+}
+
+func newResetFixture(t *testing.T) *resetFixture {
+	t.Helper()
+	h := newTestHandler(t)
+	registerClients(t, h,
+		&clients.Client{ID: "rp", RedirectURIs: []string{"http://rp/cb"}},
+		&clients.Client{ID: "other", RedirectURIs: []string{"http://other/cb"}},
+	)
+	login := func(clientID, redirectURI, phone string) string {
+		location := submitLogin(t, h, url.Values{"client_id": {clientID}, "redirect_uri": {redirectURI}, "phone": {phone}})
+		return location.Query().Get("code")
+	}
+	login("rp", "http://rp/cb", "79990000001")
+	token := exchangeCode(t, h, login("rp", "http://rp/cb", "79990000001")).AccessToken
+	getUserInfo(t, h, token) // персона телефона 79990000001
+	login("other", "http://other/cb", "79990000002")
+
+	f := &resetFixture{h: h, token: token, limits: ratelimit.NewLimiter(), faults: faults.NewEngine()}
+	if _, err := f.limits.Add(ratelimit.Rule{Endpoint: "/aas/oauth2/ac", Rate: "1/m", Burst: 1}); err != nil {
+		t.Fatalf("Add limit: %v", err)
+	}
+	if _, err := f.faults.Add(faults.Rule{Endpoint: "/aas/oauth2/ac", Nth: 1, Status: http.StatusServiceUnavailable}); err != nil {
+		t.Fatalf("Add fault: %v", err)
+	}
+	h.SetRateLimits(f.limits)
+	h.SetFaults(f.faults)
+
+	for _, clientID := range []string{"rp", "other"} {
+		f.authorize(clientID)
+		if status := f.authorize(clientID); status != http.StatusTooManyRequests {
+			t.Fatalf("%s: status %d, want 429", clientID, status)
+		}
+	}
+	if listed := f.faults.List(); listed[0].Fired != 1 {
+		t.Fatalf("fault counters = %+v", listed[0])
+	}
+	return f
+}
+
+// authorize открывает форму входа клиента через лимиты и сбои и возвращает статус
+func (f *resetFixture) authorize(clientID string) int {
+	next := f.faults.Middleware(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}), f.h.RequestIdentity)
+	rec := httptest.NewRecorder()
+	f.limits.Middleware(next, f.h.RequestIdentity).ServeHTTP(rec,
+		httptest.NewRequest(http.MethodGet, "/aas/oauth2/ac?client_id="+clientID, nil))
+	return rec.Code
+}
+
+// reset вызывает сброс и возвращает число удаленных записей
+func (f *resetFixture) reset(t *testing.T, path string) ResetResult {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	f.h.Reset(rec, httptest.NewRequest(http.MethodPost, path, nil))
+	var result ResetResult
+	if rec.Code != http.StatusOK {
+		t.Fatalf("%s: status %d, body %s", path, rec.Code, rec.Body)
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&result); err != nil {
+		t.Fatalf("%s: %v", path, err)
+	}
+	return result
+}
+
+func TestResetAll(t *testing.T) {
+	f := newResetFixture(t)
+
+	if got, want := f.reset(t, "/admin/reset"), (ResetResult{Codes: 2, Tokens: 1, Personas: 1, Clients: 2}); got != want {
+		t.Errorf("result = %+v, want %+v", got, want)
+	}
+	if list, _ := f.h.clients.List(); len(list) != 0 {
+		t.Errorf("clients left: %d", len(list))
+	}
+
+	// Ведра наполнены, счетчики обнулены, правила остались
+	if rules := f.limits.List(); len(rules) != 1 || rules[0].Throttled != 0 {
+		t.Errorf("limit rules = %+v", rules)
+	}
+	if rules := f.faults.List(); len(rules) != 1 || rules[0].Matched != 0 || rules[0].Fired != 0 {
+		t.Errorf("fault rules = %+v", rules)
+	}
+	if status := f.authorize("rp"); status != http.StatusServiceUnavailable {
+		t.Errorf("first request after reset: status %d, want 503 from the nth rule", status)
+	}
+	if status := f.authorize("rp"); status != http.StatusTooManyRequests {
+		t.Errorf("second request after reset: status %d, want 429", status)
+	}
+}
+
+func TestResetScopes(t *testing.T) {
+	tests := []struct {
+		path        string
+		want        ResetResult
+		tokenRevoke bool
+		limitsReset []string // клиенты, чьи ведра наполнены
+		persona     bool     // персона 79990000001 осталась
+	}{
+		{"/admin/reset/tokens", ResetResult{Codes: 2, Tokens: 1}, true, nil, true},
+		{"/admin/reset/clients/rp", ResetResult{Codes: 1, Tokens: 1}, true, []string{"rp"}, true},
+		{"/admin/reset/clients/other", ResetResult{Codes: 1}, false, []string{"other"}, true},
+		{"/admin/reset/phones/+7%20999%20000-00-01", ResetResult{Codes: 1, Tokens: 1, Personas: 1}, true, nil, false},
+		{"/admin/reset/phones/79990000002", ResetResult{Codes: 1}, false, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			f := newResetFixture(t)
+
+			if got := f.reset(t, tt.path); got != tt.want {
+				t.Errorf("result = %+v, want %+v", got, tt.want)
+			}
+			if _, err := f.h.oauth.LookupToken(f.token); (err != nil) != tt.tokenRevoke {
+				t.Errorf("token revoked = %v, want %v", err != nil, tt.tokenRevoke)
+			}
+			if _, ok := f.h.userCache.Get("79990000001"); ok != tt.persona {
+				t.Errorf("persona kept = %v, want %v", ok, tt.persona)
+			}
+			if list, _ := f.h.clients.List(); len(list) != 2 {
+				t.Errorf("clients = %d, want 2", len(list))
+			}
+
+			reset := map[string]bool{}
+			for _, clientID := range tt.limitsReset {
+				reset[clientID] = true
+			}
+			for _, clientID := range []string{"rp", "other"} {
+				want := http.StatusTooManyRequests
+				if reset[clientID] {
+					want = http.StatusOK
+				}
+				if status := f.authorize(clientID); status != want {
+					t.Errorf("%s: status %d, want %d", clientID, status, want)
+				}
+			}
+		})
+	}
+}
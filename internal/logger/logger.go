@@ -9,7 +9,18 @@ import (
 
 var logger *zap.Logger
 
+// Форматы логов
+const (
+	FormatJSON    = "json"
+	FormatConsole = "console"
+)
+
 func Init(level string) {
+	Configure(level, FormatJSON)
+}
+
+// Configure настраивает уровень и формат логов: json — для сбора логов, console — для чтения глазами
+func Configure(level, format string) {
 	zLevel := getLevel(level)
 
 	const (
@@ -21,21 +32,28 @@ func Init(level string) {
 		stacktrace = "stacktrace"
 	)
 
+	encoderConfig := zapcore.EncoderConfig{
+		TimeKey:        timestamp,
+		LevelKey:       severity,
+		NameKey:        loggerName,
+		CallerKey:      caller,
+		MessageKey:     message,
+		StacktraceKey:  stacktrace,
+		LineEnding:     zapcore.DefaultLineEnding,
+		EncodeLevel:    zapcore.LowercaseLevelEncoder,
+		EncodeTime:     zapcore.ISO8601TimeEncoder,
+		EncodeDuration: zapcore.SecondsDurationEncoder,
+		EncodeCaller:   zapcore.ShortCallerEncoder,
+	}
+
+	encoder := zapcore.NewJSONEncoder(encoderConfig)
+	if format == FormatConsole {
+		encoder = zapcore.NewConsoleEncoder(encoderConfig)
+	}
+
 	logger = zap.New(
 		zapcore.NewCore(
-			zapcore.NewJSONEncoder(zapcore.EncoderConfig{
-				TimeKey:        timestamp,
-				LevelKey:       severity,
-				NameKey:        loggerName,
-				CallerKey:      caller,
-				MessageKey:     message,
-				StacktraceKey:  stacktrace,
-				LineEnding:     zapcore.DefaultLineEnding,
-				EncodeLevel:    zapcore.LowercaseLevelEncoder,
-				EncodeTime:     zapcore.ISO8601TimeEncoder,
-				EncodeDuration: zapcore.SecondsDurationEncoder,
-				EncodeCaller:   zapcore.ShortCallerEncoder,
-			}),
+			encoder,
 			zapcore.AddSync(os.Stdout),
 			zLevel,
 		),
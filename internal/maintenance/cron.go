@@ -0,0 +1,161 @@
+package maintenance
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule расписание в формате cron из пяти полей: минута, час, день месяца, месяц, день недели.
+// Поле — *, число, диапазон a-b, список через запятую и шаг /n. День недели 0 или 7 — воскресенье
+type Schedule struct {
+	minute, hour, dom, month, dow uint64 // битовые маски допустимых значений
+	domAny, dowAny                bool
+}
+
+// cronField пределы поля расписания
+type cronField struct {
+	name     string
+	min, max int
+}
+
+var cronFields = []cronField{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7},
+}
+
+// ParseSchedule разбирает выражение cron
+func ParseSchedule(expr string) (*Schedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != len(cronFields) {
+		return nil, fmt.Errorf("invalid schedule %q, expected 5 fields: minute hour day month weekday", expr)
+	}
+
+	masks := make([]uint64, len(fields))
+	for i, field := range fields {
+		mask, err := parseCronField(field, cronFields[i])
+		if err != nil {
+			return nil, fmt.Errorf("invalid schedule %q: %w", expr, err)
+		}
+		masks[i] = mask
+	}
+
+	// 7 и 0 — воскресенье
+	if masks[4]&(1<<7) != 0 {
+		masks[4] |= 1
+	}
+
+	return &Schedule{
+		minute: masks[0],
+		hour:   masks[1],
+		dom:    masks[2],
+		month:  masks[3],
+		dow:    masks[4],
+		domAny: fields[2] == "*",
+		dowAny: fields[4] == "*",
+	}, nil
+}
+
+// parseCronField переводит поле расписания в битовую маску
+func parseCronField(field string, f cronField) (uint64, error) {
+	var mask uint64
+	for _, part := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepPart)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid step %q in %s", stepPart, f.name)
+			}
+			step = n
+		}
+
+		low, high := f.min, f.max
+		if rangePart != "*" {
+			lowPart, highPart, isRange := strings.Cut(rangePart, "-")
+			var err error
+			if low, err = strconv.Atoi(lowPart); err != nil {
+				return 0, fmt.Errorf("invalid value %q in %s", part, f.name)
+			}
+			high = low
+			if isRange {
+				if high, err = strconv.Atoi(highPart); err != nil {
+					return 0, fmt.Errorf("invalid value %q in %s", part, f.name)
+				}
+			} else if hasStep {
+				high = f.max
+			}
+		}
+		if low < f.min || high > f.max || low > high {
+			return 0, fmt.Errorf("%s value %q out of range %d-%d", f.name, part, f.min, f.max)
+		}
+
+		for v := low; v <= high; v += step {
+			mask |= 1 << v
+		}
+	}
+	return mask, nil
+}
+
+// Matches проверяет, что минута t попадает в расписание. Как в cron, если заданы и день месяца,
+// и день недели, достаточно совпадения одного из них
+func (s *Schedule) Matches(t time.Time) bool {
+	if s.minute&(1<<t.Minute()) == 0 || s.hour&(1<<t.Hour()) == 0 || s.month&(1<<int(t.Month())) == 0 {
+		return false
+	}
+	return s.matchesDay(t)
+}
+
+// matchesDay проверяет день месяца и день недели
+func (s *Schedule) matchesDay(t time.Time) bool {
+	domMatch := s.dom&(1<<t.Day()) != 0
+	dowMatch := s.dow&(1<<int(t.Weekday())) != 0
+	switch {
+	case s.domAny && s.dowAny:
+		return true
+	case s.domAny:
+		return dowMatch
+	case s.dowAny:
+		return domMatch
+	}
+	return domMatch || dowMatch
+}
+
+// LastStart возвращает последний запуск расписания не позже t и не раньше t-within
+func (s *Schedule) LastStart(t time.Time, within time.Duration) (time.Time, bool) {
+	start := t.Truncate(time.Minute)
+	for candidate := start; t.Sub(candidate) < within; candidate = candidate.Add(-time.Minute) {
+		if s.Matches(candidate) {
+			return candidate, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// NextStart возвращает ближайший запуск расписания позже t и не позже t+within.
+// Неподходящие месяцы, дни и часы пропускаются целиком
+func (s *Schedule) NextStart(t time.Time, within time.Duration) (time.Time, bool) {
+	limit := t.Add(within)
+	candidate := t.Truncate(time.Minute).Add(time.Minute)
+	for !candidate.After(limit) {
+		y, mon, d := candidate.Date()
+		loc := candidate.Location()
+		switch {
+		case s.month&(1<<int(mon)) == 0:
+			candidate = time.Date(y, mon+1, 1, 0, 0, 0, 0, loc)
+		case !s.matchesDay(candidate):
+			candidate = time.Date(y, mon, d+1, 0, 0, 0, 0, loc)
+		case s.hour&(1<<candidate.Hour()) == 0:
+			candidate = time.Date(y, mon, d, candidate.Hour()+1, 0, 0, 0, loc)
+		case s.minute&(1<<candidate.Minute()) == 0:
+			candidate = candidate.Add(time.Minute)
+		default:
+			return candidate, true
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,385 @@
+package maintenance
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vibe-gaming/esia-mock/internal/logger"
+	"github.com/vibe-gaming/esia-mock/internal/stats"
+	"go.uber.org/zap"
+)
+
+// Группы эндпоинтов, которые можно отключить по отдельности
+const (
+	GroupLogin = "login" // форма входа: /aas/oauth2/ac, /aas/oauth2/authorize
+	GroupToken = "token" // обмен кода: /aas/oauth2/te
+	GroupREST  = "rest"  // данные пользователя: /rs/*, /userinfo
+)
+
+// Groups все группы эндпоинтов
+var Groups = []string{GroupLogin, GroupToken, GroupREST}
+
+// DefaultTimezone часовой пояс окон обслуживания по умолчанию: работы на Госуслугах
+// объявляются по московскому времени
+const DefaultTimezone = "Europe/Moscow"
+
+var maintenanceRejections = stats.New("maintenance_rejections")
+
+// Window окно обслуживания по расписанию: начинается по cron-выражению и длится duration
+type Window struct {
+	Schedule  string   `json:"schedule" yaml:"schedule"`                       // например "0 1 * * 0" — воскресенье 01:00
+	Duration  string   `json:"duration" yaml:"duration"`                       // например 3h
+	Endpoints []string `json:"endpoints,omitempty" yaml:"endpoints,omitempty"` // пусто — все группы
+	Timezone  string   `json:"timezone,omitempty" yaml:"timezone,omitempty"`   // пусто — Europe/Moscow
+
+	schedule *Schedule
+	duration time.Duration
+	location *time.Location
+	bounds   *windowBounds
+}
+
+// windowLookahead насколько вперед ищется следующее окно. Если окна нет, расписание
+// не проверяется до конца этого срока
+const windowLookahead = 366 * 24 * time.Hour
+
+// windowBounds границы текущего или следующего окна. Они вычисляются заново, только
+// когда окно закончилось или часы ушли назад, а не на каждом запросе
+type windowBounds struct {
+	mu         sync.Mutex
+	from       time.Time // момент вычисления границ
+	start, end time.Time
+}
+
+// Settings настройки режима обслуживания
+type Settings struct {
+	Enabled   bool       `json:"enabled" yaml:"enabled"`                         // включен вручную
+	Endpoints []string   `json:"endpoints,omitempty" yaml:"endpoints,omitempty"` // группы при ручном включении; пусто — все
+	Until     *time.Time `json:"until,omitempty" yaml:"until,omitempty"`         // ручной режим выключается сам в это время
+	Message   string     `json:"message,omitempty" yaml:"message,omitempty"`     // текст на странице технических работ
+	Windows   []Window   `json:"windows,omitempty" yaml:"windows,omitempty"`     // окна по расписанию
+}
+
+// Status настройки и группы, недоступные прямо сейчас
+type Status struct {
+	Settings
+	Active      []string   `json:"active"`
+	ActiveUntil *time.Time `json:"activeUntil,omitempty"`
+}
+
+// Mode режим обслуживания: ручной переключатель и окна по расписанию
+type Mode struct {
+	mu       sync.RWMutex
+	settings Settings
+	now      func() time.Time
+}
+
+// NewMode создает выключенный режим обслуживания
+func NewMode() *Mode {
+	return &Mode{now: time.Now}
+}
+
+// Set проверяет и применяет настройки целиком
+func (m *Mode) Set(settings Settings) error {
+	if err := validateGroups(settings.Endpoints); err != nil {
+		return err
+	}
+	// Окна проверяются в новом срезе: переданные настройки могут разделять его с текущими,
+	// которые в это время читает Middleware
+	windows := make([]Window, len(settings.Windows))
+	for i, w := range settings.Windows {
+		if err := w.validate(); err != nil {
+			return fmt.Errorf("windows[%d]: %w", i, err)
+		}
+		windows[i] = w
+	}
+	if settings.Windows != nil {
+		settings.Windows = windows
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.settings = settings
+	return nil
+}
+
+// Settings возвращает копию текущих настроек
+func (m *Mode) Settings() Settings {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	settings := m.settings
+	if settings.Windows != nil {
+		settings.Windows = append([]Window(nil), settings.Windows...)
+	}
+	return settings
+}
+
+// Status возвращает настройки и активные группы
+func (m *Mode) Status() Status {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	status := Status{Settings: m.settings, Active: []string{}}
+	active := m.active(m.now())
+	for _, group := range Groups {
+		if until, ok := active[group]; ok {
+			status.Active = append(status.Active, group)
+			if !until.IsZero() && (status.ActiveUntil == nil || until.After(*status.ActiveUntil)) {
+				status.ActiveUntil = &until
+			}
+		}
+	}
+	return status
+}
+
+// GroupOf возвращает группу эндпоинта; пусто — эндпоинт режим обслуживания не затрагивает
+func GroupOf(path string) string {
+	switch {
+	case path == "/aas/oauth2/ac" || path == "/aas/oauth2/authorize":
+		return GroupLogin
+	case path == "/aas/oauth2/te":
+		return GroupToken
+	case strings.HasPrefix(path, "/rs/") || path == "/userinfo":
+		return GroupREST
+	}
+	return ""
+}
+
+// Middleware отвечает на запросы к недоступным группам как ЕСИА во время технических работ
+func (m *Mode) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		group := GroupOf(r.URL.Path)
+		if group == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		m.mu.RLock()
+		now := m.now()
+		until, down := m.active(now)[group]
+		message := m.settings.Message
+		m.mu.RUnlock()
+
+		if !down {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		maintenanceRejections.Inc()
+		logger.Debug("Request rejected by maintenance mode",
+			zap.String("group", group),
+			zap.String("path", r.URL.Path))
+
+		if !until.IsZero() {
+			seconds := int(math.Ceil(until.Sub(now).Seconds()))
+			w.Header().Set("Retry-After", strconv.Itoa(max(seconds, 1)))
+		}
+
+		switch group {
+		case GroupLogin:
+			writePage(w, message, until)
+		case GroupToken:
+			writeJSON(w, map[string]string{
+				"error":             "temporarily_unavailable",
+				"error_description": "ESIA-007008: Сервис авторизации в настоящее время не может обработать запрос",
+			})
+		default:
+			writeJSON(w, map[string]string{
+				"code":    "ESIA-005011",
+				"message": "Сервис временно недоступен: проводятся технические работы",
+			})
+		}
+	})
+}
+
+// active возвращает недоступные группы и время окончания работ; нулевое время — окончание неизвестно
+func (m *Mode) active(now time.Time) map[string]time.Time {
+	active := map[string]time.Time{}
+	mark := func(groups []string, until time.Time) {
+		if len(groups) == 0 {
+			groups = Groups
+		}
+		for _, group := range groups {
+			if prev, ok := active[group]; !ok || (!prev.IsZero() && (until.IsZero() || until.After(prev))) {
+				active[group] = until
+			}
+		}
+	}
+
+	s := m.settings
+	if s.Enabled && (s.Until == nil || now.Before(*s.Until)) {
+		var until time.Time
+		if s.Until != nil {
+			until = *s.Until
+		}
+		mark(s.Endpoints, until)
+	}
+
+	for _, w := range s.Windows {
+		if until, ok := w.activeUntil(now); ok {
+			mark(w.Endpoints, until)
+		}
+	}
+	return active
+}
+
+// activeUntil возвращает окончание окна, если now попадает в окно
+func (w *Window) activeUntil(now time.Time) (time.Time, bool) {
+	b := w.bounds
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if now.Before(b.from) || !now.Before(b.end) {
+		local := now.In(w.location)
+		b.from = now
+		if start, ok := w.schedule.LastStart(local, w.duration); ok {
+			b.start, b.end = start, start.Add(w.duration)
+			// Окна, начавшиеся до конца текущего, продлевают его
+			for last := start; b.end.Sub(now) < windowLookahead; {
+				next, ok := w.schedule.NextStart(last, b.end.Sub(last))
+				if !ok {
+					break
+				}
+				last, b.end = next, next.Add(w.duration)
+			}
+		} else if start, ok := w.schedule.NextStart(local, windowLookahead); ok {
+			b.start, b.end = start, start.Add(w.duration)
+		} else {
+			b.start = now.Add(windowLookahead)
+			b.end = b.start
+		}
+	}
+
+	if now.Before(b.start) {
+		return time.Time{}, false
+	}
+	return b.end, true
+}
+
+// validate разбирает расписание, длительность и часовой пояс окна
+func (w *Window) validate() error {
+	schedule, err := ParseSchedule(w.Schedule)
+	if err != nil {
+		return err
+	}
+	w.schedule = schedule
+	w.bounds = &windowBounds{}
+
+	duration, err := time.ParseDuration(w.Duration)
+	if err != nil || duration <= 0 || duration > 7*24*time.Hour {
+		return fmt.Errorf("invalid duration %q, expected a positive duration up to 168h", w.Duration)
+	}
+	w.duration = duration
+
+	if w.Timezone == "" {
+		w.Timezone = DefaultTimezone
+	}
+	w.location, err = loadLocation(w.Timezone)
+	if err != nil {
+		return fmt.Errorf("invalid timezone %q: %w", w.Timezone, err)
+	}
+	return validateGroups(w.Endpoints)
+}
+
+// loadLocation загружает часовой пояс. Без базы часовых поясов в системе
+// Europe/Moscow заменяется фиксированным UTC+3
+func loadLocation(name string) (*time.Location, error) {
+	location, err := time.LoadLocation(name)
+	if err != nil && name == DefaultTimezone {
+		return time.FixedZone("MSK", 3*60*60), nil
+	}
+	return location, err
+}
+
+// validateGroups проверяет названия групп эндпоинтов
+func validateGroups(groups []string) error {
+	for _, group := range groups {
+		switch group {
+		case GroupLogin, GroupToken, GroupREST:
+		default:
+			return fmt.Errorf("unknown endpoint group %q, expected %s", group, strings.Join(Groups, ", "))
+		}
+	}
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusServiceUnavailable)
+	json.NewEncoder(w).Encode(body)
+}
+
+// writePage отдает страницу технических работ вместо формы входа
+func writePage(w http.ResponseWriter, message string, until time.Time) {
+	if message == "" {
+		message = "Портал Госуслуг временно недоступен. Проводятся плановые технические работы."
+	}
+	untilInfo := ""
+	if !until.IsZero() {
+		msk, _ := loadLocation(DefaultTimezone)
+		untilInfo = `<p class="until">Работы завершатся ` + until.In(msk).Format("02.01.2006 в 15:04") + ` по московскому времени</p>`
+	}
+
+	page := `<!DOCTYPE html>
+<html lang="ru">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>Технические работы — Госуслуги</title>
+    <style>
+        body {
+            font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Roboto, 'Helvetica Neue', Arial, sans-serif;
+            background: linear-gradient(135deg, #0d47a1 0%, #1976d2 100%);
+            min-height: 100vh;
+            margin: 0;
+            display: flex;
+            align-items: center;
+            justify-content: center;
+            padding: 20px;
+            box-sizing: border-box;
+        }
+        .container {
+            background: white;
+            padding: 40px;
+            border-radius: 12px;
+            box-shadow: 0 10px 40px rgba(0, 0, 0, 0.2);
+            max-width: 440px;
+            text-align: center;
+        }
+        h1 {
+            color: #333;
+            font-size: 24px;
+            margin: 0 0 16px;
+        }
+        p {
+            color: #666;
+            font-size: 15px;
+            line-height: 1.5;
+            margin: 0 0 12px;
+        }
+        .until {
+            color: #0d47a1;
+            font-weight: 600;
+        }
+    </style>
+</head>
+<body>
+    <div class="container">
+        <h1>Технические работы</h1>
+        <p>` + html.EscapeString(message) + `</p>
+        ` + untilInfo + `
+        <p>Приносим извинения за доставленные неудобства.</p>
+    </div>
+</body>
+</html>
+`
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusServiceUnavailable)
+	w.Write([]byte(page))
+}
@@ -0,0 +1,285 @@
+package maintenance
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/vibe-gaming/esia-mock/internal/logger"
+)
+
+func TestMain(m *testing.M) {
+	logger.Init("error")
+	os.Exit(m.Run())
+}
+
+func TestSchedule(t *testing.T) {
+	msk := time.FixedZone("MSK", 3*60*60)
+	// Воскресенье, 4 января 2026
+	sunday := time.Date(2026, 1, 4, 1, 30, 0, 0, msk)
+
+	tests := []struct {
+		expr string
+		at   time.Time
+		want bool
+	}{
+		{"30 1 * * 0", sunday, true},
+		{"30 1 * * 7", sunday, true},
+		{"30 1 * * 1-5", sunday, false},
+		{"*/15 * * * *", sunday, true},
+		{"*/20 * * * *", sunday, false},
+		{"0,30 0-2 4 1 *", sunday, true},
+		{"30 1 5 * 0", sunday, true}, // день месяца или день недели
+		{"30 1 5 * 1", sunday, false},
+	}
+	for _, tt := range tests {
+		s, err := ParseSchedule(tt.expr)
+		if err != nil {
+			t.Fatalf("ParseSchedule(%q): %v", tt.expr, err)
+		}
+		if got := s.Matches(tt.at); got != tt.want {
+			t.Errorf("%q matches %v = %v, want %v", tt.expr, tt.at, got, tt.want)
+		}
+	}
+
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "5-1 * * * *", "*/0 * * * *", "a * * * *"} {
+		if _, err := ParseSchedule(expr); err == nil {
+			t.Errorf("ParseSchedule(%q) accepted", expr)
+		}
+	}
+}
+
+// testMode режим с управляемыми часами
+func testMode(t *testing.T, settings Settings, now time.Time) (*Mode, func(time.Time)) {
+	t.Helper()
+	m := NewMode()
+	m.now = func() time.Time { return now }
+	if err := m.Set(settings); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	return m, func(at time.Time) { now = at }
+}
+
+func serve(m *Mode, path string) *httptest.ResponseRecorder {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	rec := httptest.NewRecorder()
+	m.Middleware(ok).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	return rec
+}
+
+func TestManualToggle(t *testing.T) {
+	now := time.Date(2026, 1, 4, 12, 0, 0, 0, time.UTC)
+	until := now.Add(90 * time.Second)
+	m, setNow := testMode(t, Settings{Enabled: true, Endpoints: []string{GroupREST}, Until: &until}, now)
+
+	rec := serve(m, "/rs/prns/1000")
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("rest status = %d, want 503", rec.Code)
+	}
+	if rec.Header().Get("Retry-After") != "90" {
+		t.Errorf("Retry-After = %q, want 90", rec.Header().Get("Retry-After"))
+	}
+	var body map[string]string
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || body["code"] == "" {
+		t.Errorf("rest body = %s", rec.Body)
+	}
+
+	// Вход и обмен кода работают
+	for _, path := range []string{"/aas/oauth2/ac", "/aas/oauth2/te", "/mock/info"} {
+		if rec := serve(m, path); rec.Code != http.StatusOK {
+			t.Errorf("%s: status = %d, want 200", path, rec.Code)
+		}
+	}
+
+	if status := m.Status(); len(status.Active) != 1 || status.Active[0] != GroupREST {
+		t.Errorf("active = %v, want [rest]", status.Active)
+	}
+
+	// После until режим выключается сам
+	setNow(until)
+	if rec := serve(m, "/userinfo"); rec.Code != http.StatusOK {
+		t.Errorf("after until: status = %d, want 200", rec.Code)
+	}
+}
+
+func TestAllGroupsResponses(t *testing.T) {
+	m, _ := testMode(t, Settings{Enabled: true, Message: "Обновление <системы>"}, time.Now())
+
+	rec := serve(m, "/aas/oauth2/authorize")
+	if rec.Code != http.StatusServiceUnavailable || !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/html") {
+		t.Fatalf("login: %d %s", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	if !strings.Contains(rec.Body.String(), "Обновление &lt;системы&gt;") {
+		t.Error("maintenance page does not contain the escaped message")
+	}
+	if rec.Header().Get("Retry-After") != "" {
+		t.Error("Retry-After set without a known end time")
+	}
+
+	rec = serve(m, "/aas/oauth2/te")
+	var body map[string]string
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || body["error"] != "temporarily_unavailable" {
+		t.Errorf("token body = %s", rec.Body)
+	}
+}
+
+func TestScheduledWindow(t *testing.T) {
+	msk := time.FixedZone("MSK", 3*60*60)
+	window := Window{Schedule: "0 1 * * 0", Duration: "3h", Endpoints: []string{GroupLogin, GroupToken}}
+	m, setNow := testMode(t, Settings{Windows: []Window{window}}, time.Date(2026, 1, 4, 0, 59, 0, 0, msk))
+
+	if rec := serve(m, "/aas/oauth2/ac"); rec.Code != http.StatusOK {
+		t.Errorf("before window: status = %d, want 200", rec.Code)
+	}
+
+	setNow(time.Date(2026, 1, 4, 3, 30, 0, 0, msk))
+	rec := serve(m, "/aas/oauth2/ac")
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("inside window: status = %d, want 503", rec.Code)
+	}
+	if rec.Header().Get("Retry-After") != "1800" {
+		t.Errorf("Retry-After = %q, want 1800", rec.Header().Get("Retry-After"))
+	}
+	if rec := serve(m, "/userinfo"); rec.Code != http.StatusOK {
+		t.Errorf("rest inside login window: status = %d, want 200", rec.Code)
+	}
+
+	setNow(time.Date(2026, 1, 4, 4, 0, 0, 0, msk))
+	if rec := serve(m, "/aas/oauth2/ac"); rec.Code != http.StatusOK {
+		t.Errorf("after window: status = %d, want 200", rec.Code)
+	}
+}
+
+func TestNextStart(t *testing.T) {
+	msk := time.FixedZone("MSK", 3*60*60)
+	// Понедельник, 5 января 2026
+	monday := time.Date(2026, 1, 5, 10, 15, 30, 0, msk)
+
+	tests := []struct {
+		expr   string
+		within time.Duration
+		want   time.Time
+		ok     bool
+	}{
+		{"0 1 * * 0", 7 * 24 * time.Hour, time.Date(2026, 1, 11, 1, 0, 0, 0, msk), true},
+		{"*/20 * * * *", time.Hour, time.Date(2026, 1, 5, 10, 20, 0, 0, msk), true},
+		{"15 10 * * *", 48 * time.Hour, time.Date(2026, 1, 6, 10, 15, 0, 0, msk), true},
+		{"0 0 29 2 *", windowLookahead, time.Time{}, false},
+		{"0 0 1 3 *", windowLookahead, time.Date(2026, 3, 1, 0, 0, 0, 0, msk), true},
+		{"0 1 * * 0", 24 * time.Hour, time.Time{}, false},
+	}
+	for _, tt := range tests {
+		s, err := ParseSchedule(tt.expr)
+		if err != nil {
+			t.Fatalf("ParseSchedule(%q): %v", tt.expr, err)
+		}
+		got, ok := s.NextStart(monday, tt.within)
+		if ok != tt.ok || !got.Equal(tt.want) {
+			t.Errorf("%q NextStart = %v, %v; want %v, %v", tt.expr, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestScheduledWindowBounds(t *testing.T) {
+	msk := time.FixedZone("MSK", 3*60*60)
+	monday := time.Date(2026, 1, 5, 10, 0, 0, 0, msk)
+	m, setNow := testMode(t, Settings{Windows: []Window{{Schedule: "0 1 * * 0", Duration: "3h"}}}, monday)
+
+	// Границы следующего окна вычисляются один раз и используются до его окончания
+	serve(m, "/userinfo")
+	bounds := m.Settings().Windows[0].bounds
+	if want := time.Date(2026, 1, 11, 1, 0, 0, 0, msk); !bounds.start.Equal(want) || !bounds.end.Equal(want.Add(3*time.Hour)) {
+		t.Fatalf("bounds = %v - %v, want %v", bounds.start, bounds.end, want)
+	}
+	setNow(time.Date(2026, 1, 11, 2, 0, 0, 0, msk))
+	if rec := serve(m, "/userinfo"); rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") != "7200" {
+		t.Errorf("inside window: status = %d, Retry-After %q", rec.Code, rec.Header().Get("Retry-After"))
+	}
+	if !bounds.from.Equal(monday) {
+		t.Errorf("bounds recomputed at %v", bounds.from)
+	}
+
+	// После окна и при переводе часов назад границы вычисляются заново
+	setNow(time.Date(2026, 1, 11, 4, 0, 0, 0, msk))
+	if rec := serve(m, "/userinfo"); rec.Code != http.StatusOK {
+		t.Errorf("after window: status = %d, want 200", rec.Code)
+	}
+	setNow(time.Date(2026, 1, 4, 1, 30, 0, 0, msk))
+	if rec := serve(m, "/userinfo"); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("clock moved back into a window: status = %d, want 503", rec.Code)
+	}
+}
+
+func TestOverlappingWindows(t *testing.T) {
+	now := time.Date(2026, 1, 5, 10, 10, 0, 0, time.UTC)
+	m, _ := testMode(t, Settings{Windows: []Window{{Schedule: "*/30 * * * *", Duration: "20m", Timezone: "UTC"}}}, now)
+	if rec := serve(m, "/userinfo"); rec.Header().Get("Retry-After") != "600" {
+		t.Errorf("Retry-After = %q, want 600", rec.Header().Get("Retry-After"))
+	}
+
+	// Окна каждые 30 минут по 45 минут идут без перерыва
+	m, _ = testMode(t, Settings{Windows: []Window{{Schedule: "*/30 * * * *", Duration: "45m", Timezone: "UTC"}}}, now)
+	if status := m.Status(); status.ActiveUntil == nil || status.ActiveUntil.Sub(now) < 300*24*time.Hour {
+		t.Errorf("ActiveUntil = %v, want the end of the continuous run", status.ActiveUntil)
+	}
+}
+
+func TestToggleWhileServing(t *testing.T) {
+	m := NewMode()
+	if err := m.Set(Settings{Windows: []Window{{Schedule: "*/2 * * * *", Duration: "1m"}}}); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	// Как DELETE /admin/maintenance: настройки читаются, меняются и применяются снова,
+	// пока запросы проверяют окна. Гонки ловит go test -race
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+					serve(m, "/userinfo")
+					m.Status()
+				}
+			}
+		}()
+	}
+	for i := 0; i < 200; i++ {
+		settings := m.Settings()
+		settings.Enabled = i%2 == 0
+		settings.Windows[0].Timezone = ""
+		if err := m.Set(settings); err != nil {
+			t.Fatalf("Set: %v", err)
+		}
+	}
+	close(stop)
+	wg.Wait()
+
+	if tz := m.Settings().Windows[0].Timezone; tz != DefaultTimezone {
+		t.Errorf("timezone = %q, want %q", tz, DefaultTimezone)
+	}
+}
+
+func TestInvalidSettings(t *testing.T) {
+	m := NewMode()
+	for _, settings := range []Settings{
+		{Enabled: true, Endpoints: []string{"admin"}},
+		{Windows: []Window{{Schedule: "0 1 * * 0", Duration: "0s"}}},
+		{Windows: []Window{{Schedule: "0 1 * *", Duration: "1h"}}},
+		{Windows: []Window{{Schedule: "0 1 * * 0", Duration: "1h", Timezone: "Mars/Olympus"}}},
+	} {
+		if err := m.Set(settings); err == nil {
+			t.Errorf("settings accepted: %+v", settings)
+		}
+	}
+}
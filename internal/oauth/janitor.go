@@ -0,0 +1,77 @@
+package oauth
+
+import (
+	"context"
+	"time"
+
+	"github.com/vibe-gaming/esia-mock/internal/logger"
+	"github.com/vibe-gaming/esia-mock/internal/stats"
+	"github.com/vibe-gaming/esia-mock/internal/storage"
+	"go.uber.org/zap"
+)
+
+// DefaultJanitorInterval период очистки истекших кодов и токенов
+const DefaultJanitorInterval = time.Minute
+
+var (
+	evictedCodes  = stats.New("evicted_codes")
+	evictedTokens = stats.New("evicted_tokens")
+)
+
+// RunJanitor периодически удаляет истекшие коды и токены, пока не отменен ctx
+func (s *Store) RunJanitor(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultJanitorInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			codes, tokens, err := s.Purge()
+			if err != nil {
+				logger.Error("Failed to purge expired codes and tokens", zap.Error(err))
+				continue
+			}
+			if codes > 0 || tokens > 0 {
+				logger.Debug("Expired codes and tokens evicted",
+					zap.Int("codes", codes),
+					zap.Int("tokens", tokens))
+			}
+		}
+	}
+}
+
+// Purge удаляет истекшие коды и токены и учитывает их в статистике
+func (s *Store) Purge() (codes, tokens int, err error) {
+	codes, err = s.backend.Purge(storage.BucketCodes)
+	if err != nil {
+		return 0, 0, err
+	}
+	evictedCodes.Add(int64(codes))
+
+	tokens, err = s.backend.Purge(storage.BucketTokens)
+	if err != nil {
+		return codes, 0, err
+	}
+	evictedTokens.Add(int64(tokens))
+
+	return codes, tokens, nil
+}
+
+// Counts возвращает число действующих кодов и токенов
+func (s *Store) Counts() (codes, tokens int, err error) {
+	codeEntries, err := s.backend.List(storage.BucketCodes)
+	if err != nil {
+		return 0, 0, err
+	}
+	tokenEntries, err := s.backend.List(storage.BucketTokens)
+	if err != nil {
+		return 0, 0, err
+	}
+	return len(codeEntries), len(tokenEntries), nil
+}
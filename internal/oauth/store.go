@@ -0,0 +1,379 @@
+package oauth
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/vibe-gaming/esia-mock/internal/storage"
+)
+
+// Сроки жизни по умолчанию
+const (
+	DefaultCodeTTL  = 5 * time.Minute
+	DefaultTokenTTL = time.Hour
+)
+
+// ErrInvalidCode код не выдавался, уже обменян или истек
+var ErrInvalidCode = errors.New("invalid authorization code")
+
+// ErrInvalidToken токен не выдавался, отозван или истек
+var ErrInvalidToken = errors.New("invalid access token")
+
+// AuthCode код авторизации, выданный после ввода телефона
+type AuthCode struct {
+	Code        string
+	ClientID    string
+	RedirectURI string
+	State       string
+	Scope       string
+	PhoneNumber string
+	Hint        storage.Hint // ограничения персоны из подсказки; пусто — персона телефона
+	Profile     string       // профиль среды, в которой выдан код; пусто — любой
+	CreatedAt   time.Time
+	ExpiresAt   time.Time
+}
+
+// Token выданный токен доступа
+type Token struct {
+	AccessToken  string
+	RefreshToken string
+	IDToken      string
+	ExpiresIn    int
+	TokenType    string
+	ClientID     string
+	Scope        string
+	PhoneNumber  string       // Номер телефона пользователя
+	Hint         storage.Hint // ограничения персоны из подсказки; пусто — персона телефона
+	Profile      string       // профиль среды, в которой выдан токен; пусто — действует в любой
+	CreatedAt    time.Time
+	ExpiresAt    time.Time
+}
+
+// CodeRequest параметры выдачи кода авторизации
+type CodeRequest struct {
+	ClientID    string
+	RedirectURI string
+	State       string
+	Scope       string
+	PhoneNumber string
+	Hint        storage.Hint
+}
+
+// Environment параметры выдачи кодов и токенов в профиле среды ЕСИА
+type Environment struct {
+	Profile  string
+	Issuer   string // iss в id_token
+	CodeTTL  time.Duration
+	TokenTTL time.Duration
+}
+
+// Store хранит состояние OAuth2 (коды авторизации и токены) в storage.Backend.
+// Все операции атомарны на уровне хранилища, поэтому обработчикам не нужны свои блокировки
+type Store struct {
+	backend  storage.Backend
+	codeTTL  time.Duration
+	tokenTTL time.Duration
+	profile  string
+	issuer   string
+}
+
+// NewStore создает хранилище; нулевые сроки жизни заменяются значениями по умолчанию
+func NewStore(backend storage.Backend, codeTTL, tokenTTL time.Duration) *Store {
+	if codeTTL <= 0 {
+		codeTTL = DefaultCodeTTL
+	}
+	if tokenTTL <= 0 {
+		tokenTTL = DefaultTokenTTL
+	}
+
+	return &Store{
+		backend:  backend,
+		codeTTL:  codeTTL,
+		tokenTTL: tokenTTL,
+	}
+}
+
+// In возвращает хранилище на том же backend, которое выдает коды и токены в профиле env.
+// Коды и токены других профилей в нем недействительны; нулевые сроки жизни не меняются
+func (s *Store) In(env Environment) *Store {
+	scoped := *s
+	scoped.profile = env.Profile
+	scoped.issuer = env.Issuer
+	if env.CodeTTL > 0 {
+		scoped.codeTTL = env.CodeTTL
+	}
+	if env.TokenTTL > 0 {
+		scoped.tokenTTL = env.TokenTTL
+	}
+	return &scoped
+}
+
+// inProfile проверяет, что код или токен выдан в профиле хранилища
+func (s *Store) inProfile(profile string) bool {
+	return s.profile == "" || profile == "" || profile == s.profile
+}
+
+// IssueCode выдает новый код авторизации
+func (s *Store) IssueCode(req CodeRequest) (*AuthCode, error) {
+	now := time.Now()
+	code := &AuthCode{
+		Code:        randomString(),
+		ClientID:    req.ClientID,
+		RedirectURI: req.RedirectURI,
+		State:       req.State,
+		Scope:       req.Scope,
+		PhoneNumber: req.PhoneNumber,
+		Hint:        req.Hint,
+		Profile:     s.profile,
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(s.codeTTL),
+	}
+
+	if err := storage.PutJSON(s.backend, storage.BucketCodes, code.Code, code, s.codeTTL); err != nil {
+		return nil, fmt.Errorf("save code: %w", err)
+	}
+	return code, nil
+}
+
+// ConsumeCode атомарно проверяет и удаляет код: код обменивается не более одного раза.
+// Если кода нет, возвращает ErrInvalidCode. Код другого профиля тоже удаляется
+// и дает ErrInvalidCode, как его предъявление в другой среде ЕСИА
+func (s *Store) ConsumeCode(code string) (*AuthCode, error) {
+	data, err := s.backend.Take(storage.BucketCodes, code)
+	if storage.IsNotFound(err) {
+		return nil, ErrInvalidCode
+	}
+	if err != nil {
+		return nil, fmt.Errorf("take code: %w", err)
+	}
+
+	var authCode AuthCode
+	if err := json.Unmarshal(data, &authCode); err != nil {
+		return nil, fmt.Errorf("decode code: %w", err)
+	}
+	if !s.inProfile(authCode.Profile) {
+		return nil, ErrInvalidCode
+	}
+	return &authCode, nil
+}
+
+// LookupCode возвращает действующий код, не обменивая его, или ErrInvalidCode
+func (s *Store) LookupCode(code string) (*AuthCode, error) {
+	var authCode AuthCode
+	err := storage.GetJSON(s.backend, storage.BucketCodes, code, &authCode)
+	if storage.IsNotFound(err) {
+		return nil, ErrInvalidCode
+	}
+	if err != nil {
+		return nil, fmt.Errorf("load code: %w", err)
+	}
+	if !s.inProfile(authCode.Profile) {
+		return nil, ErrInvalidCode
+	}
+	return &authCode, nil
+}
+
+// RevokeCode отзывает код; если кода нет, возвращает ErrInvalidCode
+func (s *Store) RevokeCode(code string) error {
+	_, err := s.ConsumeCode(code)
+	return err
+}
+
+// IssueToken выдает токен по обмененному коду
+func (s *Store) IssueToken(code *AuthCode) (*Token, error) {
+	now := time.Now()
+	token := &Token{
+		AccessToken:  randomString(),
+		RefreshToken: randomString(),
+		IDToken:      generateIDToken(now, s.tokenTTL, s.issuer),
+		ExpiresIn:    int(s.tokenTTL.Seconds()),
+		TokenType:    "Bearer",
+		ClientID:     code.ClientID,
+		Scope:        code.Scope,
+		PhoneNumber:  code.PhoneNumber,
+		Hint:         code.Hint,
+		Profile:      s.profile,
+		CreatedAt:    now,
+		ExpiresAt:    now.Add(s.tokenTTL),
+	}
+
+	if err := storage.PutJSON(s.backend, storage.BucketTokens, token.AccessToken, token, s.tokenTTL); err != nil {
+		return nil, fmt.Errorf("save token: %w", err)
+	}
+	return token, nil
+}
+
+// SaveToken сохраняет заранее заданный токен, например из фикстур. Незаполненные
+// поля выставляются как при обычной выдаче; нулевой ExpiresAt — стандартный срок жизни
+func (s *Store) SaveToken(token *Token) error {
+	now := time.Now()
+	if token.AccessToken == "" {
+		token.AccessToken = randomString()
+	}
+	if token.RefreshToken == "" {
+		token.RefreshToken = randomString()
+	}
+	if token.TokenType == "" {
+		token.TokenType = "Bearer"
+	}
+	if token.CreatedAt.IsZero() {
+		token.CreatedAt = now
+	}
+	if token.ExpiresAt.IsZero() {
+		token.ExpiresAt = now.Add(s.tokenTTL)
+	}
+
+	ttl := token.ExpiresAt.Sub(now)
+	if ttl <= 0 {
+		return fmt.Errorf("token already expired at %s", token.ExpiresAt.Format(time.RFC3339))
+	}
+	token.ExpiresIn = int(token.ExpiresAt.Sub(token.CreatedAt).Seconds())
+	if token.IDToken == "" {
+		token.IDToken = generateIDToken(token.CreatedAt, token.ExpiresAt.Sub(token.CreatedAt), s.issuer)
+	}
+
+	if err := storage.PutJSON(s.backend, storage.BucketTokens, token.AccessToken, token, ttl); err != nil {
+		return fmt.Errorf("save token: %w", err)
+	}
+	return nil
+}
+
+// LookupToken возвращает действующий токен или ErrInvalidToken; токен другого профиля недействителен
+func (s *Store) LookupToken(accessToken string) (*Token, error) {
+	var token Token
+	err := storage.GetJSON(s.backend, storage.BucketTokens, accessToken, &token)
+	if storage.IsNotFound(err) {
+		return nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, fmt.Errorf("load token: %w", err)
+	}
+	if !s.inProfile(token.Profile) {
+		return nil, ErrInvalidToken
+	}
+	return &token, nil
+}
+
+// Revoke отзывает токен; отзыв несуществующего токена не является ошибкой
+func (s *Store) Revoke(accessToken string) error {
+	return s.backend.Delete(storage.BucketTokens, accessToken)
+}
+
+// RevokeMatching удаляет коды и токены, для которых match возвращает true, и возвращает их число
+func (s *Store) RevokeMatching(match func(clientID, phoneNumber string) bool) (codes, tokens int, err error) {
+	allCodes, allTokens, err := s.Export()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	for _, code := range allCodes {
+		if !match(code.ClientID, code.PhoneNumber) {
+			continue
+		}
+		if err := s.backend.Delete(storage.BucketCodes, code.Code); err != nil {
+			return codes, tokens, err
+		}
+		codes++
+	}
+	for _, token := range allTokens {
+		if !match(token.ClientID, token.PhoneNumber) {
+			continue
+		}
+		if err := s.backend.Delete(storage.BucketTokens, token.AccessToken); err != nil {
+			return codes, tokens, err
+		}
+		tokens++
+	}
+	return codes, tokens, nil
+}
+
+// Export возвращает все действующие коды и токены
+func (s *Store) Export() ([]*AuthCode, []*Token, error) {
+	codeEntries, err := s.backend.List(storage.BucketCodes)
+	if err != nil {
+		return nil, nil, err
+	}
+	codes := make([]*AuthCode, 0, len(codeEntries))
+	for key, data := range codeEntries {
+		var code AuthCode
+		if err := json.Unmarshal(data, &code); err != nil {
+			return nil, nil, fmt.Errorf("decode code %s: %w", key, err)
+		}
+		codes = append(codes, &code)
+	}
+
+	tokenEntries, err := s.backend.List(storage.BucketTokens)
+	if err != nil {
+		return nil, nil, err
+	}
+	tokens := make([]*Token, 0, len(tokenEntries))
+	for key, data := range tokenEntries {
+		var token Token
+		if err := json.Unmarshal(data, &token); err != nil {
+			return nil, nil, fmt.Errorf("decode token %s: %w", key, err)
+		}
+		tokens = append(tokens, &token)
+	}
+
+	sort.Slice(codes, func(i, j int) bool { return codes[i].CreatedAt.Before(codes[j].CreatedAt) })
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i].CreatedAt.Before(tokens[j].CreatedAt) })
+	return codes, tokens, nil
+}
+
+// Import загружает коды и токены с сохранением их сроков действия; истекшие пропускаются.
+// При replace существующие коды и токены удаляются
+func (s *Store) Import(codes []*AuthCode, tokens []*Token, replace bool) error {
+	if replace {
+		if err := storage.Clear(s.backend, storage.BucketCodes); err != nil {
+			return err
+		}
+		if err := storage.Clear(s.backend, storage.BucketTokens); err != nil {
+			return err
+		}
+	}
+
+	now := time.Now()
+	for _, code := range codes {
+		if ttl := code.ExpiresAt.Sub(now); ttl > 0 {
+			if err := storage.PutJSON(s.backend, storage.BucketCodes, code.Code, code, ttl); err != nil {
+				return fmt.Errorf("save code: %w", err)
+			}
+		}
+	}
+	for _, token := range tokens {
+		if ttl := token.ExpiresAt.Sub(now); ttl > 0 {
+			if err := storage.PutJSON(s.backend, storage.BucketTokens, token.AccessToken, token, ttl); err != nil {
+				return fmt.Errorf("save token: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// randomString генерирует случайное значение для кодов и токенов
+func randomString() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return base64.URLEncoding.EncodeToString(b)
+}
+
+// generateIDToken простой мок JWT токена; iss указывается, если задан издатель
+func generateIDToken(now time.Time, ttl time.Duration, issuer string) string {
+	claims, _ := json.Marshal(struct {
+		Iss string `json:"iss,omitempty"`
+		Sub string `json:"sub"`
+		Aud string `json:"aud"`
+		Iat int64  `json:"iat"`
+		Exp int64  `json:"exp"`
+	}{issuer, "1000000001", "mock", now.Unix(), now.Add(ttl).Unix()})
+
+	header := base64.URLEncoding.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`))
+	payload := base64.URLEncoding.EncodeToString(claims)
+	signature := base64.URLEncoding.EncodeToString([]byte("mock_signature"))
+	return fmt.Sprintf("%s.%s.%s", header, payload, signature)
+}
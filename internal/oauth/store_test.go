@@ -0,0 +1,180 @@
+package oauth
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/vibe-gaming/esia-mock/internal/storage"
+)
+
+func TestConsumeCodeOnce(t *testing.T) {
+	s := NewStore(storage.NewMemoryBackend(), 0, 0)
+
+	code, err := s.IssueCode(CodeRequest{ClientID: "client", RedirectURI: "http://localhost/cb", PhoneNumber: "79991234567"})
+	if err != nil {
+		t.Fatalf("IssueCode: %v", err)
+	}
+
+	// Из конкурентных обменов одного кода успешен ровно один
+	var wg sync.WaitGroup
+	var consumed atomic.Int32
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			authCode, err := s.ConsumeCode(code.Code)
+			switch {
+			case err == nil:
+				consumed.Add(1)
+				if authCode.PhoneNumber != "79991234567" {
+					t.Errorf("consumed code phone = %q", authCode.PhoneNumber)
+				}
+			case !errors.Is(err, ErrInvalidCode):
+				t.Errorf("ConsumeCode: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if consumed.Load() != 1 {
+		t.Fatalf("code consumed %d times, want 1", consumed.Load())
+	}
+}
+
+func TestTokenLifecycle(t *testing.T) {
+	s := NewStore(storage.NewMemoryBackend(), 0, 0)
+
+	token, err := s.IssueToken(&AuthCode{ClientID: "client", Scope: "openid fullname", PhoneNumber: "79991234567"})
+	if err != nil {
+		t.Fatalf("IssueToken: %v", err)
+	}
+	if token.ExpiresIn != int(DefaultTokenTTL.Seconds()) {
+		t.Errorf("ExpiresIn = %d, want %d", token.ExpiresIn, int(DefaultTokenTTL.Seconds()))
+	}
+
+	found, err := s.LookupToken(token.AccessToken)
+	if err != nil {
+		t.Fatalf("LookupToken: %v", err)
+	}
+	if found.PhoneNumber != token.PhoneNumber || found.Scope != token.Scope {
+		t.Errorf("LookupToken = %+v, want %+v", found, token)
+	}
+
+	if err := s.Revoke(token.AccessToken); err != nil {
+		t.Fatalf("Revoke: %v", err)
+	}
+	if _, err := s.LookupToken(token.AccessToken); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("LookupToken after revoke: err = %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestLookupAndRevokeCode(t *testing.T) {
+	s := NewStore(storage.NewMemoryBackend(), 0, 0)
+
+	code, err := s.IssueCode(CodeRequest{ClientID: "client", PhoneNumber: "79991234567"})
+	if err != nil {
+		t.Fatalf("IssueCode: %v", err)
+	}
+
+	// Просмотр не обменивает код
+	for i := 0; i < 2; i++ {
+		if found, err := s.LookupCode(code.Code); err != nil || found.ClientID != "client" {
+			t.Fatalf("LookupCode = %+v, %v", found, err)
+		}
+	}
+
+	if err := s.RevokeCode(code.Code); err != nil {
+		t.Fatalf("RevokeCode: %v", err)
+	}
+	if _, err := s.ConsumeCode(code.Code); !errors.Is(err, ErrInvalidCode) {
+		t.Fatalf("ConsumeCode after revoke: err = %v, want ErrInvalidCode", err)
+	}
+	if err := s.RevokeCode(code.Code); !errors.Is(err, ErrInvalidCode) {
+		t.Fatalf("second RevokeCode: err = %v, want ErrInvalidCode", err)
+	}
+}
+
+func TestRevokeMatching(t *testing.T) {
+	s := NewStore(storage.NewMemoryBackend(), 0, 0)
+
+	for _, req := range []CodeRequest{
+		{ClientID: "a", PhoneNumber: "79990000001"},
+		{ClientID: "a", PhoneNumber: "79990000002"},
+		{ClientID: "b", PhoneNumber: "79990000001"},
+	} {
+		code, err := s.IssueCode(req)
+		if err != nil {
+			t.Fatalf("IssueCode: %v", err)
+		}
+		if _, err := s.IssueToken(code); err != nil {
+			t.Fatalf("IssueToken: %v", err)
+		}
+	}
+
+	codes, tokens, err := s.RevokeMatching(func(clientID, _ string) bool { return clientID == "a" })
+	if err != nil || codes != 2 || tokens != 2 {
+		t.Fatalf("RevokeMatching = %d, %d, %v; want 2, 2", codes, tokens, err)
+	}
+
+	remainingCodes, remainingTokens, err := s.Export()
+	if err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	if len(remainingCodes) != 1 || len(remainingTokens) != 1 || remainingTokens[0].ClientID != "b" {
+		t.Errorf("remaining codes %d, tokens %+v; want only client b", len(remainingCodes), remainingTokens)
+	}
+}
+
+func TestEnvironments(t *testing.T) {
+	s := NewStore(storage.NewMemoryBackend(), 0, 0)
+	test := s.In(Environment{Profile: "test", Issuer: "http://esia-portal1.test.gosuslugi.ru/", TokenTTL: 3 * time.Hour})
+	prod := s.In(Environment{Profile: "prod", Issuer: "http://esia.gosuslugi.ru/"})
+
+	code, err := test.IssueCode(CodeRequest{ClientID: "client", PhoneNumber: "79991234567"})
+	if err != nil {
+		t.Fatalf("IssueCode: %v", err)
+	}
+	if code.Profile != "test" || code.ExpiresAt.Sub(code.CreatedAt) != DefaultCodeTTL {
+		t.Errorf("code = %+v", code)
+	}
+
+	// Код тестовой среды не обменивается в продуктивной
+	if _, err := prod.LookupCode(code.Code); !errors.Is(err, ErrInvalidCode) {
+		t.Errorf("LookupCode in another profile: err = %v", err)
+	}
+	if _, err := test.ConsumeCode(code.Code); err != nil {
+		t.Fatalf("ConsumeCode: %v", err)
+	}
+
+	token, err := test.IssueToken(code)
+	if err != nil {
+		t.Fatalf("IssueToken: %v", err)
+	}
+	if token.Profile != "test" || token.ExpiresIn != int((3*time.Hour).Seconds()) {
+		t.Errorf("token = %+v", token)
+	}
+	payload, err := base64.URLEncoding.DecodeString(strings.Split(token.IDToken, ".")[1])
+	if err != nil || !strings.Contains(string(payload), `"iss":"http://esia-portal1.test.gosuslugi.ru/"`) {
+		t.Errorf("id_token payload = %s, %v", payload, err)
+	}
+
+	if _, err := prod.LookupToken(token.AccessToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("LookupToken in another profile: err = %v", err)
+	}
+	// Хранилище без профиля и токены без профиля видны во всех средах
+	if _, err := s.LookupToken(token.AccessToken); err != nil {
+		t.Errorf("LookupToken without profile: %v", err)
+	}
+	fixture := &Token{PhoneNumber: "79991234567"}
+	if err := s.SaveToken(fixture); err != nil {
+		t.Fatalf("SaveToken: %v", err)
+	}
+	if _, err := prod.LookupToken(fixture.AccessToken); err != nil {
+		t.Errorf("token without profile in prod: %v", err)
+	}
+}
@@ -0,0 +1,297 @@
+package profiles
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/vibe-gaming/esia-mock/internal/logger"
+	"github.com/vibe-gaming/esia-mock/internal/oauth"
+	"github.com/vibe-gaming/esia-mock/internal/stats"
+	"go.uber.org/zap"
+)
+
+// Встроенные профили
+const (
+	Test = "test" // тестовая среда ЕСИА, esia-portal1.test.gosuslugi.ru
+	Prod = "prod" // продуктивная среда ЕСИА, esia.gosuslugi.ru
+)
+
+var disabledRequests = stats.New("profile_disabled_requests")
+
+// Profile среда ЕСИА, которую эмулирует мок: издатель маркеров, сроки жизни, доступные
+// эндпоинты и детали ответов. Запрос обслуживается профилем по префиксу пути или заголовку Host
+type Profile struct {
+	Name   string `json:"name" yaml:"name"`
+	Issuer string `json:"issuer" yaml:"issuer"` // iss в id_token
+
+	Hosts      []string `json:"hosts,omitempty" yaml:"hosts,omitempty"`           // значения Host без порта
+	PathPrefix string   `json:"pathPrefix,omitempty" yaml:"pathPrefix,omitempty"` // например /prod
+
+	CodeTTL  string `json:"codeTtl,omitempty" yaml:"codeTtl,omitempty"`   // пусто — oauth.codeTtl
+	TokenTTL string `json:"tokenTtl,omitempty" yaml:"tokenTtl,omitempty"` // пусто — oauth.tokenTtl
+
+	// DisabledEndpoints эндпоинты, которых нет в среде: ответ 404; "*" в конце — префикс
+	DisabledEndpoints []string `json:"disabledEndpoints,omitempty" yaml:"disabledEndpoints,omitempty"`
+	// Sandbox форма входа помечена как тестовая и предлагает сценарии отказа
+	Sandbox bool `json:"sandbox" yaml:"sandbox"`
+
+	codeTTL  time.Duration
+	tokenTTL time.Duration
+}
+
+// Сроки жизни встроенного профиля prod. Профиль test берет сроки из настроек oauth
+const (
+	ProdCodeTTL  = "1m" // продуктивная среда ждет обмена кода недолго
+	ProdTokenTTL = "1h"
+)
+
+// SandboxEndpoints эндпоинты, которые есть только в тестовой среде: вход без формы
+var SandboxEndpoints = []string{"/admin/login", "/admin/login/token"}
+
+// Builtin встроенные профили тестовой и продуктивной сред. Тестовая берет сроки жизни
+// из настроек oauth и предлагает сценарии отказа; продуктивная выдает коды с коротким
+// сроком жизни и не поддерживает вход без формы. Профиль из настроек с тем же именем
+// заменяет встроенный целиком
+func Builtin() []Profile {
+	return []Profile{
+		{
+			Name:       Test,
+			Issuer:     "http://esia-portal1.test.gosuslugi.ru/",
+			Hosts:      []string{"esia-portal1.test.gosuslugi.ru"},
+			PathPrefix: "/test",
+			Sandbox:    true,
+		},
+		{
+			Name:              Prod,
+			Issuer:            "http://esia.gosuslugi.ru/",
+			Hosts:             []string{"esia.gosuslugi.ru"},
+			PathPrefix:        "/prod",
+			CodeTTL:           ProdCodeTTL,
+			TokenTTL:          ProdTokenTTL,
+			DisabledEndpoints: append([]string(nil), SandboxEndpoints...),
+		},
+	}
+}
+
+// Validate проверяет имя, издателя, префикс, сроки жизни и эндпоинты
+func (p *Profile) Validate() error {
+	if p.Name == "" {
+		return errors.New("profile name is required")
+	}
+	if u, err := url.Parse(p.Issuer); err != nil || !u.IsAbs() {
+		return fmt.Errorf("invalid issuer %q, expected an absolute URL", p.Issuer)
+	}
+	if p.PathPrefix != "" && (!strings.HasPrefix(p.PathPrefix, "/") || strings.HasSuffix(p.PathPrefix, "/")) {
+		return fmt.Errorf("invalid path prefix %q, expected a path such as /prod", p.PathPrefix)
+	}
+	for _, host := range p.Hosts {
+		if host == "" || strings.Contains(host, "/") {
+			return fmt.Errorf("invalid host %q", host)
+		}
+	}
+	for _, endpoint := range p.DisabledEndpoints {
+		if !strings.HasPrefix(endpoint, "/") {
+			return fmt.Errorf("invalid endpoint %q, expected a path", endpoint)
+		}
+	}
+
+	var err error
+	if p.codeTTL, err = parseTTL(p.CodeTTL); err != nil {
+		return fmt.Errorf("codeTtl: %w", err)
+	}
+	if p.tokenTTL, err = parseTTL(p.TokenTTL); err != nil {
+		return fmt.Errorf("tokenTtl: %w", err)
+	}
+	return nil
+}
+
+// parseTTL разбирает срок жизни; пустая строка — срок по умолчанию
+func parseTTL(value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	ttl, err := time.ParseDuration(value)
+	if err != nil || ttl <= 0 {
+		return 0, fmt.Errorf("invalid duration %q, expected a positive duration such as 5m", value)
+	}
+	return ttl, nil
+}
+
+// Environment параметры выдачи кодов и токенов профиля
+func (p *Profile) Environment() oauth.Environment {
+	return oauth.Environment{
+		Profile:  p.Name,
+		Issuer:   p.Issuer,
+		CodeTTL:  p.codeTTL,
+		TokenTTL: p.tokenTTL,
+	}
+}
+
+// Disabled проверяет, что эндпоинта нет в среде
+func (p *Profile) Disabled(path string) bool {
+	for _, endpoint := range p.DisabledEndpoints {
+		if prefix, ok := strings.CutSuffix(endpoint, "*"); ok {
+			if strings.HasPrefix(path, prefix) {
+				return true
+			}
+		} else if path == endpoint {
+			return true
+		}
+	}
+	return false
+}
+
+// Set профили, между которыми распределяются запросы
+type Set struct {
+	profiles []*Profile
+	fallback *Profile
+}
+
+// NewSet проверяет профили и объединяет их со встроенными. Запросы, не попавшие ни в один
+// профиль по префиксу или Host, обслуживает профиль defaultName
+func NewSet(configured []Profile, defaultName string) (*Set, error) {
+	list := Builtin()
+	names := map[string]bool{}
+	for _, p := range configured {
+		if names[p.Name] {
+			return nil, fmt.Errorf("profile %s is already defined", p.Name)
+		}
+		names[p.Name] = true
+
+		replaced := false
+		for i := range list {
+			if list[i].Name == p.Name {
+				list[i], replaced = p, true
+			}
+		}
+		if !replaced {
+			list = append(list, p)
+		}
+	}
+
+	s := &Set{}
+	hosts := map[string]string{}
+	prefixes := map[string]string{}
+	for i := range list {
+		p := &list[i]
+		if err := p.Validate(); err != nil {
+			return nil, fmt.Errorf("profile %s: %w", p.Name, err)
+		}
+
+		// Копия: имена хостов приводятся к нижнему регистру, не меняя настройки
+		p.Hosts = append([]string(nil), p.Hosts...)
+		for j, host := range p.Hosts {
+			host = strings.ToLower(host)
+			if owner, ok := hosts[host]; ok {
+				return nil, fmt.Errorf("profile %s: host %s is already used by profile %s", p.Name, host, owner)
+			}
+			hosts[host] = p.Name
+			p.Hosts[j] = host
+		}
+		if p.PathPrefix != "" {
+			if owner, ok := prefixes[p.PathPrefix]; ok {
+				return nil, fmt.Errorf("profile %s: path prefix %s is already used by profile %s", p.Name, p.PathPrefix, owner)
+			}
+			prefixes[p.PathPrefix] = p.Name
+		}
+
+		s.profiles = append(s.profiles, p)
+		if p.Name == defaultName {
+			s.fallback = p
+		}
+	}
+	if s.fallback == nil {
+		return nil, fmt.Errorf("unknown profile %q", defaultName)
+	}
+	return s, nil
+}
+
+// List возвращает профили: встроенные, затем добавленные в настройках
+func (s *Set) List() []*Profile {
+	return s.profiles
+}
+
+// Default возвращает профиль по умолчанию
+func (s *Set) Default() *Profile {
+	return s.fallback
+}
+
+// Select выбирает профиль запроса: сначала по префиксу пути, затем по Host, иначе профиль
+// по умолчанию. prefix — совпавший префикс пути, который нужно отрезать
+func (s *Set) Select(r *http.Request) (p *Profile, prefix string) {
+	for _, p := range s.profiles {
+		if p.PathPrefix != "" && (r.URL.Path == p.PathPrefix || strings.HasPrefix(r.URL.Path, p.PathPrefix+"/")) {
+			return p, p.PathPrefix
+		}
+	}
+
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(host)
+	for _, p := range s.profiles {
+		for _, h := range p.Hosts {
+			if h == host {
+				return p, ""
+			}
+		}
+	}
+	return s.fallback, ""
+}
+
+// Middleware выбирает профиль запроса, отрезает префикс пути и отвечает 404 на эндпоинты,
+// которых нет в среде. Профиль и префикс доступны обработчикам через FromContext и PrefixFromContext
+func (s *Set) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p, prefix := s.Select(r)
+
+		ctx := context.WithValue(r.Context(), selectionKey{}, selection{profile: p, prefix: prefix})
+		r = r.WithContext(ctx)
+		if prefix != "" {
+			u := *r.URL
+			u.Path = strings.TrimPrefix(u.Path, prefix)
+			u.RawPath = ""
+			if u.Path == "" {
+				u.Path = "/"
+			}
+			r.URL = &u
+		}
+
+		if p.Disabled(r.URL.Path) {
+			disabledRequests.Inc()
+			logger.Debug("Endpoint is not available in profile",
+				zap.String("profile", p.Name),
+				zap.String("path", r.URL.Path))
+			http.NotFound(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+type selectionKey struct{}
+
+// selection профиль запроса и отрезанный префикс пути
+type selection struct {
+	profile *Profile
+	prefix  string
+}
+
+// FromContext возвращает профиль запроса; nil — запрос прошел мимо Middleware
+func FromContext(ctx context.Context) *Profile {
+	sel, _ := ctx.Value(selectionKey{}).(selection)
+	return sel.profile
+}
+
+// PrefixFromContext возвращает отрезанный префикс пути: ссылки в ответах должны его сохранять
+func PrefixFromContext(ctx context.Context) string {
+	sel, _ := ctx.Value(selectionKey{}).(selection)
+	return sel.prefix
+}
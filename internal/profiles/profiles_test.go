@@ -0,0 +1,166 @@
+package profiles
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vibe-gaming/esia-mock/internal/logger"
+)
+
+func TestMain(m *testing.M) {
+	logger.Init("error")
+	os.Exit(m.Run())
+}
+
+func TestNewSet(t *testing.T) {
+	set, err := NewSet([]Profile{
+		{Name: Prod, Issuer: "https://esia.example.ru/", Hosts: []string{"ESIA.example.ru"}, TokenTTL: "30m"},
+		{Name: "stage", Issuer: "https://stage.example.ru/", PathPrefix: "/stage"},
+	}, Prod)
+	if err != nil {
+		t.Fatalf("NewSet: %v", err)
+	}
+
+	var names []string
+	for _, p := range set.List() {
+		names = append(names, p.Name)
+	}
+	if strings.Join(names, ",") != "test,prod,stage" {
+		t.Errorf("profiles = %v", names)
+	}
+
+	prod := set.Default()
+	if prod.Name != Prod || prod.Issuer != "https://esia.example.ru/" || prod.PathPrefix != "" {
+		t.Errorf("builtin prod not replaced: %+v", prod)
+	}
+	if env := prod.Environment(); env.TokenTTL != 30*time.Minute || env.CodeTTL != 0 || env.Profile != Prod {
+		t.Errorf("Environment = %+v", env)
+	}
+	if prod.Hosts[0] != "esia.example.ru" {
+		t.Errorf("hosts = %v, want lower case", prod.Hosts)
+	}
+}
+
+func TestNewSetInvalid(t *testing.T) {
+	tests := []struct {
+		name       string
+		profiles   []Profile
+		defaultKey string
+		want       string
+	}{
+		{"unknown default", nil, "stage", `unknown profile "stage"`},
+		{"no name", []Profile{{Issuer: "https://a/"}}, Test, "name is required"},
+		{"issuer", []Profile{{Name: "a", Issuer: "esia"}}, Test, "invalid issuer"},
+		{"prefix", []Profile{{Name: "a", Issuer: "https://a/", PathPrefix: "/a/"}}, Test, "invalid path prefix"},
+		{"ttl", []Profile{{Name: "a", Issuer: "https://a/", CodeTTL: "0s"}}, Test, "codeTtl"},
+		{"endpoint", []Profile{{Name: "a", Issuer: "https://a/", DisabledEndpoints: []string{"userinfo"}}}, Test, "invalid endpoint"},
+		{"duplicate name", []Profile{{Name: "a", Issuer: "https://a/"}, {Name: "a", Issuer: "https://a/"}}, Test, "already defined"},
+		{"duplicate prefix", []Profile{{Name: "a", Issuer: "https://a/", PathPrefix: "/prod"}}, Test, "already used by profile prod"},
+		{"duplicate host", []Profile{{Name: "a", Issuer: "https://a/", Hosts: []string{"esia.gosuslugi.ru"}}}, Test, "already used by profile prod"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewSet(tt.profiles, tt.defaultKey)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want mention of %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	set, err := NewSet([]Profile{
+		{Name: Prod, Issuer: "http://esia.gosuslugi.ru/", Hosts: []string{"esia.gosuslugi.ru"}, PathPrefix: "/prod",
+			DisabledEndpoints: []string{"/userinfo", "/admin/*"}},
+	}, Test)
+	if err != nil {
+		t.Fatalf("NewSet: %v", err)
+	}
+
+	var got, gotPrefix, gotPath string
+	handler := set.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = FromContext(r.Context()).Name
+		gotPrefix = PrefixFromContext(r.Context())
+		gotPath = r.URL.Path
+	}))
+
+	tests := []struct {
+		host, path             string
+		profile, prefix, strip string
+		status                 int
+	}{
+		{"localhost:8085", "/aas/oauth2/ac", Test, "", "/aas/oauth2/ac", http.StatusOK},
+		{"localhost:8085", "/prod/aas/oauth2/ac", Prod, "/prod", "/aas/oauth2/ac", http.StatusOK},
+		{"localhost:8085", "/test/rs/prns/1", Test, "/test", "/rs/prns/1", http.StatusOK},
+		{"localhost:8085", "/production/aas/oauth2/ac", Test, "", "/production/aas/oauth2/ac", http.StatusOK},
+		{"ESIA.gosuslugi.ru:443", "/aas/oauth2/te", Prod, "", "/aas/oauth2/te", http.StatusOK},
+		{"esia-portal1.test.gosuslugi.ru", "/aas/oauth2/te", Test, "", "/aas/oauth2/te", http.StatusOK},
+		// Префикс пути важнее Host
+		{"esia.gosuslugi.ru", "/test/aas/oauth2/te", Test, "/test", "/aas/oauth2/te", http.StatusOK},
+		{"localhost", "/prod/userinfo", "", "", "", http.StatusNotFound},
+		{"esia.gosuslugi.ru", "/admin/login", "", "", "", http.StatusNotFound},
+		{"localhost", "/userinfo", Test, "", "/userinfo", http.StatusOK},
+	}
+	for _, tt := range tests {
+		got, gotPrefix, gotPath = "", "", ""
+		req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		req.Host = tt.host
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if rec.Code != tt.status {
+			t.Errorf("%s%s: status %d, want %d", tt.host, tt.path, rec.Code, tt.status)
+		}
+		if got != tt.profile || gotPrefix != tt.prefix || gotPath != tt.strip {
+			t.Errorf("%s%s: profile %q, prefix %q, path %q; want %q, %q, %q",
+				tt.host, tt.path, got, gotPrefix, gotPath, tt.profile, tt.prefix, tt.strip)
+		}
+	}
+
+	if p := FromContext(httptest.NewRequest(http.MethodGet, "/", nil).Context()); p != nil {
+		t.Errorf("FromContext without middleware = %+v", p)
+	}
+}
+
+func TestBuiltin(t *testing.T) {
+	set, err := NewSet(nil, Test)
+	if err != nil {
+		t.Fatalf("NewSet: %v", err)
+	}
+	test, prod := set.List()[0], set.List()[1]
+
+	// Тестовая среда: сроки из настроек oauth, все эндпоинты, сценарии отказа
+	if env := test.Environment(); env.CodeTTL != 0 || env.TokenTTL != 0 || len(test.DisabledEndpoints) != 0 || !test.Sandbox {
+		t.Errorf("test: environment %+v, disabled %v, sandbox %v", env, test.DisabledEndpoints, test.Sandbox)
+	}
+	if env := prod.Environment(); env.CodeTTL != time.Minute || env.TokenTTL != time.Hour || prod.Sandbox {
+		t.Errorf("prod: environment %+v, sandbox %v", env, prod.Sandbox)
+	}
+	if test.Issuer == prod.Issuer {
+		t.Errorf("issuers are equal: %s", test.Issuer)
+	}
+
+	tests := []struct {
+		path           string
+		onTest, onProd int
+	}{
+		{"/admin/login", http.StatusOK, http.StatusNotFound},
+		{"/admin/login/token", http.StatusOK, http.StatusNotFound},
+		{"/admin/tokens", http.StatusOK, http.StatusOK},
+		{"/aas/oauth2/te", http.StatusOK, http.StatusOK},
+	}
+	handler := set.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	for _, tt := range tests {
+		for prefix, want := range map[string]int{"/test": tt.onTest, "/prod": tt.onProd} {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, prefix+tt.path, nil))
+			if rec.Code != want {
+				t.Errorf("%s%s: status %d, want %d", prefix, tt.path, rec.Code, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,281 @@
+package ratelimit
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vibe-gaming/esia-mock/internal/logger"
+	"github.com/vibe-gaming/esia-mock/internal/rules"
+	"github.com/vibe-gaming/esia-mock/internal/stats"
+	"go.uber.org/zap"
+)
+
+// Ответ на превышение лимита по умолчанию
+const (
+	DefaultError            = "too_many_requests"
+	DefaultErrorDescription = "ESIA-007017: Превышено допустимое число запросов, повторите попытку позже"
+)
+
+var throttledRequests = stats.New("throttled_requests")
+
+// Rule лимит запросов. Каждый client_id получает свое ведро токенов: ведро вмещает
+// burst запросов и пополняется со скоростью rate
+type Rule struct {
+	ID string `json:"id" yaml:"id,omitempty"`
+
+	// Условия
+	Endpoint string `json:"endpoint,omitempty" yaml:"endpoint,omitempty"` // путь; "*" в конце — префикс
+	ClientID string `json:"clientId,omitempty" yaml:"clientId,omitempty"` // пусто — любой клиент
+
+	// Лимит
+	Rate  string `json:"rate" yaml:"rate"`                       // например 10/s, 600/m, 1000/h
+	Burst int    `json:"burst,omitempty" yaml:"burst,omitempty"` // пусто — число запросов за секунду, но не меньше 1
+
+	// Ответ на превышение лимита
+	Status           int    `json:"status,omitempty" yaml:"status,omitempty"` // пусто — 429
+	Error            string `json:"error,omitempty" yaml:"error,omitempty"`
+	ErrorDescription string `json:"errorDescription,omitempty" yaml:"errorDescription,omitempty"`
+
+	// Throttled число отклоненных запросов
+	Throttled int `json:"throttled" yaml:"-"`
+
+	perSecond float64
+}
+
+// Validate проверяет правило, разбирает скорость и заполняет значения по умолчанию
+func (r *Rule) Validate() error {
+	perSecond, err := ParseRate(r.Rate)
+	if err != nil {
+		return err
+	}
+	r.perSecond = perSecond
+
+	if r.Burst < 0 {
+		return fmt.Errorf("invalid burst %d", r.Burst)
+	}
+	if r.Burst == 0 {
+		r.Burst = int(math.Max(1, math.Ceil(perSecond)))
+	}
+	if r.Status == 0 {
+		r.Status = http.StatusTooManyRequests
+	}
+	if r.Status < 400 || r.Status > 599 {
+		return fmt.Errorf("invalid status %d, expected an error status", r.Status)
+	}
+	if r.Error == "" {
+		r.Error = DefaultError
+		if r.ErrorDescription == "" {
+			r.ErrorDescription = DefaultErrorDescription
+		}
+	}
+	return nil
+}
+
+// ParseRate разбирает скорость вида N/s, N/m или N/h в запросы в секунду
+func ParseRate(rate string) (float64, error) {
+	count, unit, ok := strings.Cut(rate, "/")
+	n, err := strconv.ParseFloat(count, 64)
+	if !ok || err != nil || n <= 0 || math.IsNaN(n) || math.IsInf(n, 0) {
+		return 0, fmt.Errorf("invalid rate %q, expected a rate such as 10/s, 600/m or 1000/h", rate)
+	}
+
+	switch unit {
+	case "s":
+		return n, nil
+	case "m":
+		return n / 60, nil
+	case "h":
+		return n / 3600, nil
+	}
+	return 0, fmt.Errorf("invalid rate %q, expected a rate such as 10/s, 600/m or 1000/h", rate)
+}
+
+// matches проверяет, что правило относится к запросу
+func (r *Rule) matches(path, clientID string) bool {
+	return rules.MatchEndpoint(r.Endpoint, path) && (r.ClientID == "" || r.ClientID == clientID)
+}
+
+// Identity клиент и телефон, к которым относится запрос
+type Identity = rules.Identity
+
+// bucket ведро токенов клиента
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Limiter хранит правила и ведра клиентов
+type Limiter struct {
+	mu      sync.Mutex
+	rules   *rules.List[Rule]
+	buckets map[string]*bucket // ID правила + client_id -> ведро
+	now     func() time.Time
+}
+
+// NewLimiter создает ограничитель без правил
+func NewLimiter() *Limiter {
+	return &Limiter{
+		rules:   rules.NewList(func(r *Rule) *string { return &r.ID }),
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+// Add добавляет правило; без ID правило получает свободный порядковый номер
+func (l *Limiter) Add(rule Rule) (Rule, error) {
+	if err := rule.Validate(); err != nil {
+		return Rule{}, err
+	}
+	rule.Throttled = 0
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if err := l.rules.Add(&rule); err != nil {
+		return Rule{}, err
+	}
+	return rule, nil
+}
+
+// Remove удаляет правило по ID вместе с его ведрами
+func (l *Limiter) Remove(id string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !l.rules.Remove(id) {
+		return false
+	}
+	for key := range l.buckets {
+		if strings.HasPrefix(key, id+"\x00") {
+			delete(l.buckets, key)
+		}
+	}
+	return true
+}
+
+// Clear удаляет все правила
+func (l *Limiter) Clear() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.rules.Clear()
+	l.buckets = make(map[string]*bucket)
+}
+
+// Reset наполняет ведра всех клиентов и обнуляет счетчики отклоненных запросов; правила остаются
+func (l *Limiter) Reset() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, rule := range l.rules.Items() {
+		rule.Throttled = 0
+	}
+	l.buckets = make(map[string]*bucket)
+}
+
+// ResetClient наполняет ведра клиента по всем правилам
+func (l *Limiter) ResetClient(clientID string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for key := range l.buckets {
+		if _, client, _ := strings.Cut(key, "\x00"); client == clientID {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// List возвращает копии правил в порядке добавления
+func (l *Limiter) List() []Rule {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.rules.Copy()
+}
+
+// Middleware отклоняет запросы сверх лимита до обработчика next
+func (l *Limiter) Middleware(next http.Handler, identity Identity) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if rules.IsAdmin(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		rule, clientID, retryAfter, limited := l.take(r, identity)
+		if !limited {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		throttledRequests.Inc()
+		logger.Info("Request throttled",
+			zap.String("rule", rule.ID),
+			zap.String("client_id", clientID),
+			zap.String("path", r.URL.Path),
+			zap.Duration("retry_after", retryAfter))
+
+		body, _ := json.Marshal(map[string]string{
+			"error":             rule.Error,
+			"error_description": rule.ErrorDescription,
+		})
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		w.WriteHeader(rule.Status)
+		w.Write(body)
+	})
+}
+
+// active проверяет, что задано хотя бы одно правило
+func (l *Limiter) active() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.rules.Len() > 0
+}
+
+// take списывает запрос из ведер всех подходящих правил. Запрос отклоняется первым
+// правилом с пустым ведром; ведра остальных правил при этом не списываются. Клиент
+// определяется вне блокировки: identity может читать тело запроса и обращаться к хранилищу
+func (l *Limiter) take(r *http.Request, identity Identity) (Rule, string, time.Duration, bool) {
+	var clientID string
+	if identity != nil && l.active() {
+		clientID, _ = identity(r)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.rules.Len() == 0 {
+		return Rule{}, "", 0, false
+	}
+
+	now := l.now()
+	var taken []*bucket
+	for _, rule := range l.rules.Items() {
+		if !rule.matches(r.URL.Path, clientID) {
+			continue
+		}
+
+		key := rule.ID + "\x00" + clientID
+		b, ok := l.buckets[key]
+		if !ok {
+			b = &bucket{tokens: float64(rule.Burst), last: now}
+			l.buckets[key] = b
+		}
+		b.tokens = math.Min(float64(rule.Burst), b.tokens+now.Sub(b.last).Seconds()*rule.perSecond)
+		b.last = now
+
+		if b.tokens < 1 {
+			for _, t := range taken {
+				t.tokens++
+			}
+			rule.Throttled++
+			wait := time.Duration((1 - b.tokens) / rule.perSecond * float64(time.Second))
+			return *rule, clientID, max(wait, time.Second), true
+		}
+		b.tokens--
+		taken = append(taken, b)
+	}
+	return Rule{}, clientID, 0, false
+}
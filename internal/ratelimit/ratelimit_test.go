@@ -0,0 +1,230 @@
+package ratelimit
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/vibe-gaming/esia-mock/internal/logger"
+)
+
+func TestMain(m *testing.M) {
+	logger.Init("error")
+	os.Exit(m.Run())
+}
+
+// testLimiter ограничитель с управляемыми часами
+func testLimiter(t *testing.T, rules ...Rule) (*Limiter, func(time.Duration)) {
+	t.Helper()
+	l := NewLimiter()
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	l.now = func() time.Time { return now }
+	for _, rule := range rules {
+		if _, err := l.Add(rule); err != nil {
+			t.Fatalf("Add: %v", err)
+		}
+	}
+	return l, func(d time.Duration) { now = now.Add(d) }
+}
+
+// queryClient берет client_id из query-параметра
+func queryClient(r *http.Request) (string, string) {
+	return r.URL.Query().Get("client_id"), ""
+}
+
+func request(l *Limiter, url string) *httptest.ResponseRecorder {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	rec := httptest.NewRecorder()
+	l.Middleware(ok, queryClient).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, url, nil))
+	return rec
+}
+
+func TestTokenBucket(t *testing.T) {
+	l, advance := testLimiter(t, Rule{Endpoint: "/aas/oauth2/te", Rate: "2/s", Burst: 3})
+
+	for i := 0; i < 3; i++ {
+		if rec := request(l, "/aas/oauth2/te?client_id=a"); rec.Code != http.StatusOK {
+			t.Fatalf("request %d: status = %d, want 200", i+1, rec.Code)
+		}
+	}
+
+	rec := request(l, "/aas/oauth2/te?client_id=a")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want 429", rec.Code)
+	}
+	if rec.Header().Get("Retry-After") != "1" {
+		t.Errorf("Retry-After = %q, want 1", rec.Header().Get("Retry-After"))
+	}
+	var body map[string]string
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || body["error"] != DefaultError {
+		t.Errorf("body = %s", rec.Body)
+	}
+
+	// Другой клиент и другой путь не затронуты
+	if rec := request(l, "/aas/oauth2/te?client_id=b"); rec.Code != http.StatusOK {
+		t.Errorf("other client: status = %d, want 200", rec.Code)
+	}
+	if rec := request(l, "/userinfo?client_id=a"); rec.Code != http.StatusOK {
+		t.Errorf("other endpoint: status = %d, want 200", rec.Code)
+	}
+
+	// За полсекунды ведро пополняется на один запрос
+	advance(500 * time.Millisecond)
+	if rec := request(l, "/aas/oauth2/te?client_id=a"); rec.Code != http.StatusOK {
+		t.Errorf("after refill: status = %d, want 200", rec.Code)
+	}
+	if rec := request(l, "/aas/oauth2/te?client_id=a"); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("after refill: status = %d, want 429", rec.Code)
+	}
+
+	if rules := l.List(); rules[0].Throttled != 2 {
+		t.Errorf("throttled = %d, want 2", rules[0].Throttled)
+	}
+}
+
+func TestReset(t *testing.T) {
+	l, _ := testLimiter(t, Rule{Rate: "1/m", Burst: 1})
+	for _, client := range []string{"a", "b"} {
+		request(l, "/userinfo?client_id="+client)
+		if rec := request(l, "/userinfo?client_id="+client); rec.Code != http.StatusTooManyRequests {
+			t.Fatalf("client %s: status = %d, want 429", client, rec.Code)
+		}
+	}
+
+	// Сброс клиента наполняет только его ведра
+	l.ResetClient("a")
+	if rec := request(l, "/userinfo?client_id=a"); rec.Code != http.StatusOK {
+		t.Errorf("reset client: status = %d, want 200", rec.Code)
+	}
+	if rec := request(l, "/userinfo?client_id=b"); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("other client: status = %d, want 429", rec.Code)
+	}
+
+	l.Reset()
+	if rules := l.List(); len(rules) != 1 || rules[0].Throttled != 0 {
+		t.Errorf("rules after reset = %+v, want the rule with throttled 0", rules)
+	}
+	if rec := request(l, "/userinfo?client_id=b"); rec.Code != http.StatusOK {
+		t.Errorf("after reset: status = %d, want 200", rec.Code)
+	}
+}
+
+func TestRetryAfterAndCustomResponse(t *testing.T) {
+	l, _ := testLimiter(t, Rule{
+		ClientID:         "crm",
+		Rate:             "6/m",
+		Status:           http.StatusServiceUnavailable,
+		Error:            "temporarily_unavailable",
+		ErrorDescription: "throttled",
+	})
+
+	request(l, "/rs/prns/1?client_id=crm")
+	rec := request(l, "/rs/prns/1?client_id=crm")
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want 503", rec.Code)
+	}
+	if rec.Header().Get("Retry-After") != "10" {
+		t.Errorf("Retry-After = %q, want 10", rec.Header().Get("Retry-After"))
+	}
+	var body map[string]string
+	json.Unmarshal(rec.Body.Bytes(), &body)
+	if body["error"] != "temporarily_unavailable" || body["error_description"] != "throttled" {
+		t.Errorf("body = %v", body)
+	}
+
+	if rec := request(l, "/rs/prns/1?client_id=lk"); rec.Code != http.StatusOK {
+		t.Errorf("unlimited client: status = %d, want 200", rec.Code)
+	}
+}
+
+func TestAdminPathsSkipped(t *testing.T) {
+	l, _ := testLimiter(t, Rule{Rate: "1/h"})
+	for i := 0; i < 3; i++ {
+		if rec := request(l, "/admin/ratelimits"); rec.Code != http.StatusOK {
+			t.Fatalf("admin status = %d, want 200", rec.Code)
+		}
+	}
+}
+
+func TestIdentityResolvedOutsideLock(t *testing.T) {
+	l, _ := testLimiter(t, Rule{Endpoint: "/userinfo", Rate: "1/h"})
+
+	// identity может обращаться к ограничителю: вызов под блокировкой привел бы к взаимоблокировке
+	identity := func(r *http.Request) (string, string) {
+		return "crm" + strconv.Itoa(len(l.List())), ""
+	}
+	done := make(chan int)
+	go func() {
+		ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+		rec := httptest.NewRecorder()
+		l.Middleware(ok, identity).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/userinfo", nil))
+		done <- rec.Code
+	}()
+	select {
+	case status := <-done:
+		if status != http.StatusOK {
+			t.Errorf("status = %d, want 200", status)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("identity is called under the limiter lock")
+	}
+}
+
+func TestParseRate(t *testing.T) {
+	tests := []struct {
+		rate string
+		want float64
+		ok   bool
+	}{
+		{"10/s", 10, true},
+		{"120/m", 2, true},
+		{"3600/h", 1, true},
+		{"0.5/s", 0.5, true},
+		{"10", 0, false},
+		{"10/d", 0, false},
+		{"-1/s", 0, false},
+		{"NaN/s", 0, false},
+		{"nan/m", 0, false},
+		{"Inf/s", 0, false},
+		{"+Inf/h", 0, false},
+		{"1e400/s", 0, false},
+	}
+	for _, tt := range tests {
+		got, err := ParseRate(tt.rate)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("ParseRate(%q) = %v, %v", tt.rate, got, err)
+		}
+	}
+}
+
+func TestLimiterManagement(t *testing.T) {
+	l, _ := testLimiter(t)
+	rule, err := l.Add(Rule{Rate: "10/s"})
+	if err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if rule.Burst != 10 || rule.Status != http.StatusTooManyRequests || rule.Error != DefaultError {
+		t.Errorf("defaults not filled: %+v", rule)
+	}
+	if _, err := l.Add(Rule{ID: rule.ID, Rate: "1/s"}); err == nil {
+		t.Error("duplicate id accepted")
+	}
+	if _, err := l.Add(Rule{Rate: "1/s", Status: 200}); err == nil {
+		t.Error("success status accepted")
+	}
+
+	if !l.Remove(rule.ID) || l.Remove(rule.ID) {
+		t.Error("Remove should succeed once")
+	}
+
+	if _, err := l.Add(Rule{ID: "2", Rate: "1/s"}); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if generated, err := l.Add(Rule{Rate: "1/s"}); err != nil || generated.ID != "3" {
+		t.Errorf("generated id %q (%v), want 3: 1 was used, 2 is taken", generated.ID, err)
+	}
+}
@@ -0,0 +1,105 @@
+package rules
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// AdminPrefix пути admin API: на них правила не действуют, чтобы правило всегда можно было снять
+const AdminPrefix = "/admin/"
+
+// Identity определяет клиента и телефон, к которым относится запрос
+type Identity func(r *http.Request) (clientID, phone string)
+
+// IsAdmin проверяет, что запрос относится к admin API
+func IsAdmin(r *http.Request) bool {
+	return strings.HasPrefix(r.URL.Path, AdminPrefix)
+}
+
+// MatchEndpoint проверяет путь по условию правила: пусто — любой путь, "*" в конце — префикс
+func MatchEndpoint(endpoint, path string) bool {
+	if endpoint == "" {
+		return true
+	}
+	if prefix, ok := strings.CutSuffix(endpoint, "*"); ok {
+		return strings.HasPrefix(path, prefix)
+	}
+	return path == endpoint
+}
+
+// List правила в порядке добавления с уникальными ID. Блокировку обеспечивает владелец списка
+type List[T any] struct {
+	items  []*T
+	id     func(*T) *string
+	nextID int
+}
+
+// NewList создает пустой список; id возвращает указатель на поле ID правила
+func NewList[T any](id func(*T) *string) *List[T] {
+	return &List[T]{id: id}
+}
+
+// Add добавляет правило. Правило без ID получает порядковый номер, не занятый другими
+// правилами, в том числе заданными явно
+func (l *List[T]) Add(rule *T) error {
+	id := l.id(rule)
+	if *id == "" {
+		for {
+			l.nextID++
+			if l.find(strconv.Itoa(l.nextID)) < 0 {
+				break
+			}
+		}
+		*id = strconv.Itoa(l.nextID)
+	} else if l.find(*id) >= 0 {
+		return fmt.Errorf("rule %s already exists", *id)
+	}
+
+	l.items = append(l.items, rule)
+	return nil
+}
+
+// Remove удаляет правило по ID
+func (l *List[T]) Remove(id string) bool {
+	i := l.find(id)
+	if i < 0 {
+		return false
+	}
+	l.items = append(l.items[:i], l.items[i+1:]...)
+	return true
+}
+
+// Clear удаляет все правила
+func (l *List[T]) Clear() {
+	l.items = nil
+}
+
+// Len возвращает число правил
+func (l *List[T]) Len() int {
+	return len(l.items)
+}
+
+// Items возвращает правила для проверки запроса; изменения счетчиков сохраняются в списке
+func (l *List[T]) Items() []*T {
+	return l.items
+}
+
+// Copy возвращает копии правил в порядке добавления
+func (l *List[T]) Copy() []T {
+	result := make([]T, 0, len(l.items))
+	for _, rule := range l.items {
+		result = append(result, *rule)
+	}
+	return result
+}
+
+func (l *List[T]) find(id string) int {
+	for i, rule := range l.items {
+		if *l.id(rule) == id {
+			return i
+		}
+	}
+	return -1
+}
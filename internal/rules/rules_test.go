@@ -0,0 +1,70 @@
+package rules
+
+import (
+	"strings"
+	"testing"
+)
+
+type rule struct {
+	ID   string
+	Hits int
+}
+
+func newList() *List[rule] {
+	return NewList(func(r *rule) *string { return &r.ID })
+}
+
+func TestListIDs(t *testing.T) {
+	l := newList()
+	for _, id := range []string{"2", "", "", "custom", ""} {
+		if err := l.Add(&rule{ID: id}); err != nil {
+			t.Fatalf("Add(%q): %v", id, err)
+		}
+	}
+
+	var ids []string
+	for _, r := range l.Copy() {
+		ids = append(ids, r.ID)
+	}
+	// Автоматический номер пропускает занятый явно заданный ID
+	if got := strings.Join(ids, ","); got != "2,1,3,custom,4" {
+		t.Errorf("ids = %s, want 2,1,3,custom,4", got)
+	}
+
+	if err := l.Add(&rule{ID: "custom"}); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Errorf("duplicate ID: err = %v", err)
+	}
+	if !l.Remove("3") || l.Remove("3") || l.Len() != 4 {
+		t.Errorf("Remove: len = %d", l.Len())
+	}
+
+	// Копии не разделяют правила со списком, Items — разделяют
+	l.Copy()[0].Hits = 10
+	l.Items()[1].Hits = 5
+	if got := l.Copy(); got[0].Hits != 0 || got[1].Hits != 5 {
+		t.Errorf("hits = %d, %d; want 0, 5", got[0].Hits, got[1].Hits)
+	}
+
+	l.Clear()
+	if l.Len() != 0 {
+		t.Errorf("Len after Clear = %d", l.Len())
+	}
+}
+
+func TestMatchEndpoint(t *testing.T) {
+	tests := []struct {
+		endpoint, path string
+		want           bool
+	}{
+		{"", "/userinfo", true},
+		{"/userinfo", "/userinfo", true},
+		{"/userinfo", "/userinfo/x", false},
+		{"/rs/prns/*", "/rs/prns/1/docs", true},
+		{"/rs/prns/*", "/rs/other", false},
+	}
+	for _, tt := range tests {
+		if got := MatchEndpoint(tt.endpoint, tt.path); got != tt.want {
+			t.Errorf("MatchEndpoint(%q, %q) = %v, want %v", tt.endpoint, tt.path, got, tt.want)
+		}
+	}
+}
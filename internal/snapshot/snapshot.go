@@ -0,0 +1,106 @@
+package snapshot
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/vibe-gaming/esia-mock/internal/clients"
+	"github.com/vibe-gaming/esia-mock/internal/oauth"
+	"github.com/vibe-gaming/esia-mock/internal/storage"
+)
+
+// Version текущая версия формата снапшота. С версии 2 поля персон в camelCase, как в admin API.
+// Снапшоты версии 1 с полями в PascalCase загружаются: encoding/json сопоставляет имена
+// полей без учета регистра
+const Version = 2
+
+// Режимы импорта
+const (
+	ModeMerge   = "merge"   // записи снапшота добавляются к текущему состоянию, совпадающие заменяются
+	ModeReplace = "replace" // текущее состояние полностью заменяется снапшотом
+)
+
+// Document полное состояние мока
+type Document struct {
+	Version    int                          `json:"version"`
+	ExportedAt time.Time                    `json:"exportedAt"`
+	Personas   map[string]*storage.UserData `json:"personas"` // по номеру телефона
+	Overrides  map[string]*storage.Override `json:"overrides,omitempty"`
+	Codes      []*oauth.AuthCode            `json:"codes"`
+	Tokens     []*oauth.Token               `json:"tokens"`
+	Clients    []*clients.Client            `json:"clients"`
+}
+
+// Manager выгружает и загружает состояние мока
+type Manager struct {
+	users   *storage.Cache
+	oauth   *oauth.Store
+	clients *clients.Registry
+}
+
+// NewManager создает менеджер снапшотов
+func NewManager(users *storage.Cache, oauthStore *oauth.Store, registry *clients.Registry) *Manager {
+	return &Manager{
+		users:   users,
+		oauth:   oauthStore,
+		clients: registry,
+	}
+}
+
+// Export выгружает текущее состояние
+func (m *Manager) Export() (*Document, error) {
+	codes, tokens, err := m.oauth.Export()
+	if err != nil {
+		return nil, fmt.Errorf("export codes and tokens: %w", err)
+	}
+
+	clientList, err := m.clients.List()
+	if err != nil {
+		return nil, fmt.Errorf("export clients: %w", err)
+	}
+
+	overrides, err := m.users.Overrides()
+	if err != nil {
+		return nil, fmt.Errorf("export overrides: %w", err)
+	}
+
+	return &Document{
+		Version:    Version,
+		ExportedAt: time.Now(),
+		Personas:   m.users.GetAll(),
+		Overrides:  overrides,
+		Codes:      codes,
+		Tokens:     tokens,
+		Clients:    clientList,
+	}, nil
+}
+
+// Import загружает состояние в режиме ModeMerge или ModeReplace
+func (m *Manager) Import(doc *Document, mode string) error {
+	if doc.Version < 1 || doc.Version > Version {
+		return fmt.Errorf("unsupported snapshot version %d, supported: 1..%d", doc.Version, Version)
+	}
+
+	var replace bool
+	switch mode {
+	case "", ModeMerge:
+	case ModeReplace:
+		replace = true
+	default:
+		return fmt.Errorf("unknown import mode %q", mode)
+	}
+
+	if err := m.users.Import(doc.Personas, replace); err != nil {
+		return fmt.Errorf("import personas: %w", err)
+	}
+	if err := m.users.ImportOverrides(doc.Overrides, replace); err != nil {
+		return fmt.Errorf("import overrides: %w", err)
+	}
+	if err := m.oauth.Import(doc.Codes, doc.Tokens, replace); err != nil {
+		return fmt.Errorf("import codes and tokens: %w", err)
+	}
+	if err := m.clients.Import(doc.Clients, replace); err != nil {
+		return fmt.Errorf("import clients: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package snapshot
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/vibe-gaming/esia-mock/internal/clients"
+	"github.com/vibe-gaming/esia-mock/internal/oauth"
+	"github.com/vibe-gaming/esia-mock/internal/storage"
+)
+
+func newManager(t *testing.T) (*Manager, *storage.Cache, *oauth.Store) {
+	t.Helper()
+
+	backend := storage.NewMemoryBackend()
+	users, err := storage.New(storage.Options{Seed: "snapshot", Backend: backend})
+	if err != nil {
+		t.Fatalf("storage.New: %v", err)
+	}
+	store := oauth.NewStore(backend, 0, 0)
+	return NewManager(users, store, clients.NewRegistry(backend)), users, store
+}
+
+func TestExportImportRoundTrip(t *testing.T) {
+	src, users, store := newManager(t)
+
+	user := users.GetOrCreate("79161234567")
+	code, err := store.IssueCode(oauth.CodeRequest{ClientID: "c", PhoneNumber: "79161234567"})
+	if err != nil {
+		t.Fatalf("IssueCode: %v", err)
+	}
+	token, err := store.IssueToken(code)
+	if err != nil {
+		t.Fatalf("IssueToken: %v", err)
+	}
+
+	doc, err := src.Export()
+	if err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	data, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+
+	dst, dstUsers, dstStore := newManager(t)
+	dstUsers.GetOrCreate("79990000000") // должен исчезнуть при replace
+
+	var decoded Document
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if err := dst.Import(&decoded, ModeReplace); err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+
+	if n := dstUsers.Count(); n != 1 {
+		t.Errorf("Count = %d, want 1", n)
+	}
+	if got := dstUsers.GetOrCreate("79161234567"); got.OID != user.OID {
+		t.Errorf("persona OID = %s, want %s", got.OID, user.OID)
+	}
+	if _, err := dstStore.LookupToken(token.AccessToken); err != nil {
+		t.Errorf("LookupToken: %v", err)
+	}
+	if _, err := dstStore.ConsumeCode(code.Code); err != nil {
+		t.Errorf("ConsumeCode: %v", err)
+	}
+}
+
+func TestImportRejectsUnknownVersion(t *testing.T) {
+	m, _, _ := newManager(t)
+	if err := m.Import(&Document{Version: Version + 1}, ModeMerge); err == nil {
+		t.Error("Import accepted unsupported version")
+	}
+}
+
+func TestImportVersion1(t *testing.T) {
+	m, users, _ := newManager(t)
+
+	// Снапшот версии 1: поля персоны в PascalCase
+	data := `{"version":1,"personas":{"79161234567":{
+		"OID":"1000000042","FirstName":"Анна","BirthDate":"15.03.2010","Mobile":"+79161234567",
+		"Documents":[{"ID":"1","Type":"RF_PASSPORT","Series":"4510","IssueID":"770-001"}],
+		"Addresses":[{"ID":"1","ZipCode":"101000"}],
+		"GeneratorVersion":1,"Hint":{"Age":15,"Gender":"F"}}}}`
+	var doc Document
+	if err := json.Unmarshal([]byte(data), &doc); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if err := m.Import(&doc, ModeMerge); err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+
+	user, ok := users.Get("79161234567")
+	if !ok {
+		t.Fatal("persona not imported")
+	}
+	if user.OID != "1000000042" || user.FirstName != "Анна" || user.GeneratorVersion != 1 ||
+		user.Documents[0].IssueID != "770-001" || user.Addresses[0].ZipCode != "101000" ||
+		user.Hint == nil || user.Hint.Age != 15 {
+		t.Errorf("persona = %+v", user)
+	}
+
+	// Выгрузка — в camelCase
+	exported, err := json.Marshal(user)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	if !strings.Contains(string(exported), `"oid":"1000000042","firstName":"Анна"`) ||
+		!strings.Contains(string(exported), `"hint":{"age":15,"gender":"F"}`) {
+		t.Errorf("exported persona = %s", exported)
+	}
+}
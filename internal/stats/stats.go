@@ -0,0 +1,70 @@
+package stats
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// Counter монотонный счетчик событий
+type Counter struct {
+	value atomic.Int64
+}
+
+// Add увеличивает счетчик на n
+func (c *Counter) Add(n int64) {
+	c.value.Add(n)
+}
+
+// Inc увеличивает счетчик на единицу
+func (c *Counter) Inc() {
+	c.value.Add(1)
+}
+
+// Value возвращает текущее значение
+func (c *Counter) Value() int64 {
+	return c.value.Load()
+}
+
+var (
+	registry = map[string]*Counter{}
+	mu       sync.RWMutex
+)
+
+// New регистрирует именованный счетчик; повторный вызов с тем же именем возвращает тот же счетчик
+func New(name string) *Counter {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if c, ok := registry[name]; ok {
+		return c
+	}
+	c := &Counter{}
+	registry[name] = c
+	return c
+}
+
+// Snapshot возвращает значения всех счетчиков
+func Snapshot() map[string]int64 {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	result := make(map[string]int64, len(registry))
+	for name, c := range registry {
+		result[name] = c.Value()
+	}
+	return result
+}
+
+// Names возвращает имена счетчиков по алфавиту
+func Names() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
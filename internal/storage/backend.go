@@ -0,0 +1,107 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Бакеты хранилища
+const (
+	BucketCodes     = "codes"     // коды авторизации
+	BucketTokens    = "tokens"    // выданные токены
+	BucketUsers     = "users"     // персоны по номеру телефона
+	BucketClients   = "clients"   // зарегистрированные клиенты
+	BucketOverrides = "overrides" // явно заданные поля персон по номеру телефона
+)
+
+// Типы хранилищ
+const (
+	BackendMemory = "memory" // в памяти процесса, живет до перезапуска
+	BackendBolt   = "bolt"   // файл встроенной БД bbolt, переживает перезапуск
+	BackendRedis  = "redis"  // Redis, общее состояние для нескольких реплик
+)
+
+// ErrNotFound запись не найдена или истекла
+var ErrNotFound = errors.New("not found")
+
+// Backend хранилище ключ-значение с разбиением на бакеты. Значения — сериализованные
+// записи; ttl > 0 задает срок жизни записи, после которого она считается отсутствующей
+type Backend interface {
+	Get(bucket, key string) ([]byte, error)
+	Put(bucket, key string, value []byte, ttl time.Duration) error
+	// Take атомарно читает и удаляет запись: из двух конкурентных вызовов
+	// значение получит только один, второй — ErrNotFound
+	Take(bucket, key string) ([]byte, error)
+	Delete(bucket, key string) error
+	List(bucket string) (map[string][]byte, error)
+	// Purge удаляет истекшие записи бакета и возвращает их число
+	Purge(bucket string) (int, error)
+	// Ping проверяет, что хранилище доступно
+	Ping() error
+	Close() error
+}
+
+// Open открывает хранилище указанного типа; path — путь к файлу для bolt или URL для redis
+func Open(kind, path string) (Backend, error) {
+	switch kind {
+	case "", BackendMemory:
+		return NewMemoryBackend(), nil
+	case BackendBolt:
+		return OpenBoltBackend(path)
+	case BackendRedis:
+		return OpenRedisBackend(path)
+	}
+	return nil, fmt.Errorf("unknown storage backend %q", kind)
+}
+
+// expiresAt переводит ttl в момент истечения; нулевое время — бессрочно
+func expiresAt(ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(ttl)
+}
+
+// expired проверяет, истек ли срок жизни записи
+func expired(at time.Time) bool {
+	return !at.IsZero() && time.Now().After(at)
+}
+
+// PutJSON сериализует значение и сохраняет его в бакет
+func PutJSON(backend Backend, bucket, key string, value interface{}, ttl time.Duration) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return backend.Put(bucket, key, data, ttl)
+}
+
+// GetJSON читает значение из бакета; отсутствие записи — ErrNotFound
+func GetJSON(backend Backend, bucket, key string, value interface{}) error {
+	data, err := backend.Get(bucket, key)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, value)
+}
+
+// Clear удаляет все записи бакета
+func Clear(backend Backend, bucket string) error {
+	entries, err := backend.List(bucket)
+	if err != nil {
+		return err
+	}
+	for key := range entries {
+		if err := backend.Delete(bucket, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// IsNotFound проверяет, что ошибка означает отсутствие записи
+func IsNotFound(err error) bool {
+	return errors.Is(err, ErrNotFound)
+}
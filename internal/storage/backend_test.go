@@ -0,0 +1,149 @@
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+// testBackend проверяет общий контракт Backend. wait продвигает время хранилища;
+// nativeTTL означает, что истекшие записи хранилище удаляет само и Purge их не находит
+func testBackend(t *testing.T, b Backend, wait func(time.Duration), nativeTTL bool) {
+	t.Helper()
+
+	if err := b.Ping(); err != nil {
+		t.Fatalf("Ping: %v", err)
+	}
+	if _, err := b.Get(BucketCodes, "missing"); !IsNotFound(err) {
+		t.Fatalf("Get missing: err = %v, want ErrNotFound", err)
+	}
+
+	if err := b.Put(BucketCodes, "a", []byte("1"), 0); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if err := b.Put(BucketCodes, "b", []byte("2"), 0); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if err := b.Put(BucketTokens, "a", []byte("other bucket"), 0); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	if v, err := b.Get(BucketCodes, "a"); err != nil || string(v) != "1" {
+		t.Fatalf("Get = %q, %v; want \"1\"", v, err)
+	}
+
+	if err := b.Put(BucketCodes, "once", []byte("3"), 0); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if v, err := b.Take(BucketCodes, "once"); err != nil || string(v) != "3" {
+		t.Fatalf("Take = %q, %v; want \"3\"", v, err)
+	}
+	if _, err := b.Take(BucketCodes, "once"); !IsNotFound(err) {
+		t.Fatalf("second Take: err = %v, want ErrNotFound", err)
+	}
+
+	if err := b.Delete(BucketCodes, "a"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := b.Get(BucketCodes, "a"); !IsNotFound(err) {
+		t.Fatalf("Get deleted: err = %v, want ErrNotFound", err)
+	}
+
+	entries, err := b.List(BucketCodes)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(entries) != 1 || string(entries["b"]) != "2" {
+		t.Fatalf("List = %v, want only b", entries)
+	}
+
+	if err := b.Put(BucketTokens, "short", []byte("x"), 50*time.Millisecond); err != nil {
+		t.Fatalf("Put with ttl: %v", err)
+	}
+	wait(100 * time.Millisecond)
+	if _, err := b.Get(BucketTokens, "short"); !IsNotFound(err) {
+		t.Fatalf("Get expired: err = %v, want ErrNotFound", err)
+	}
+
+	wantPurged := 1
+	if nativeTTL {
+		wantPurged = 0
+	}
+	if n, err := b.Purge(BucketTokens); err != nil || n != wantPurged {
+		t.Fatalf("Purge = %d, %v; want %d expired entries", n, err, wantPurged)
+	}
+	if v, err := b.Get(BucketTokens, "a"); err != nil || string(v) != "other bucket" {
+		t.Fatalf("Purge removed a live entry: %q, %v", v, err)
+	}
+}
+
+func TestMemoryBackend(t *testing.T) {
+	testBackend(t, NewMemoryBackend(), time.Sleep, false)
+}
+
+func TestBoltBackend(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "esia-mock.db")
+
+	b, err := OpenBoltBackend(path)
+	if err != nil {
+		t.Fatalf("OpenBoltBackend: %v", err)
+	}
+	testBackend(t, b, time.Sleep, false)
+
+	// Данные переживают переоткрытие файла
+	if err := b.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if err := b.Ping(); err == nil {
+		t.Error("Ping succeeded on a closed database")
+	}
+	b, err = OpenBoltBackend(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer b.Close()
+
+	if v, err := b.Get(BucketCodes, "b"); err != nil || string(v) != "2" {
+		t.Fatalf("Get after reopen = %q, %v; want \"2\"", v, err)
+	}
+}
+
+func TestRedisBackend(t *testing.T) {
+	srv := miniredis.RunT(t)
+
+	b, err := OpenRedisBackend("redis://" + srv.Addr() + "/0")
+	if err != nil {
+		t.Fatalf("OpenRedisBackend: %v", err)
+	}
+	defer b.Close()
+
+	testBackend(t, b, srv.FastForward, true)
+}
+
+func TestRedisBackendSharedBetweenReplicas(t *testing.T) {
+	srv := miniredis.RunT(t)
+
+	first, err := OpenRedisBackend("redis://" + srv.Addr())
+	if err != nil {
+		t.Fatalf("OpenRedisBackend: %v", err)
+	}
+	defer first.Close()
+	second, err := OpenRedisBackend("redis://" + srv.Addr())
+	if err != nil {
+		t.Fatalf("OpenRedisBackend: %v", err)
+	}
+	defer second.Close()
+
+	// Код, выданный одной репликой, обменивается на другой ровно один раз
+	if err := first.Put(BucketCodes, "code", []byte("payload"), time.Minute); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if v, err := second.Take(BucketCodes, "code"); err != nil || string(v) != "payload" {
+		t.Fatalf("Take on second replica = %q, %v; want \"payload\"", v, err)
+	}
+	if _, err := first.Take(BucketCodes, "code"); !IsNotFound(err) {
+		t.Fatalf("Take on first replica: err = %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,176 @@
+package storage
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// BoltBackend хранилище в файле встроенной БД bbolt: состояние переживает перезапуск.
+// Каждое значение хранится с 8-байтовым префиксом — моментом истечения в UnixNano (0 — бессрочно)
+type BoltBackend struct {
+	db *bolt.DB
+}
+
+// OpenBoltBackend открывает (или создает) файл БД
+func OpenBoltBackend(path string) (*BoltBackend, error) {
+	if path == "" {
+		return nil, fmt.Errorf("bolt storage path is empty")
+	}
+
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("open bolt db %s: %w", path, err)
+	}
+
+	return &BoltBackend{db: db}, nil
+}
+
+func (b *BoltBackend) Get(bucket, key string) ([]byte, error) {
+	var value []byte
+	err := b.db.View(func(tx *bolt.Tx) error {
+		bkt := tx.Bucket([]byte(bucket))
+		if bkt == nil {
+			return ErrNotFound
+		}
+
+		raw := bkt.Get([]byte(key))
+		v, ok := decodeBoltValue(raw)
+		if !ok {
+			return ErrNotFound
+		}
+		value = v
+		return nil
+	})
+	return value, err
+}
+
+func (b *BoltBackend) Put(bucket, key string, value []byte, ttl time.Duration) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		bkt, err := tx.CreateBucketIfNotExists([]byte(bucket))
+		if err != nil {
+			return err
+		}
+		return bkt.Put([]byte(key), encodeBoltValue(value, expiresAt(ttl)))
+	})
+}
+
+func (b *BoltBackend) Take(bucket, key string) ([]byte, error) {
+	var value []byte
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		bkt := tx.Bucket([]byte(bucket))
+		if bkt == nil {
+			return ErrNotFound
+		}
+
+		raw := bkt.Get([]byte(key))
+		if raw == nil {
+			return ErrNotFound
+		}
+		v, ok := decodeBoltValue(raw)
+		if err := bkt.Delete([]byte(key)); err != nil {
+			return err
+		}
+		if !ok {
+			return ErrNotFound
+		}
+		value = v
+		return nil
+	})
+	return value, err
+}
+
+func (b *BoltBackend) Delete(bucket, key string) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		bkt := tx.Bucket([]byte(bucket))
+		if bkt == nil {
+			return nil
+		}
+		return bkt.Delete([]byte(key))
+	})
+}
+
+func (b *BoltBackend) List(bucket string) (map[string][]byte, error) {
+	result := make(map[string][]byte)
+	err := b.db.View(func(tx *bolt.Tx) error {
+		bkt := tx.Bucket([]byte(bucket))
+		if bkt == nil {
+			return nil
+		}
+		return bkt.ForEach(func(k, raw []byte) error {
+			if v, ok := decodeBoltValue(raw); ok {
+				result[string(k)] = v
+			}
+			return nil
+		})
+	})
+	return result, err
+}
+
+func (b *BoltBackend) Purge(bucket string) (int, error) {
+	purged := 0
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		bkt := tx.Bucket([]byte(bucket))
+		if bkt == nil {
+			return nil
+		}
+
+		// Удалять во время ForEach нельзя: сначала собираем ключи
+		var keys [][]byte
+		err := bkt.ForEach(func(k, raw []byte) error {
+			if _, ok := decodeBoltValue(raw); !ok {
+				keys = append(keys, append([]byte(nil), k...))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, k := range keys {
+			if err := bkt.Delete(k); err != nil {
+				return err
+			}
+		}
+		purged = len(keys)
+		return nil
+	})
+	return purged, err
+}
+
+// Ping открывает пустую транзакцию: после Close она вернет ошибку
+func (b *BoltBackend) Ping() error {
+	return b.db.View(func(tx *bolt.Tx) error { return nil })
+}
+
+func (b *BoltBackend) Close() error {
+	return b.db.Close()
+}
+
+// encodeBoltValue добавляет к значению момент истечения
+func encodeBoltValue(value []byte, expiresAt time.Time) []byte {
+	buf := make([]byte, 8+len(value))
+	if !expiresAt.IsZero() {
+		binary.BigEndian.PutUint64(buf, uint64(expiresAt.UnixNano()))
+	}
+	copy(buf[8:], value)
+	return buf
+}
+
+// decodeBoltValue возвращает копию значения, если запись существует и не истекла.
+// Копия нужна, т.к. память bbolt действительна только внутри транзакции
+func decodeBoltValue(raw []byte) ([]byte, bool) {
+	if len(raw) < 8 {
+		return nil, false
+	}
+
+	if at := binary.BigEndian.Uint64(raw); at != 0 && expired(time.Unix(0, int64(at))) {
+		return nil, false
+	}
+
+	value := make([]byte, len(raw)-8)
+	copy(value, raw[8:])
+	return value, true
+}
@@ -1,52 +1,201 @@
 package storage
 
 import (
-	"crypto/sha256"
-	"encoding/hex"
+	"container/list"
+	"encoding/json"
 	"fmt"
-	"strconv"
-	"strings"
+	"sort"
 	"sync"
+
+	"github.com/vibe-gaming/esia-mock/internal/logger"
+	"github.com/vibe-gaming/esia-mock/internal/stats"
+	"go.uber.org/zap"
 )
 
 // UserData содержит моковые данные пользователя
 type UserData struct {
-	OID         string
-	FirstName   string
-	LastName    string
-	MiddleName  string
-	BirthDate   string
-	Gender      string
-	SNILS       string
-	INN         string
-	Email       string
-	Mobile      string
-	Trusted     bool
-	Verified    bool
-	Citizenship string
-	Status      string
-}
-
-// Cache хранит связь между номерами телефонов и моковыми данными пользователей
+	OID         string     `json:"oid"`
+	FirstName   string     `json:"firstName"`
+	LastName    string     `json:"lastName"`
+	MiddleName  string     `json:"middleName"`
+	BirthDate   string     `json:"birthDate"`
+	Gender      string     `json:"gender"`
+	SNILS       string     `json:"snils"`
+	INN         string     `json:"inn"`
+	Email       string     `json:"email"`
+	Mobile      string     `json:"mobile"`
+	Trusted     bool       `json:"trusted"`
+	Verified    bool       `json:"verified"`
+	Citizenship string     `json:"citizenship"`
+	Status      string     `json:"status"`
+	Documents   []Document `json:"documents"`
+	Addresses   []Address  `json:"addresses"`
+	Region      string     `json:"region,omitempty"` // код субъекта РФ (с версии генерации v2)
+
+	// GeneratorVersion версия алгоритма, которым сгенерирована персона
+	GeneratorVersion int `json:"generatorVersion"`
+	// Hint ограничения, с которыми создана персона; nil — персона построена только по телефону.
+	// Такую персону нельзя восстановить по одному телефону, поэтому она не вытесняется
+	Hint *Hint `json:"hint,omitempty"`
+}
+
+// Типы документов, удостоверяющих личность, в терминах ЕСИА
+const (
+	DocTypeRFPassport = "RF_PASSPORT" // паспорт гражданина РФ
+	DocTypeForeign    = "FID_DOC"     // документ иностранного гражданина
+)
+
+// CitizenshipRUS код гражданства РФ (ISO 3166-1 alpha-3)
+const CitizenshipRUS = "RUS"
+
+// Document содержит моковые данные документа, удостоверяющего личность
+type Document struct {
+	ID           string `json:"id"`
+	Type         string `json:"type"`
+	Series       string `json:"series"`
+	Number       string `json:"number"`
+	IssueDate    string `json:"issueDate"`
+	IssueID      string `json:"issueId,omitempty"` // код подразделения (только для паспорта РФ)
+	IssuedBy     string `json:"issuedBy"`
+	ExpiryDate   string `json:"expiryDate,omitempty"`
+	IssueCountry string `json:"issueCountry,omitempty"`
+	Verified     bool   `json:"verified"`
+}
+
+// Типы адресов в терминах ЕСИА
+const (
+	AddrTypeRegistration = "PRG" // адрес регистрации
+	AddrTypeResidence    = "PLV" // адрес проживания
+)
+
+// Address содержит моковый адрес пользователя
+type Address struct {
+	ID      string `json:"id"`
+	Type    string `json:"type"`
+	Region  string `json:"region"` // наименование субъекта РФ
+	City    string `json:"city"`
+	Street  string `json:"street"`
+	House   string `json:"house"`
+	Flat    string `json:"flat"`
+	ZipCode string `json:"zipCode"`
+}
+
+// String возвращает адрес одной строкой
+func (a Address) String() string {
+	return fmt.Sprintf("%s, %s, %s, ул. %s, д. %s, кв. %s", a.ZipCode, a.Region, a.City, a.Street, a.House, a.Flat)
+}
+
+// IsForeign возвращает true для персон без гражданства РФ
+func (u *UserData) IsForeign() bool {
+	return u.Citizenship != CitizenshipRUS
+}
+
+// Address возвращает адрес пользователя по ID
+func (u *UserData) Address(id string) (*Address, bool) {
+	for i := range u.Addresses {
+		if u.Addresses[i].ID == id {
+			return &u.Addresses[i], true
+		}
+	}
+	return nil, false
+}
+
+// Document возвращает документ пользователя по ID
+func (u *UserData) Document(id string) (*Document, bool) {
+	for i := range u.Documents {
+		if u.Documents[i].ID == id {
+			return &u.Documents[i], true
+		}
+	}
+	return nil, false
+}
+
+// Cache хранит связь между номерами телефонов и моковыми данными пользователей.
+// Персоны лежат в Backend, поэтому при файловом хранилище переживают перезапуск
 type Cache struct {
-	users map[string]*UserData
-	mu    sync.RWMutex
+	backend Backend
+	seed    string // соль генерации, своя для каждого окружения
+	version int    // версия алгоритма генерации
+	mu      sync.Mutex
+
+	// LRU персон при ограничении их числа: телефон -> элемент списка, в начале списка — свежие
+	maxUsers int
+	lru      *list.List
+	lruIndex map[string]*list.Element
+	lruMu    sync.Mutex
+}
+
+var evictedPersonas = stats.New("evicted_personas")
+
+// Options настройки генерации персон
+type Options struct {
+	// Seed подмешивается в хеш телефона: при одинаковом seed данные воспроизводимы,
+	// при разных — различаются. Пустой seed сохраняет исходную генерацию от sha256(phone)
+	Seed string
+	// GeneratorVersion версия алгоритма генерации; 0 — DefaultGeneratorVersion
+	GeneratorVersion int
+	// Backend хранилище персон; nil — в памяти процесса
+	Backend Backend
+	// MaxUsers ограничивает число хранимых персон; при превышении вытесняются
+	// давно не использованные (LRU). 0 — без ограничения
+	MaxUsers int
 }
 
 // New создает новый кеш
-func New() *Cache {
-	return &Cache{
-		users: make(map[string]*UserData),
+func New(opts Options) (*Cache, error) {
+	version, err := resolveGeneratorVersion(opts.GeneratorVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	backend := opts.Backend
+	if backend == nil {
+		backend = NewMemoryBackend()
+	}
+
+	c := &Cache{
+		backend:  backend,
+		seed:     opts.Seed,
+		version:  version,
+		maxUsers: opts.MaxUsers,
+		lru:      list.New(),
+		lruIndex: make(map[string]*list.Element),
+	}
+
+	// Персоны, уже лежащие в файловом хранилище, тоже участвуют в вытеснении. Время
+	// использования не хранится, поэтому LRU заполняется в порядке телефонов: при каждом
+	// запуске первыми вытесняются одни и те же персоны
+	if c.maxUsers > 0 {
+		entries, err := backend.List(BucketUsers)
+		if err != nil {
+			return nil, err
+		}
+		phones := make([]string, 0, len(entries))
+		for phone := range entries {
+			phones = append(phones, phone)
+		}
+		sort.Strings(phones)
+		for _, phone := range phones {
+			c.touch(phone)
+		}
 	}
+
+	return c, nil
+}
+
+// Seed возвращает соль генерации
+func (c *Cache) Seed() string {
+	return c.seed
+}
+
+// GeneratorVersion возвращает версию алгоритма генерации
+func (c *Cache) GeneratorVersion() int {
+	return c.version
 }
 
 // GetOrCreate возвращает существующие данные для телефона или создает новые
 func (c *Cache) GetOrCreate(phoneNumber string) *UserData {
-	c.mu.RLock()
-	user, exists := c.users[phoneNumber]
-	c.mu.RUnlock()
-
-	if exists {
+	if user, exists := c.load(phoneNumber); exists {
 		return user
 	}
 
@@ -54,149 +203,165 @@ func (c *Cache) GetOrCreate(phoneNumber string) *UserData {
 	defer c.mu.Unlock()
 
 	// Проверяем еще раз (double-check locking)
-	if user, exists := c.users[phoneNumber]; exists {
+	if user, exists := c.load(phoneNumber); exists {
 		return user
 	}
 
 	// Создаем уникальные данные на основе номера телефона
-	user = c.generateUserData(phoneNumber)
-	c.users[phoneNumber] = user
+	user := c.generateUserData(phoneNumber)
+	c.applyOverride(phoneNumber, user)
+	c.save(phoneNumber, user)
 
 	return user
 }
 
-// generateUserData генерирует уникальные моковые данные на основе номера телефона
-func (c *Cache) generateUserData(phoneNumber string) *UserData {
-	// Используем хеш телефона для генерации детерминированных, но уникальных данных
-	hash := sha256.Sum256([]byte(phoneNumber))
-	hashStr := hex.EncodeToString(hash[:])
-
-	// Генерируем OID из первых 10 символов хеша
-	oidNum := parseHexToNumber(hashStr[:10])
-	oid := fmt.Sprintf("%d", 1000000000+oidNum%1000000000)
-
-	// Имена из разных наборов в зависимости от хеша
-	firstNames := []string{"Иван", "Петр", "Сергей", "Александр", "Дмитрий", "Андрей", "Михаил", "Алексей", "Николай", "Владимир"}
-	lastNames := []string{"Иванов", "Петров", "Сидоров", "Смирнов", "Кузнецов", "Попов", "Васильев", "Павлов", "Соколов", "Михайлов"}
-	middleNames := []string{"Иванович", "Петрович", "Сергеевич", "Александрович", "Дмитриевич", "Андреевич", "Михайлович", "Алексеевич", "Николаевич", "Владимирович"}
-
-	firstNameIdx := int(hash[0]) % len(firstNames)
-	lastNameIdx := int(hash[1]) % len(lastNames)
-	middleNameIdx := int(hash[2]) % len(middleNames)
-
-	// Генерируем дату рождения (годы 1970-2000)
-	year := 1970 + (int(hash[3]) % 31)
-	month := 1 + (int(hash[4]) % 12)
-	day := 1 + (int(hash[5]) % 28) // безопасное значение для любого месяца
-	birthDate := fmt.Sprintf("%02d.%02d.%d", day, month, year)
-
-	// Пол (M или F)
-	gender := "M"
-	if hash[6]%2 == 0 {
-		gender = "F"
-		// Для женщин используем женские окончания отчеств
-		femaleMiddleNames := []string{"Ивановна", "Петровна", "Сергеевна", "Александровна", "Дмитриевна", "Андреевна", "Михайловна", "Алексеевна", "Николаевна", "Владимировна"}
-		middleNames = femaleMiddleNames
-		middleNameIdx = int(hash[2]) % len(middleNames)
-
-		// Женские фамилии с окончанием -ова/-ева
-		lastNames = []string{"Иванова", "Петрова", "Сидорова", "Смирнова", "Кузнецова", "Попова", "Васильева", "Павлова", "Соколова", "Михайлова"}
-		lastNameIdx = int(hash[1]) % len(lastNames)
-
-		// Женские имена
-		firstNames = []string{"Мария", "Анна", "Елена", "Ольга", "Татьяна", "Наталья", "Ирина", "Светлана", "Екатерина", "Юлия"}
-		firstNameIdx = int(hash[0]) % len(firstNames)
-	}
-
-	// Генерируем SNILS (11 цифр)
-	snilsNum := parseHexToNumber(hashStr[10:21])
-	snils := fmt.Sprintf("%011d", snilsNum%100000000000)
-
-	// Генерируем ИНН (12 цифр)
-	innNum := parseHexToNumber(hashStr[21:33])
-	inn := fmt.Sprintf("%012d", innNum%1000000000000)
-
-	// Email на основе имени и хеша (транслитерация + lowercase)
-	emailHash := hashStr[33:40]
-	email := strings.ToLower(fmt.Sprintf("%s.%s.%s@example.com",
-		transliterate(firstNames[firstNameIdx]),
-		transliterate(lastNames[lastNameIdx]),
-		emailHash))
-
-	return &UserData{
-		OID:         oid,
-		FirstName:   firstNames[firstNameIdx],
-		LastName:    lastNames[lastNameIdx],
-		MiddleName:  middleNames[middleNameIdx],
-		BirthDate:   birthDate,
-		Gender:      gender,
-		SNILS:       snils,
-		INN:         inn,
-		Email:       email,
-		Mobile:      phoneNumber,
-		Trusted:     hash[7]%2 == 0, // 50% trusted
-		Verified:    hash[8]%3 != 0, // ~66% verified
-		Citizenship: "RUS",
-		Status:      "REGISTERED",
-	}
-}
-
-// parseHexToNumber парсит hex строку в uint64
-func parseHexToNumber(hexStr string) uint64 {
-	num, err := strconv.ParseUint(hexStr, 16, 64)
-	if err != nil {
-		return 0
+// Get возвращает сохраненную персону, не создавая новую
+func (c *Cache) Get(phoneNumber string) (*UserData, bool) {
+	return c.load(phoneNumber)
+}
+
+// Peek возвращает сохраненную персону или ту, что будет сгенерирована для телефона, не сохраняя ее
+func (c *Cache) Peek(phoneNumber string) *UserData {
+	if user, exists := c.load(phoneNumber); exists {
+		return user
 	}
-	return num
-}
-
-// transliterate простая транслитерация русских имен в латиницу
-func transliterate(name string) string {
-	translit := map[rune]string{
-		// Заглавные
-		'А': "A", 'Б': "B", 'В': "V", 'Г': "G", 'Д': "D",
-		'Е': "E", 'Ё': "Yo", 'Ж': "Zh", 'З': "Z", 'И': "I",
-		'Й': "Y", 'К': "K", 'Л': "L", 'М': "M", 'Н': "N",
-		'О': "O", 'П': "P", 'Р': "R", 'С': "S", 'Т': "T",
-		'У': "U", 'Ф': "F", 'Х': "H", 'Ц': "Ts", 'Ч': "Ch",
-		'Ш': "Sh", 'Щ': "Sch", 'Ъ': "", 'Ы': "Y", 'Ь': "",
-		'Э': "E", 'Ю': "Yu", 'Я': "Ya",
-		// Строчные
-		'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d",
-		'е': "e", 'ё': "yo", 'ж': "zh", 'з': "z", 'и': "i",
-		'й': "y", 'к': "k", 'л': "l", 'м': "m", 'н': "n",
-		'о': "o", 'п': "p", 'р': "r", 'с': "s", 'т': "t",
-		'у': "u", 'ф': "f", 'х': "h", 'ц': "ts", 'ч': "ch",
-		'ш': "sh", 'щ': "sch", 'ъ': "", 'ы': "y", 'ь': "",
-		'э': "e", 'ю': "yu", 'я': "ya",
-	}
-
-	result := ""
-	for _, r := range name {
-		if t, ok := translit[r]; ok {
-			result += t
-		} else {
-			result += string(r)
+	user := c.generateUserData(phoneNumber)
+	c.applyOverride(phoneNumber, user)
+	return user
+}
+
+// load читает персону из хранилища. Ошибки хранилища не фатальны:
+// персона будет сгенерирована заново, генерация детерминирована
+func (c *Cache) load(phoneNumber string) (*UserData, bool) {
+	var user UserData
+	if err := GetJSON(c.backend, BucketUsers, phoneNumber, &user); err != nil {
+		if !IsNotFound(err) {
+			logger.Error("Failed to load user", zap.String("phone", phoneNumber), zap.Error(err))
 		}
+		return nil, false
+	}
+	c.touch(phoneNumber)
+	return &user, true
+}
+
+// save сохраняет персону в хранилище
+func (c *Cache) save(phoneNumber string, user *UserData) {
+	if err := PutJSON(c.backend, BucketUsers, phoneNumber, user, 0); err != nil {
+		logger.Error("Failed to save user", zap.String("phone", phoneNumber), zap.Error(err))
+		return
+	}
+	c.touch(phoneNumber)
+}
+
+// touch отмечает использование персоны и вытесняет лишние при ограничении MaxUsers.
+// Вытеснение безопасно: при следующем входе персона будет сгенерирована заново
+func (c *Cache) touch(phoneNumber string) {
+	if c.maxUsers <= 0 {
+		return
+	}
+
+	c.lruMu.Lock()
+	defer c.lruMu.Unlock()
+
+	if el, ok := c.lruIndex[phoneNumber]; ok {
+		c.lru.MoveToFront(el)
+		return
+	}
+	c.lruIndex[phoneNumber] = c.lru.PushFront(phoneNumber)
+
+	for c.lru.Len() > c.maxUsers {
+		oldest := c.lru.Back()
+		phone := oldest.Value.(string)
+		c.lru.Remove(oldest)
+		delete(c.lruIndex, phone)
+
+		if err := c.backend.Delete(BucketUsers, phone); err != nil {
+			logger.Error("Failed to evict user", zap.String("phone", phone), zap.Error(err))
+			continue
+		}
+		evictedPersonas.Inc()
+	}
+}
+
+// untrack убирает телефон из LRU; вызывается под lruMu
+func (c *Cache) untrack(phoneNumber string) {
+	if el, ok := c.lruIndex[phoneNumber]; ok {
+		c.lru.Remove(el)
+		delete(c.lruIndex, phoneNumber)
 	}
-	return result
 }
 
 // GetAll возвращает все сохраненные данные (для отладки)
 func (c *Cache) GetAll() map[string]*UserData {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
+	entries, err := c.backend.List(BucketUsers)
+	if err != nil {
+		logger.Error("Failed to list users", zap.Error(err))
+		return map[string]*UserData{}
+	}
 
-	result := make(map[string]*UserData, len(c.users))
-	for k, v := range c.users {
-		result[k] = v
+	result := make(map[string]*UserData, len(entries))
+	for phone, data := range entries {
+		var user UserData
+		if err := json.Unmarshal(data, &user); err != nil {
+			logger.Error("Failed to decode user", zap.String("phone", phone), zap.Error(err))
+			continue
+		}
+		result[phone] = &user
 	}
 	return result
 }
 
+// Import загружает персоны по номерам телефонов; при replace существующие персоны удаляются
+func (c *Cache) Import(users map[string]*UserData, replace bool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if replace {
+		if err := Clear(c.backend, BucketUsers); err != nil {
+			return err
+		}
+		c.lruMu.Lock()
+		c.lru.Init()
+		c.lruIndex = make(map[string]*list.Element)
+		c.lruMu.Unlock()
+	}
+
+	for phone, user := range users {
+		if err := PutJSON(c.backend, BucketUsers, phone, user, 0); err != nil {
+			return err
+		}
+		c.touch(phone)
+	}
+	return nil
+}
+
+// Reset удаляет все персоны и их заданные поля и возвращает число удаленных персон
+func (c *Cache) Reset() (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	count := c.Count()
+	if err := Clear(c.backend, BucketUsers); err != nil {
+		return 0, err
+	}
+	if err := Clear(c.backend, BucketOverrides); err != nil {
+		return 0, err
+	}
+
+	c.lruMu.Lock()
+	c.lru.Init()
+	c.lruIndex = make(map[string]*list.Element)
+	c.lruMu.Unlock()
+
+	return count, nil
+}
+
 // Count возвращает количество сохраненных пользователей
 func (c *Cache) Count() int {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
-	return len(c.users)
+	entries, err := c.backend.List(BucketUsers)
+	if err != nil {
+		logger.Error("Failed to list users", zap.Error(err))
+		return 0
+	}
+	return len(entries)
 }
@@ -0,0 +1,113 @@
+package storage
+
+import (
+	"testing"
+)
+
+func TestCacheMaxUsersEvictsLeastRecentlyUsed(t *testing.T) {
+	c, err := New(Options{MaxUsers: 2})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	c.GetOrCreate("79990000001")
+	evicted := c.GetOrCreate("79990000002")
+	c.GetOrCreate("79990000001") // свежее использование: вытеснен будет второй
+	c.GetOrCreate("79990000003")
+
+	if n := c.Count(); n != 2 {
+		t.Fatalf("Count = %d, want 2", n)
+	}
+	users := c.GetAll()
+	if _, ok := users["79990000002"]; ok {
+		t.Error("least recently used persona was not evicted")
+	}
+	if _, ok := users["79990000001"]; !ok {
+		t.Error("recently used persona was evicted")
+	}
+
+	// Вытесненная персона генерируется заново детерминированно
+	if again := c.GetOrCreate("79990000002"); again.OID != evicted.OID || again.FirstName != evicted.FirstName {
+		t.Errorf("regenerated persona = %s %s, want %s %s", again.OID, again.FirstName, evicted.OID, evicted.FirstName)
+	}
+}
+
+func TestCacheMaxUsersRestoresHintedPersonas(t *testing.T) {
+	c, err := New(Options{MaxUsers: 1})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	hint := Hint{Age: 15, Gender: "F"}
+	hinted := c.GetOrCreateWithHint("79990000001", hint)
+	if hinted.Hint == nil || *hinted.Hint != hint {
+		t.Fatalf("persona hint = %+v", hinted.Hint)
+	}
+	c.GetOrCreate("79990000002")
+
+	// Персона по подсказке выводится из телефона и подсказки, поэтому переживает вытеснение
+	if _, ok := c.GetAll()["79990000001"]; ok {
+		t.Error("persona was not evicted")
+	}
+	if again := c.GetOrCreateWithHint("79990000001", hint); again.OID != hinted.OID || again.BirthDate != hinted.BirthDate || again.FirstName != hinted.FirstName {
+		t.Errorf("regenerated hinted persona = %s %s %s, want %s %s %s",
+			again.OID, again.FirstName, again.BirthDate, hinted.OID, hinted.FirstName, hinted.BirthDate)
+	}
+}
+
+func TestCacheMaxUsersTracksStoredPersonas(t *testing.T) {
+	backend := NewMemoryBackend()
+	previous, err := New(Options{Backend: backend})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	for _, phone := range []string{"79990000001", "79990000002", "79990000003"} {
+		previous.GetOrCreate(phone)
+	}
+
+	// Персоны предыдущего запуска (bolt, redis) учитываются в ограничении сразу
+	c, err := New(Options{Backend: backend, MaxUsers: 2})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if n := c.Count(); n != 2 {
+		t.Errorf("Count after restart = %d, want 2", n)
+	}
+	c.GetOrCreate("79990000004")
+	if n := c.Count(); n != 2 {
+		t.Errorf("Count = %d, want 2", n)
+	}
+}
+
+func TestCacheMaxUsersSeedsInPhoneOrder(t *testing.T) {
+	// Порядок обхода map случаен: несколько запусков должны вытеснять одно и то же
+	for i := 0; i < 10; i++ {
+		backend := NewMemoryBackend()
+		previous, err := New(Options{Backend: backend})
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+		for _, phone := range []string{"79990000003", "79990000001", "79990000004", "79990000002"} {
+			previous.GetOrCreate(phone)
+		}
+
+		c, err := New(Options{Backend: backend, MaxUsers: 2})
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+		users := c.GetAll()
+		if _, ok := users["79990000003"]; !ok || len(users) != 2 {
+			t.Fatalf("kept %v, want 79990000003 and 79990000004", users)
+		}
+
+		// Следующим вытесняется наименьший из оставшихся телефонов
+		c.GetOrCreate("79990000005")
+		users = c.GetAll()
+		if _, ok := users["79990000003"]; ok {
+			t.Fatalf("79990000003 kept instead of being evicted first")
+		}
+		if _, ok := users["79990000004"]; !ok {
+			t.Fatalf("79990000004 evicted out of order")
+		}
+	}
+}
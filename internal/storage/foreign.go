@@ -0,0 +1,167 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+)
+
+// foreignShare доля иностранных граждан среди сгенерированных персон (в процентах)
+const foreignShare = 12
+
+// foreignDocBaseYear самый поздний год выдачи паспорта иностранного гражданина;
+// фиксирован, чтобы генерация не зависела от текущей даты
+const foreignDocBaseYear = 2024
+
+// country описывает страну гражданства иностранной персоны
+type country struct {
+	Code        string // ISO 3166-1 alpha-3
+	Weight      int    // относительная частота среди иностранцев
+	DocPrefix   string // буквенная часть номера паспорта
+	IssuedBy    string
+	MaleNames   []string
+	FemaleNames []string
+	LastNames   []string
+	// FemaleLastNames задаются для стран, где фамилия изменяется по роду
+	FemaleLastNames []string
+}
+
+// countries распределение гражданств примерно соответствует статистике миграционного учета.
+// Таблица входит в алгоритм генерации v1: для изменений заведите новую версию
+var countries = []country{
+	{
+		Code: "UZB", Weight: 30, DocPrefix: "FA", IssuedBy: "MIA OF THE REPUBLIC OF UZBEKISTAN",
+		MaleNames:       []string{"Aziz", "Bekzod", "Jasur", "Sardor", "Rustam", "Timur", "Otabek", "Sherzod"},
+		FemaleNames:     []string{"Dilnoza", "Gulnora", "Madina", "Nigora", "Shahnoza", "Zarina", "Malika", "Feruza"},
+		LastNames:       []string{"Karimov", "Rakhimov", "Yusupov", "Tashkentov", "Abdullaev", "Ismoilov", "Nazarov", "Saidov"},
+		FemaleLastNames: []string{"Karimova", "Rakhimova", "Yusupova", "Tashkentova", "Abdullaeva", "Ismoilova", "Nazarova", "Saidova"},
+	},
+	{
+		Code: "TJK", Weight: 20, DocPrefix: "A", IssuedBy: "MIA OF THE REPUBLIC OF TAJIKISTAN",
+		MaleNames:       []string{"Firuz", "Davron", "Farhod", "Jamshed", "Parviz", "Sukhrob", "Umed", "Behruz"},
+		FemaleNames:     []string{"Farangis", "Manizha", "Nilufar", "Parvina", "Sitora", "Tahmina", "Zebo", "Mehrona"},
+		LastNames:       []string{"Rahmonov", "Sharipov", "Davlatov", "Safarov", "Kurbonov", "Mirzoev", "Nabiev", "Odinaev"},
+		FemaleLastNames: []string{"Rahmonova", "Sharipova", "Davlatova", "Safarova", "Kurbonova", "Mirzoeva", "Nabieva", "Odinaeva"},
+	},
+	{
+		Code: "KGZ", Weight: 12, DocPrefix: "AC", IssuedBy: "SRS KR",
+		MaleNames:       []string{"Aibek", "Bakyt", "Emil", "Kanat", "Nurlan", "Talant", "Ulan", "Azamat"},
+		FemaleNames:     []string{"Aigul", "Aizhan", "Begimai", "Cholpon", "Elmira", "Nazira", "Saltanat", "Zhyldyz"},
+		LastNames:       []string{"Asanov", "Bakirov", "Dzhumabaev", "Isakov", "Mamytov", "Osmonov", "Sadykov", "Toktogulov"},
+		FemaleLastNames: []string{"Asanova", "Bakirova", "Dzhumabaeva", "Isakova", "Mamytova", "Osmonova", "Sadykova", "Toktogulova"},
+	},
+	{
+		Code: "KAZ", Weight: 10, DocPrefix: "N", IssuedBy: "MINISTRY OF INTERNAL AFFAIRS",
+		MaleNames:       []string{"Arman", "Daniyar", "Erlan", "Marat", "Nursultan", "Serik", "Yerzhan", "Askar"},
+		FemaleNames:     []string{"Aida", "Aliya", "Dana", "Gulnara", "Kamila", "Saule", "Zhanar", "Aruzhan"},
+		LastNames:       []string{"Abenov", "Baimukhanov", "Zhakupov", "Kassymov", "Nurpeisov", "Omarov", "Seitkaliev", "Tulegenov"},
+		FemaleLastNames: []string{"Abenova", "Baimukhanova", "Zhakupova", "Kassymova", "Nurpeisova", "Omarova", "Seitkalieva", "Tulegenova"},
+	},
+	{
+		Code: "ARM", Weight: 8, DocPrefix: "AT", IssuedBy: "001",
+		MaleNames:   []string{"Aram", "Armen", "Davit", "Gevorg", "Hayk", "Narek", "Tigran", "Vardan"},
+		FemaleNames: []string{"Anahit", "Ani", "Arpi", "Gayane", "Lusine", "Mariam", "Nare", "Siranush"},
+		LastNames:   []string{"Hakobyan", "Harutyunyan", "Grigoryan", "Karapetyan", "Khachatryan", "Petrosyan", "Sargsyan", "Vardanyan"},
+	},
+	{
+		Code: "AZE", Weight: 6, DocPrefix: "C", IssuedBy: "MINISTRY OF INTERNAL AFFAIRS",
+		MaleNames:       []string{"Elvin", "Farid", "Kamran", "Orkhan", "Rashad", "Samir", "Tural", "Vugar"},
+		FemaleNames:     []string{"Aygun", "Gunel", "Leyla", "Narmin", "Sabina", "Sevinj", "Ulviyya", "Aysel"},
+		LastNames:       []string{"Aliyev", "Hasanov", "Huseynov", "Ismayilov", "Mammadov", "Guliyev", "Rzayev", "Jafarov"},
+		FemaleLastNames: []string{"Aliyeva", "Hasanova", "Huseynova", "Ismayilova", "Mammadova", "Guliyeva", "Rzayeva", "Jafarova"},
+	},
+	{
+		Code: "UKR", Weight: 5, DocPrefix: "FK", IssuedBy: "8012",
+		MaleNames:   []string{"Andrii", "Bohdan", "Dmytro", "Oleksandr", "Mykola", "Serhii", "Taras", "Yurii"},
+		FemaleNames: []string{"Halyna", "Iryna", "Kateryna", "Oksana", "Olena", "Tetiana", "Yuliia", "Nataliia"},
+		LastNames:   []string{"Bondarenko", "Kovalenko", "Kravchenko", "Melnyk", "Shevchenko", "Tkachenko", "Boiko", "Lysenko"},
+	},
+	{
+		Code: "BLR", Weight: 4, DocPrefix: "MP", IssuedBy: "MINISTRY OF INTERNAL AFFAIRS",
+		MaleNames:       []string{"Aliaksandr", "Andrei", "Dzmitry", "Ihar", "Mikalai", "Siarhei", "Uladzimir", "Pavel"},
+		FemaleNames:     []string{"Alena", "Hanna", "Iryna", "Katsiaryna", "Maryia", "Natallia", "Tatsiana", "Volha"},
+		LastNames:       []string{"Ivanou", "Kavaliou", "Kazlou", "Marozau", "Navumau", "Piatrou", "Sakalou", "Zhuk"},
+		FemaleLastNames: []string{"Ivanova", "Kavaliova", "Kazlova", "Marozava", "Navumava", "Piatrova", "Sakalova", "Zhuk"},
+	},
+	{
+		Code: "CHN", Weight: 3, DocPrefix: "E", IssuedBy: "NATIONAL IMMIGRATION ADMINISTRATION, PRC",
+		MaleNames:   []string{"Wei", "Jun", "Hao", "Lei", "Ming", "Qiang", "Tao", "Yong"},
+		FemaleNames: []string{"Fang", "Jing", "Li", "Mei", "Na", "Xiu", "Yan", "Ying"},
+		LastNames:   []string{"Wang", "Li", "Zhang", "Liu", "Chen", "Yang", "Huang", "Zhao"},
+	},
+	{
+		Code: "TUR", Weight: 2, DocPrefix: "U", IssuedBy: "T.C. ICISLERI BAKANLIGI",
+		MaleNames:   []string{"Ahmet", "Emre", "Mehmet", "Murat", "Mustafa", "Burak", "Can", "Kerem"},
+		FemaleNames: []string{"Ayse", "Elif", "Emine", "Fatma", "Zeynep", "Merve", "Esra", "Selin"},
+		LastNames:   []string{"Yilmaz", "Kaya", "Demir", "Sahin", "Celik", "Yildiz", "Aydin", "Ozturk"},
+	},
+}
+
+// isForeign определяет по хешу, является ли персона иностранным гражданином
+func isForeign(hash [32]byte) bool {
+	return int(hash[20])%100 < foreignShare
+}
+
+// pickCountry выбирает страну гражданства с учетом весов
+func pickCountry(b byte) country {
+	total := 0
+	for _, c := range countries {
+		total += c.Weight
+	}
+
+	n := int(b) % total
+	for _, c := range countries {
+		if n < c.Weight {
+			return c
+		}
+		n -= c.Weight
+	}
+	return countries[0]
+}
+
+// applyForeignCitizen превращает персону в иностранного гражданина:
+// латинские ФИО без отчества, FID_DOC вместо паспорта РФ, СНИЛС и ИНН есть не у всех
+func applyForeignCitizen(user *UserData, hash [32]byte, hashStr string, birthYear int) {
+	c := pickCountry(hash[21])
+
+	firstNames := c.MaleNames
+	lastNames := c.LastNames
+	if user.Gender == "F" {
+		firstNames = c.FemaleNames
+		if c.FemaleLastNames != nil {
+			lastNames = c.FemaleLastNames
+		}
+	}
+
+	user.FirstName = firstNames[int(hash[0])%len(firstNames)]
+	user.LastName = lastNames[int(hash[1])%len(lastNames)]
+	user.MiddleName = ""
+	user.Citizenship = c.Code
+	user.Email = strings.ToLower(fmt.Sprintf("%s.%s.%s@example.com", user.FirstName, user.LastName, hashStr[33:40]))
+
+	// ИНН получают ~40% иностранцев (при постановке на налоговый учет), СНИЛС — около половины
+	if hash[24]%5 >= 2 {
+		user.INN = ""
+	}
+	if hash[25]%2 != 0 {
+		user.SNILS = ""
+	}
+
+	// Паспорт иностранного гражданина действует 10 лет: выдан в 2016-2024 годах, но не ранее 18 лет
+	issueYear := foreignDocBaseYear - int(hash[27])%9
+	if issueYear < birthYear+18 {
+		issueYear = birthYear + 18
+	}
+	issueDay := 1 + int(hash[28])%28
+	issueMonth := 1 + int(hash[29])%12
+
+	user.Documents = []Document{{
+		ID:           documentID(hashStr),
+		Type:         DocTypeForeign,
+		Number:       fmt.Sprintf("%s%07d", c.DocPrefix, parseHexToNumber(hashStr[54:62])%10000000),
+		IssueDate:    fmt.Sprintf("%02d.%02d.%d", issueDay, issueMonth, issueYear),
+		IssuedBy:     c.IssuedBy,
+		ExpiryDate:   fmt.Sprintf("%02d.%02d.%d", issueDay, issueMonth, issueYear+10),
+		IssueCountry: c.Code,
+		Verified:     hash[26]%3 != 0,
+	}}
+}
@@ -0,0 +1,108 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// Версии алгоритма генерации персон. Выход каждой версии стабилен: любые правки
+// наборов имен или разбора хеша оформляются новой версией, а старые остаются доступны
+const (
+	GeneratorV1 = 1
+	GeneratorV2 = 2
+
+	// LatestGeneratorVersion самая новая версия; включается только явно
+	LatestGeneratorVersion = GeneratorV2
+
+	// DefaultGeneratorVersion используется, если версия не задана явно. Не меняется
+	// с выходом новых версий: иначе незакрепленные окружения получат другие персоны
+	DefaultGeneratorVersion = GeneratorV1
+)
+
+// generator строит персону по хешу телефона
+type generator func(hash [32]byte, phoneNumber string) *UserData
+
+var generators = map[int]generator{
+	GeneratorV1: generateV1,
+	GeneratorV2: generateV2,
+}
+
+// GeneratorVersions возвращает поддерживаемые версии генерации по возрастанию
+func GeneratorVersions() []int {
+	versions := make([]int, 0, len(generators))
+	for v := range generators {
+		versions = append(versions, v)
+	}
+	sort.Ints(versions)
+	return versions
+}
+
+// resolveGeneratorVersion проверяет версию; 0 означает версию по умолчанию
+func resolveGeneratorVersion(version int) (int, error) {
+	if version == 0 {
+		return DefaultGeneratorVersion, nil
+	}
+	if _, ok := generators[version]; !ok {
+		return 0, fmt.Errorf("unknown generator version %d, supported: %v", version, GeneratorVersions())
+	}
+	return version, nil
+}
+
+// generateUserData генерирует уникальные моковые данные на основе номера телефона
+func (c *Cache) generateUserData(phoneNumber string) *UserData {
+	// Используем хеш телефона для генерации детерминированных, но уникальных данных
+	user := generators[c.version](c.hash(phoneNumber), phoneNumber)
+	user.GeneratorVersion = c.version
+	return user
+}
+
+// hash возвращает sha256 от телефона с учетом seed
+func (c *Cache) hash(phoneNumber string) [32]byte {
+	if c.seed == "" {
+		return sha256.Sum256([]byte(phoneNumber))
+	}
+	return sha256.Sum256([]byte(c.seed + ":" + phoneNumber))
+}
+
+// parseHexToNumber парсит hex строку в uint64
+func parseHexToNumber(hexStr string) uint64 {
+	num, err := strconv.ParseUint(hexStr, 16, 64)
+	if err != nil {
+		return 0
+	}
+	return num
+}
+
+// transliterate простая транслитерация русских имен в латиницу
+func transliterate(name string) string {
+	translit := map[rune]string{
+		// Заглавные
+		'А': "A", 'Б': "B", 'В': "V", 'Г': "G", 'Д': "D",
+		'Е': "E", 'Ё': "Yo", 'Ж': "Zh", 'З': "Z", 'И': "I",
+		'Й': "Y", 'К': "K", 'Л': "L", 'М': "M", 'Н': "N",
+		'О': "O", 'П': "P", 'Р': "R", 'С': "S", 'Т': "T",
+		'У': "U", 'Ф': "F", 'Х': "H", 'Ц': "Ts", 'Ч': "Ch",
+		'Ш': "Sh", 'Щ': "Sch", 'Ъ': "", 'Ы': "Y", 'Ь': "",
+		'Э': "E", 'Ю': "Yu", 'Я': "Ya",
+		// Строчные
+		'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d",
+		'е': "e", 'ё': "yo", 'ж': "zh", 'з': "z", 'и': "i",
+		'й': "y", 'к': "k", 'л': "l", 'м': "m", 'н': "n",
+		'о': "o", 'п': "p", 'р': "r", 'с': "s", 'т': "t",
+		'у': "u", 'ф': "f", 'х': "h", 'ц': "ts", 'ч': "ch",
+		'ш': "sh", 'щ': "sch", 'ъ': "", 'ы': "y", 'ь': "",
+		'э': "e", 'ю': "yu", 'я': "ya",
+	}
+
+	result := ""
+	for _, r := range name {
+		if t, ok := translit[r]; ok {
+			result += t
+		} else {
+			result += string(r)
+		}
+	}
+	return result
+}
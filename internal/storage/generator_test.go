@@ -0,0 +1,104 @@
+package storage
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "перезаписать golden-файлы генераторов")
+
+// goldenSeeds и goldenPhones — фиксированный набор входов golden-тестов
+var (
+	goldenSeeds  = []string{"", "staging"}
+	goldenPhones = func() []string {
+		phones := []string{"+79991234567", "+79109876543", "79644223811"}
+		for i := 0; i < 40; i++ {
+			phones = append(phones, fmt.Sprintf("7999%07d", i*7919))
+		}
+		return phones
+	}()
+)
+
+type goldenPersona struct {
+	Seed  string
+	Phone string
+	User  *UserData
+}
+
+// TestGeneratorGolden проверяет, что выход каждой версии генерации не изменился.
+// Для новой версии golden-файл создается командой: go test ./internal/storage -update
+func TestGeneratorGolden(t *testing.T) {
+	for _, version := range GeneratorVersions() {
+		t.Run(fmt.Sprintf("v%d", version), func(t *testing.T) {
+			var personas []goldenPersona
+			for _, seed := range goldenSeeds {
+				c, err := New(Options{Seed: seed, GeneratorVersion: version})
+				if err != nil {
+					t.Fatalf("New: %v", err)
+				}
+				for _, phone := range goldenPhones {
+					personas = append(personas, goldenPersona{Seed: seed, Phone: phone, User: c.GetOrCreate(phone)})
+				}
+			}
+
+			got, err := json.MarshalIndent(personas, "", "  ")
+			if err != nil {
+				t.Fatalf("marshal: %v", err)
+			}
+			got = append(got, '\n')
+
+			path := filepath.Join("testdata", "golden", fmt.Sprintf("v%d.json", version))
+			if *update {
+				if err := os.WriteFile(path, got, 0o644); err != nil {
+					t.Fatalf("write golden: %v", err)
+				}
+				return
+			}
+
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("read golden (создайте его флагом -update): %v", err)
+			}
+
+			// Сравниваем значения, а не байты: новые пустые поля UserData не считаются дрейфом
+			var want, current []goldenPersona
+			if err := json.Unmarshal(data, &want); err != nil {
+				t.Fatalf("unmarshal golden: %v", err)
+			}
+			if err := json.Unmarshal(got, &current); err != nil {
+				t.Fatalf("unmarshal output: %v", err)
+			}
+			if len(current) != len(want) {
+				t.Fatalf("golden %s has %d personas, generated %d", path, len(want), len(current))
+			}
+			for i := range want {
+				if !reflect.DeepEqual(current[i], want[i]) {
+					t.Errorf("output of generator v%d drifted from %s for phone %s (seed %q): add a new generator version instead of changing this one",
+						version, path, want[i].Phone, want[i].Seed)
+				}
+			}
+		})
+	}
+}
+
+func TestNewGeneratorVersion(t *testing.T) {
+	c, err := New(Options{})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if c.GeneratorVersion() != DefaultGeneratorVersion {
+		t.Errorf("default version = %d, want %d", c.GeneratorVersion(), DefaultGeneratorVersion)
+	}
+	if v := c.GetOrCreate("+79991234567").GeneratorVersion; v != DefaultGeneratorVersion {
+		t.Errorf("persona version = %d, want %d", v, DefaultGeneratorVersion)
+	}
+
+	if _, err := New(Options{GeneratorVersion: 999}); err == nil {
+		t.Error("expected error for unknown generator version")
+	}
+}
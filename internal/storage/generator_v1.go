@@ -0,0 +1,114 @@
+package storage
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// generateV1 исходный алгоритм генерации: независимые срезы хеша телефона,
+// ~12% иностранных граждан с FID_DOC.
+// Выход зафиксирован golden-файлом testdata/golden/v1.json — не изменяйте эту функцию
+// и используемые ею наборы имен, добавляйте новую версию
+func generateV1(hash [32]byte, phoneNumber string) *UserData {
+	hashStr := hex.EncodeToString(hash[:])
+
+	// Генерируем OID из первых 10 символов хеша
+	oidNum := parseHexToNumber(hashStr[:10])
+	oid := fmt.Sprintf("%d", 1000000000+oidNum%1000000000)
+
+	// Имена из разных наборов в зависимости от хеша
+	firstNames := []string{"Иван", "Петр", "Сергей", "Александр", "Дмитрий", "Андрей", "Михаил", "Алексей", "Николай", "Владимир"}
+	lastNames := []string{"Иванов", "Петров", "Сидоров", "Смирнов", "Кузнецов", "Попов", "Васильев", "Павлов", "Соколов", "Михайлов"}
+	middleNames := []string{"Иванович", "Петрович", "Сергеевич", "Александрович", "Дмитриевич", "Андреевич", "Михайлович", "Алексеевич", "Николаевич", "Владимирович"}
+
+	firstNameIdx := int(hash[0]) % len(firstNames)
+	lastNameIdx := int(hash[1]) % len(lastNames)
+	middleNameIdx := int(hash[2]) % len(middleNames)
+
+	// Генерируем дату рождения (годы 1970-2000)
+	year := 1970 + (int(hash[3]) % 31)
+	month := 1 + (int(hash[4]) % 12)
+	day := 1 + (int(hash[5]) % 28) // безопасное значение для любого месяца
+	birthDate := fmt.Sprintf("%02d.%02d.%d", day, month, year)
+
+	// Пол (M или F)
+	gender := "M"
+	if hash[6]%2 == 0 {
+		gender = "F"
+		// Для женщин используем женские окончания отчеств
+		femaleMiddleNames := []string{"Ивановна", "Петровна", "Сергеевна", "Александровна", "Дмитриевна", "Андреевна", "Михайловна", "Алексеевна", "Николаевна", "Владимировна"}
+		middleNames = femaleMiddleNames
+		middleNameIdx = int(hash[2]) % len(middleNames)
+
+		// Женские фамилии с окончанием -ова/-ева
+		lastNames = []string{"Иванова", "Петрова", "Сидорова", "Смирнова", "Кузнецова", "Попова", "Васильева", "Павлова", "Соколова", "Михайлова"}
+		lastNameIdx = int(hash[1]) % len(lastNames)
+
+		// Женские имена
+		firstNames = []string{"Мария", "Анна", "Елена", "Ольга", "Татьяна", "Наталья", "Ирина", "Светлана", "Екатерина", "Юлия"}
+		firstNameIdx = int(hash[0]) % len(firstNames)
+	}
+
+	// Генерируем SNILS (11 цифр)
+	snilsNum := parseHexToNumber(hashStr[10:21])
+	snils := fmt.Sprintf("%011d", snilsNum%100000000000)
+
+	// Генерируем ИНН (12 цифр)
+	innNum := parseHexToNumber(hashStr[21:33])
+	inn := fmt.Sprintf("%012d", innNum%1000000000000)
+
+	// Email на основе имени и хеша (транслитерация + lowercase)
+	emailHash := hashStr[33:40]
+	email := strings.ToLower(fmt.Sprintf("%s.%s.%s@example.com",
+		transliterate(firstNames[firstNameIdx]),
+		transliterate(lastNames[lastNameIdx]),
+		emailHash))
+
+	user := &UserData{
+		OID:         oid,
+		FirstName:   firstNames[firstNameIdx],
+		LastName:    lastNames[lastNameIdx],
+		MiddleName:  middleNames[middleNameIdx],
+		BirthDate:   birthDate,
+		Gender:      gender,
+		SNILS:       snils,
+		INN:         inn,
+		Email:       email,
+		Mobile:      phoneNumber,
+		Trusted:     hash[7]%2 == 0, // 50% trusted
+		Verified:    hash[8]%3 != 0, // ~66% verified
+		Citizenship: CitizenshipRUS,
+		Status:      "REGISTERED",
+	}
+
+	// Часть персон — иностранные граждане (байты 20+ хеша ранее не использовались)
+	if isForeign(hash) {
+		applyForeignCitizen(user, hash, hashStr, year)
+		return user
+	}
+
+	user.Documents = []Document{generatePassport(hash, hashStr, year)}
+	return user
+}
+
+// generatePassport генерирует паспорт гражданина РФ, выданный в 20 лет
+func generatePassport(hash [32]byte, hashStr string, birthYear int) Document {
+	issueDate := fmt.Sprintf("%02d.%02d.%d", 1+int(hash[28])%28, 1+int(hash[29])%12, birthYear+20)
+
+	return Document{
+		ID:        documentID(hashStr),
+		Type:      DocTypeRFPassport,
+		Series:    fmt.Sprintf("%04d", parseHexToNumber(hashStr[52:56])%10000),
+		Number:    fmt.Sprintf("%06d", parseHexToNumber(hashStr[56:62])%1000000),
+		IssueDate: issueDate,
+		IssueID:   fmt.Sprintf("%03d-%03d", int(hash[30])%1000, int(hash[31])%1000),
+		IssuedBy:  "ОТДЕЛОМ УФМС РОССИИ",
+		Verified:  true,
+	}
+}
+
+// documentID генерирует числовой идентификатор документа
+func documentID(hashStr string) string {
+	return fmt.Sprintf("%d", 10000000+parseHexToNumber(hashStr[44:52])%90000000)
+}
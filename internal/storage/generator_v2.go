@@ -0,0 +1,69 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// generateV2 согласует поля персоны с регионом. Регион определяется по DEF-коду
+// телефона, а для неизвестных кодов выбирается по хешу. Из региона выводятся код
+// инспекции в ИНН, серия и код подразделения паспорта, адрес регистрации.
+// ИНН и СНИЛС получают корректные контрольные разряды.
+// Базовые поля (ФИО, дата рождения, пол, OID, статусы) совпадают с v1.
+// Выход зафиксирован golden-файлом testdata/golden/v2.json
+func generateV2(hash [32]byte, phoneNumber string) *UserData {
+	user := generateV1(hash, phoneNumber)
+
+	// Байты хеша v1 исчерпаны: берем производный хеш для новых полей
+	ext := sha256.Sum256(append(hash[:], "v2"...))
+	next := func(i int) uint64 {
+		return binary.BigEndian.Uint64(ext[i*8 : i*8+8])
+	}
+
+	reg, ok := regionByPhone(phoneNumber)
+	if !ok {
+		reg = pickRegion(next(0))
+	}
+	user.Region = reg.Code
+
+	// ИНН: есть у всех граждан РФ, у иностранцев — если он был выдан в v1
+	if user.INN != "" {
+		taxOffice := fmt.Sprintf("%s%02d", reg.Code, 1+next(1)%uint64(reg.TaxOffices))
+		user.INN = personalINN(taxOffice, next(1)>>16)
+	}
+	if user.SNILS != "" {
+		user.SNILS = snilsWithChecksum(next(2))
+	}
+
+	for i := range user.Documents {
+		doc := &user.Documents[i]
+		if doc.Type != DocTypeRFPassport {
+			continue
+		}
+		issueYear, _ := strconv.Atoi(doc.IssueDate[strings.LastIndex(doc.IssueDate, ".")+1:])
+		doc.Series = fmt.Sprintf("%s%02d", reg.OKATO, issueYear%100)
+		doc.IssueID = fmt.Sprintf("%s-%03d", reg.PassportDep, 1+next(3)%60)
+		doc.IssuedBy = reg.IssuedBy
+	}
+
+	// Гражданин РФ зарегистрирован в регионе, иностранец там проживает
+	addrType := AddrTypeRegistration
+	if user.IsForeign() {
+		addrType = AddrTypeResidence
+	}
+	user.Addresses = []Address{{
+		ID:      fmt.Sprintf("%d", 10000000+(next(3)>>32)%90000000),
+		Type:    addrType,
+		Region:  reg.Name,
+		City:    reg.Cities[int(ext[0])%len(reg.Cities)],
+		Street:  streets[int(ext[1])%len(streets)],
+		House:   strconv.Itoa(1 + int(ext[2])%120),
+		Flat:    strconv.Itoa(1 + int(ext[3])%300),
+		ZipCode: fmt.Sprintf("%s%03d", reg.ZipPrefixes[int(ext[4])%len(reg.ZipPrefixes)], int(binary.BigEndian.Uint16(ext[5:7]))%1000),
+	}}
+
+	return user
+}
@@ -0,0 +1,190 @@
+package storage
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxHintAttempts число попыток подобрать персону нужного пола
+const maxHintAttempts = 64
+
+// birthDateLayout формат дат в данных ЕСИА
+const birthDateLayout = "02.01.2006"
+
+// Hint ограничения на генерируемую персону (login_hint, persona_age, persona_gender)
+type Hint struct {
+	Age    int    `json:"age,omitempty"`    // полных лет; 0 — не задано
+	Gender string `json:"gender,omitempty"` // "M" или "F"; пустая строка — не задано
+}
+
+// IsZero возвращает true, если ограничения не заданы
+func (h Hint) IsZero() bool {
+	return h.Age == 0 && h.Gender == ""
+}
+
+// String возвращает подсказку в формате login_hint
+func (h Hint) String() string {
+	var parts []string
+	if h.Age > 0 {
+		parts = append(parts, "persona_age="+strconv.Itoa(h.Age))
+	}
+	if h.Gender != "" {
+		parts = append(parts, "persona_gender="+h.Gender)
+	}
+	return strings.Join(parts, ";")
+}
+
+// Matches проверяет, удовлетворяет ли персона ограничениям на дату now
+func (h Hint) Matches(user *UserData, now time.Time) bool {
+	if h.Gender != "" && user.Gender != h.Gender {
+		return false
+	}
+	if h.Age > 0 {
+		age, ok := ageAt(user.BirthDate, now)
+		if !ok || age != h.Age {
+			return false
+		}
+	}
+	return true
+}
+
+// ParseAge разбирает возраст персоны
+func ParseAge(value string) (int, error) {
+	age, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || age < 1 || age > 120 {
+		return 0, fmt.Errorf("invalid persona age %q", value)
+	}
+	return age, nil
+}
+
+// ParseGender разбирает пол персоны: M/F (без учета регистра)
+func ParseGender(value string) (string, error) {
+	switch strings.ToUpper(strings.TrimSpace(value)) {
+	case "M", "MALE":
+		return "M", nil
+	case "F", "FEMALE":
+		return "F", nil
+	}
+	return "", fmt.Errorf("invalid persona gender %q", value)
+}
+
+// HintReferenceDate дата, на которую считается возраст из подсказки. Дата фиксирована,
+// чтобы персона по подсказке воспроизводилась между запусками и не менялась со временем
+var HintReferenceDate = time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// GetOrCreateWithHint возвращает персону телефона, удовлетворяющую ограничениям. Если
+// персона телефона им не соответствует, возвращается ее детерминированный вариант с тем же
+// OID; вариант не сохраняется, и персона телефона остается прежней
+func (c *Cache) GetOrCreateWithHint(phoneNumber string, hint Hint) *UserData {
+	return c.withHint(phoneNumber, c.GetOrCreate(phoneNumber), hint)
+}
+
+// GetWithHint как GetOrCreateWithHint, но не создает персону телефона
+func (c *Cache) GetWithHint(phoneNumber string, hint Hint) (*UserData, bool) {
+	user, exists := c.load(phoneNumber)
+	if !exists {
+		return nil, false
+	}
+	return c.withHint(phoneNumber, user, hint), true
+}
+
+// withHint возвращает user, если персона удовлетворяет ограничениям, иначе ее вариант по подсказке.
+// Явно заданные поля важнее подсказки
+func (c *Cache) withHint(phoneNumber string, user *UserData, hint Hint) *UserData {
+	if hint.Matches(user, HintReferenceDate) {
+		return user
+	}
+
+	user = c.generateWithHint(phoneNumber, hint, HintReferenceDate)
+	user.Hint = &hint
+	c.applyOverride(phoneNumber, user)
+	return user
+}
+
+// generateWithHint генерирует персону с учетом ограничений. Пол подбирается перебором
+// производных хешей телефона, возраст задается датой рождения относительно now.
+// OID остается OID персоны телефона
+func (c *Cache) generateWithHint(phoneNumber string, hint Hint, now time.Time) *UserData {
+	user := c.generateUserData(phoneNumber)
+	oid := user.OID
+
+	for attempt := 1; hint.Gender != "" && user.Gender != hint.Gender && attempt <= maxHintAttempts; attempt++ {
+		user = generators[c.version](c.hash(fmt.Sprintf("%s#%d", phoneNumber, attempt)), phoneNumber)
+		user.GeneratorVersion = c.version
+	}
+	user.OID = oid
+
+	if hint.Age > 0 {
+		hash := c.hash(phoneNumber)
+		applyAge(user, hint.Age, uint16(hash[10])<<8|uint16(hash[11]), now)
+	}
+
+	return user
+}
+
+// applyAge переносит дату рождения так, чтобы на дату now персоне было age полных лет,
+// и согласует с ней даты выдачи документов
+func applyAge(user *UserData, age int, salt uint16, now time.Time) {
+	now = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	// Подходящие даты рождения: (now - (age+1) лет; now - age лет]
+	latest := now.AddDate(-age, 0, 0)
+	earliest := now.AddDate(-age-1, 0, 1)
+	span := int(latest.Sub(earliest).Hours()/24) + 1
+	birth := earliest.AddDate(0, 0, int(salt)%span)
+
+	user.BirthDate = birth.Format(birthDateLayout)
+	alignDocuments(user, birth, now)
+}
+
+// alignDocuments согласует документы с датой рождения: паспорт РФ выдается с 14 лет,
+// ни один документ не выдается раньше рождения или позже now
+func alignDocuments(user *UserData, birth, now time.Time) {
+	now = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	age, _ := ageAt(birth.Format(birthDateLayout), now)
+
+	docs := user.Documents[:0]
+	for _, doc := range user.Documents {
+		// Паспорт РФ выдается с 14 лет
+		if doc.Type == DocTypeRFPassport && age < 14 {
+			continue
+		}
+
+		minIssue := birth
+		if doc.Type == DocTypeRFPassport {
+			minIssue = birth.AddDate(14, 0, 0)
+		}
+
+		issue, err := time.Parse(birthDateLayout, doc.IssueDate)
+		if err != nil || issue.Before(minIssue) || issue.After(now) {
+			issue = minIssue
+			doc.IssueDate = issue.Format(birthDateLayout)
+			// Последние две цифры серии паспорта РФ — год изготовления бланка
+			if doc.Type == DocTypeRFPassport && len(doc.Series) == 4 {
+				doc.Series = doc.Series[:2] + fmt.Sprintf("%02d", issue.Year()%100)
+			}
+			if doc.ExpiryDate != "" {
+				doc.ExpiryDate = issue.AddDate(10, 0, 0).Format(birthDateLayout)
+			}
+		}
+
+		docs = append(docs, doc)
+	}
+	user.Documents = docs
+}
+
+// ageAt возвращает число полных лет на дату now
+func ageAt(birthDate string, now time.Time) (int, bool) {
+	birth, err := time.Parse(birthDateLayout, birthDate)
+	if err != nil {
+		return 0, false
+	}
+
+	age := now.Year() - birth.Year()
+	if now.Month() < birth.Month() || (now.Month() == birth.Month() && now.Day() < birth.Day()) {
+		age--
+	}
+	return age, true
+}
@@ -0,0 +1,66 @@
+package storage
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestGetOrCreateWithHint(t *testing.T) {
+	for _, hint := range []Hint{
+		{Age: 15},
+		{Gender: "F"},
+		{Age: 10, Gender: "M"},
+		{Age: 70, Gender: "F"},
+	} {
+		t.Run(hint.String(), func(t *testing.T) {
+			for i := 0; i < 20; i++ {
+				phone := fmt.Sprintf("7999%07d", i)
+
+				first, _ := New(Options{})
+				base := first.GetOrCreate(phone)
+				user := first.GetOrCreateWithHint(phone, hint)
+				if !hint.Matches(user, HintReferenceDate) {
+					t.Fatalf("%s: persona %s/%s does not match hint", phone, user.BirthDate, user.Gender)
+				}
+				if user.OID != base.OID {
+					t.Errorf("%s: hinted persona OID %s, want %s", phone, user.OID, base.OID)
+				}
+				if hint.Age > 0 && hint.Age < 14 {
+					for _, doc := range user.Documents {
+						if doc.Type == DocTypeRFPassport {
+							t.Errorf("%s: passport issued to a %d year old", phone, hint.Age)
+						}
+					}
+				}
+
+				// Персона телефона остается прежней
+				if stored, _ := first.Get(phone); stored.Hint != nil || stored.BirthDate != base.BirthDate || stored.Gender != base.Gender {
+					t.Errorf("%s: hinted persona replaced the phone persona", phone)
+				}
+
+				// Новый кеш генерирует ту же персону
+				second, _ := New(Options{})
+				if other := second.GetOrCreateWithHint(phone, hint); other.OID != user.OID || other.BirthDate != user.BirthDate || other.FirstName != user.FirstName {
+					t.Errorf("%s: hinted generation is not deterministic", phone)
+				}
+			}
+		})
+	}
+}
+
+func TestGetWithHint(t *testing.T) {
+	c, _ := New(Options{})
+	hint := Hint{Age: 30}
+	if _, ok := c.GetWithHint("79990000001", hint); ok {
+		t.Fatal("persona returned before login")
+	}
+	if c.Count() != 0 {
+		t.Fatal("GetWithHint created a persona")
+	}
+
+	want := c.GetOrCreateWithHint("79990000001", hint)
+	got, ok := c.GetWithHint("79990000001", hint)
+	if !ok || got.BirthDate != want.BirthDate || got.FirstName != want.FirstName {
+		t.Errorf("GetWithHint = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,102 @@
+package storage
+
+import (
+	"sync"
+	"time"
+)
+
+// memoryEntry запись in-memory хранилища
+type memoryEntry struct {
+	value     []byte
+	expiresAt time.Time
+}
+
+// MemoryBackend хранилище в памяти процесса
+type MemoryBackend struct {
+	buckets map[string]map[string]memoryEntry
+	mu      sync.RWMutex
+}
+
+// NewMemoryBackend создает пустое in-memory хранилище
+func NewMemoryBackend() *MemoryBackend {
+	return &MemoryBackend{
+		buckets: make(map[string]map[string]memoryEntry),
+	}
+}
+
+func (m *MemoryBackend) Get(bucket, key string) ([]byte, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	entry, exists := m.buckets[bucket][key]
+	if !exists || expired(entry.expiresAt) {
+		return nil, ErrNotFound
+	}
+	return entry.value, nil
+}
+
+func (m *MemoryBackend) Put(bucket, key string, value []byte, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.buckets[bucket] == nil {
+		m.buckets[bucket] = make(map[string]memoryEntry)
+	}
+	m.buckets[bucket][key] = memoryEntry{value: value, expiresAt: expiresAt(ttl)}
+	return nil
+}
+
+func (m *MemoryBackend) Take(bucket, key string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, exists := m.buckets[bucket][key]
+	if !exists || expired(entry.expiresAt) {
+		return nil, ErrNotFound
+	}
+	delete(m.buckets[bucket], key)
+	return entry.value, nil
+}
+
+func (m *MemoryBackend) Delete(bucket, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.buckets[bucket], key)
+	return nil
+}
+
+func (m *MemoryBackend) List(bucket string) (map[string][]byte, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	result := make(map[string][]byte, len(m.buckets[bucket]))
+	for k, entry := range m.buckets[bucket] {
+		if !expired(entry.expiresAt) {
+			result[k] = entry.value
+		}
+	}
+	return result, nil
+}
+
+func (m *MemoryBackend) Purge(bucket string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	purged := 0
+	for k, entry := range m.buckets[bucket] {
+		if expired(entry.expiresAt) {
+			delete(m.buckets[bucket], k)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+func (m *MemoryBackend) Ping() error {
+	return nil
+}
+
+func (m *MemoryBackend) Close() error {
+	return nil
+}
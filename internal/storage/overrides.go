@@ -0,0 +1,280 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vibe-gaming/esia-mock/internal/logger"
+	"go.uber.org/zap"
+)
+
+// Override поля персоны, заданные явно через admin API или фикстуры. Заданные (не nil)
+// поля имеют приоритет над сгенерированными и переживают перегенерацию и вытеснение персоны
+type Override struct {
+	OID         *string `json:"oid,omitempty"`
+	FirstName   *string `json:"firstName,omitempty"`
+	LastName    *string `json:"lastName,omitempty"`
+	MiddleName  *string `json:"middleName,omitempty"`
+	BirthDate   *string `json:"birthDate,omitempty"` // ДД.ММ.ГГГГ
+	Gender      *string `json:"gender,omitempty"`    // M или F
+	SNILS       *string `json:"snils,omitempty"`
+	INN         *string `json:"inn,omitempty"`
+	Email       *string `json:"email,omitempty"`
+	Trusted     *bool   `json:"trusted,omitempty"`
+	Verified    *bool   `json:"verified,omitempty"`
+	Citizenship *string `json:"citizenship,omitempty"` // ISO 3166-1 alpha-3
+	Status      *string `json:"status,omitempty"`
+
+	// Documents и Addresses заменяют сгенерированные документы и адреса целиком
+	Documents []Document `json:"documents,omitempty"`
+	Addresses []Address  `json:"addresses,omitempty"`
+}
+
+// Merge возвращает копию o, в которой поля, заданные в patch, заменены
+func (o Override) Merge(patch Override) Override {
+	merged := o
+	mergeField(&merged.OID, patch.OID)
+	mergeField(&merged.FirstName, patch.FirstName)
+	mergeField(&merged.LastName, patch.LastName)
+	mergeField(&merged.MiddleName, patch.MiddleName)
+	mergeField(&merged.BirthDate, patch.BirthDate)
+	mergeField(&merged.Gender, patch.Gender)
+	mergeField(&merged.SNILS, patch.SNILS)
+	mergeField(&merged.INN, patch.INN)
+	mergeField(&merged.Email, patch.Email)
+	mergeField(&merged.Trusted, patch.Trusted)
+	mergeField(&merged.Verified, patch.Verified)
+	mergeField(&merged.Citizenship, patch.Citizenship)
+	mergeField(&merged.Status, patch.Status)
+	if patch.Documents != nil {
+		merged.Documents = patch.Documents
+	}
+	if patch.Addresses != nil {
+		merged.Addresses = patch.Addresses
+	}
+	return merged
+}
+
+// Clear снимает заданное поле по его имени в JSON: персона вернется к сгенерированному значению.
+// false — такого поля нет
+func (o *Override) Clear(field string) bool {
+	switch field {
+	case "oid":
+		o.OID = nil
+	case "firstName":
+		o.FirstName = nil
+	case "lastName":
+		o.LastName = nil
+	case "middleName":
+		o.MiddleName = nil
+	case "birthDate":
+		o.BirthDate = nil
+	case "gender":
+		o.Gender = nil
+	case "snils":
+		o.SNILS = nil
+	case "inn":
+		o.INN = nil
+	case "email":
+		o.Email = nil
+	case "trusted":
+		o.Trusted = nil
+	case "verified":
+		o.Verified = nil
+	case "citizenship":
+		o.Citizenship = nil
+	case "status":
+		o.Status = nil
+	case "documents":
+		o.Documents = nil
+	case "addresses":
+		o.Addresses = nil
+	default:
+		return false
+	}
+	return true
+}
+
+// mergeField заменяет значение поля, если оно задано в патче
+func mergeField[T any](dst **T, src *T) {
+	if src != nil {
+		*dst = src
+	}
+}
+
+// Normalize проверяет значения полей и приводит их к формату ЕСИА
+func (o *Override) Normalize() error {
+	if o.OID != nil {
+		if _, err := strconv.ParseUint(*o.OID, 10, 64); err != nil {
+			return fmt.Errorf("invalid oid %q, expected digits", *o.OID)
+		}
+	}
+	if o.BirthDate != nil {
+		birth, err := time.Parse(birthDateLayout, *o.BirthDate)
+		if err != nil {
+			return fmt.Errorf("invalid birthDate %q, expected DD.MM.YYYY", *o.BirthDate)
+		}
+		if birth.After(time.Now()) {
+			return fmt.Errorf("birthDate %q is in the future", *o.BirthDate)
+		}
+	}
+	if o.Gender != nil {
+		gender, err := ParseGender(*o.Gender)
+		if err != nil {
+			return err
+		}
+		o.Gender = &gender
+	}
+	if o.Citizenship != nil {
+		citizenship := strings.ToUpper(strings.TrimSpace(*o.Citizenship))
+		if len(citizenship) != 3 {
+			return fmt.Errorf("invalid citizenship %q, expected ISO 3166-1 alpha-3 code", *o.Citizenship)
+		}
+		o.Citizenship = &citizenship
+	}
+	return nil
+}
+
+// Apply переносит заданные поля в персону. Сгенерированные документы согласуются
+// с новой датой рождения, заданные явно — нет
+func (o Override) Apply(user *UserData) {
+	applyField(&user.OID, o.OID)
+	applyField(&user.FirstName, o.FirstName)
+	applyField(&user.LastName, o.LastName)
+	applyField(&user.MiddleName, o.MiddleName)
+	applyField(&user.Gender, o.Gender)
+	applyField(&user.SNILS, o.SNILS)
+	applyField(&user.INN, o.INN)
+	applyField(&user.Email, o.Email)
+	applyField(&user.Trusted, o.Trusted)
+	applyField(&user.Verified, o.Verified)
+	applyField(&user.Citizenship, o.Citizenship)
+	applyField(&user.Status, o.Status)
+	if o.Addresses != nil {
+		user.Addresses = append([]Address(nil), o.Addresses...)
+	}
+
+	if o.Documents != nil {
+		user.Documents = append([]Document(nil), o.Documents...)
+		applyField(&user.BirthDate, o.BirthDate)
+	} else if o.BirthDate != nil && *o.BirthDate != user.BirthDate {
+		if birth, err := time.Parse(birthDateLayout, *o.BirthDate); err == nil {
+			user.BirthDate = *o.BirthDate
+			alignDocuments(user, birth, time.Now())
+		}
+	}
+}
+
+// applyField записывает значение в поле персоны, если оно задано
+func applyField[T any](dst *T, src *T) {
+	if src != nil {
+		*dst = *src
+	}
+}
+
+// Override возвращает заданные для телефона поля
+func (c *Cache) Override(phoneNumber string) (Override, bool, error) {
+	var o Override
+	err := GetJSON(c.backend, BucketOverrides, phoneNumber, &o)
+	if IsNotFound(err) {
+		return Override{}, false, nil
+	}
+	if err != nil {
+		return Override{}, false, err
+	}
+	return o, true, nil
+}
+
+// SetOverride сохраняет заданные поля для телефона и применяет их к персоне
+func (c *Cache) SetOverride(phoneNumber string, o Override) (*UserData, error) {
+	if err := o.Normalize(); err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := PutJSON(c.backend, BucketOverrides, phoneNumber, o, 0); err != nil {
+		return nil, fmt.Errorf("save override: %w", err)
+	}
+
+	// Персона перегенерируется, чтобы снятые с переопределения поля вернулись к исходным
+	user := c.generateUserData(phoneNumber)
+	o.Apply(user)
+	c.save(phoneNumber, user)
+	return user, nil
+}
+
+// Delete удаляет персону и ее переопределения; при следующем входе она будет сгенерирована заново
+func (c *Cache) Delete(phoneNumber string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.backend.Delete(BucketOverrides, phoneNumber); err != nil {
+		return err
+	}
+	c.lruMu.Lock()
+	c.untrack(phoneNumber)
+	c.lruMu.Unlock()
+	return c.backend.Delete(BucketUsers, phoneNumber)
+}
+
+// FindByOID ищет сохраненную персону по OID и возвращает ее телефон
+func (c *Cache) FindByOID(oid string) (string, *UserData, bool) {
+	for phone, user := range c.GetAll() {
+		if user.OID == oid {
+			return phone, user, true
+		}
+	}
+	return "", nil, false
+}
+
+// Overrides возвращает переопределения всех телефонов
+func (c *Cache) Overrides() (map[string]*Override, error) {
+	entries, err := c.backend.List(BucketOverrides)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]*Override, len(entries))
+	for phone, data := range entries {
+		var o Override
+		if err := json.Unmarshal(data, &o); err != nil {
+			return nil, fmt.Errorf("decode override %s: %w", phone, err)
+		}
+		result[phone] = &o
+	}
+	return result, nil
+}
+
+// ImportOverrides загружает переопределения; при replace существующие удаляются.
+// Сохраненные персоны не меняются: снапшот содержит их уже с примененными полями
+func (c *Cache) ImportOverrides(overrides map[string]*Override, replace bool) error {
+	if replace {
+		if err := Clear(c.backend, BucketOverrides); err != nil {
+			return err
+		}
+	}
+
+	for phone, o := range overrides {
+		if err := PutJSON(c.backend, BucketOverrides, phone, o, 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyOverride применяет к сгенерированной персоне заданные для телефона поля
+func (c *Cache) applyOverride(phoneNumber string, user *UserData) {
+	o, ok, err := c.Override(phoneNumber)
+	if err != nil {
+		logger.Error("Failed to load override", zap.String("phone", phoneNumber), zap.Error(err))
+		return
+	}
+	if ok {
+		o.Apply(user)
+	}
+}
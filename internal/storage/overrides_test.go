@@ -0,0 +1,68 @@
+package storage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestOverrideTakesPrecedence(t *testing.T) {
+	c, err := New(Options{MaxUsers: 1})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	const phone = "79161234567"
+	generated := c.GetOrCreate(phone)
+
+	name, birth, trusted := "Анна", "15.03.2010", false
+	user, err := c.SetOverride(phone, Override{FirstName: &name, BirthDate: &birth, Trusted: &trusted})
+	if err != nil {
+		t.Fatalf("SetOverride: %v", err)
+	}
+	if user.FirstName != name || user.BirthDate != birth || user.Trusted {
+		t.Fatalf("override not applied: %+v", user)
+	}
+	if user.OID != generated.OID || user.LastName != generated.LastName {
+		t.Errorf("fields without override changed: %+v", user)
+	}
+
+	// Паспорт РФ не выдается раньше 14 лет
+	birthTime, _ := time.Parse(birthDateLayout, birth)
+	for _, doc := range user.Documents {
+		issue, _ := time.Parse(birthDateLayout, doc.IssueDate)
+		if issue.Before(birthTime) {
+			t.Errorf("document %s issued before birth: %s", doc.ID, doc.IssueDate)
+		}
+	}
+
+	// Переопределение переживает вытеснение персоны и важнее подсказки
+	c.GetOrCreate("79990000001")
+	if again := c.GetOrCreateWithHint(phone, Hint{Age: 40}); again.FirstName != name || again.BirthDate != birth {
+		t.Errorf("override lost after eviction: %s %s", again.FirstName, again.BirthDate)
+	}
+
+	if err := c.Delete(phone); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if again := c.GetOrCreate(phone); again.FirstName != generated.FirstName {
+		t.Errorf("FirstName after Delete = %s, want generated %s", again.FirstName, generated.FirstName)
+	}
+}
+
+func TestOverrideNormalize(t *testing.T) {
+	gender, citizenship := "female", "rus"
+	o := Override{Gender: &gender, Citizenship: &citizenship}
+	if err := o.Normalize(); err != nil {
+		t.Fatalf("Normalize: %v", err)
+	}
+	if *o.Gender != "F" || *o.Citizenship != "RUS" {
+		t.Errorf("Normalize = %s/%s, want F/RUS", *o.Gender, *o.Citizenship)
+	}
+
+	for _, bad := range []string{"2010", "31.02.2010", "01.01.2999"} {
+		birth := bad
+		if err := (&Override{BirthDate: &birth}).Normalize(); err == nil {
+			t.Errorf("Normalize accepted birthDate %q", bad)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// redisKeyPrefix префикс ключей мока в Redis
+const redisKeyPrefix = "esia-mock:"
+
+// RedisBackend хранилище в Redis: несколько реплик мока за балансировщиком видят
+// общие коды, токены и персоны. Ключ записи — esia-mock:<bucket>:<key>, срок жизни
+// задается штатным TTL Redis
+type RedisBackend struct {
+	client *redis.Client
+}
+
+// OpenRedisBackend подключается к Redis по URL вида redis://[:password@]host:6379/0
+func OpenRedisBackend(rawURL string) (*RedisBackend, error) {
+	if rawURL == "" {
+		return nil, fmt.Errorf("redis storage url is empty")
+	}
+
+	opts, err := redis.ParseURL(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("parse redis url: %w", err)
+	}
+
+	client := redis.NewClient(opts)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("connect to redis %s: %w", opts.Addr, err)
+	}
+
+	return &RedisBackend{client: client}, nil
+}
+
+func (r *RedisBackend) Get(bucket, key string) ([]byte, error) {
+	value, err := r.client.Get(context.Background(), redisKey(bucket, key)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrNotFound
+	}
+	return value, err
+}
+
+func (r *RedisBackend) Put(bucket, key string, value []byte, ttl time.Duration) error {
+	if ttl < 0 {
+		ttl = 0
+	}
+	return r.client.Set(context.Background(), redisKey(bucket, key), value, ttl).Err()
+}
+
+func (r *RedisBackend) Take(bucket, key string) ([]byte, error) {
+	// GETDEL атомарен: код обменяет только одна реплика
+	value, err := r.client.GetDel(context.Background(), redisKey(bucket, key)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrNotFound
+	}
+	return value, err
+}
+
+func (r *RedisBackend) Delete(bucket, key string) error {
+	return r.client.Del(context.Background(), redisKey(bucket, key)).Err()
+}
+
+func (r *RedisBackend) List(bucket string) (map[string][]byte, error) {
+	ctx := context.Background()
+	prefix := redisKey(bucket, "")
+	result := make(map[string][]byte)
+
+	iter := r.client.Scan(ctx, 0, prefix+"*", 100).Iterator()
+	var keys []string
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+	}
+	if err := iter.Err(); err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return result, nil
+	}
+
+	values, err := r.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+	for i, v := range values {
+		// Запись могла истечь между SCAN и MGET
+		s, ok := v.(string)
+		if !ok {
+			continue
+		}
+		result[strings.TrimPrefix(keys[i], prefix)] = []byte(s)
+	}
+	return result, nil
+}
+
+// Purge ничего не делает: истекшие ключи Redis удаляет сам
+func (r *RedisBackend) Purge(bucket string) (int, error) {
+	return 0, nil
+}
+
+func (r *RedisBackend) Ping() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	return r.client.Ping(ctx).Err()
+}
+
+func (r *RedisBackend) Close() error {
+	return r.client.Close()
+}
+
+// redisKey строит ключ Redis для записи бакета
+func redisKey(bucket, key string) string {
+	return redisKeyPrefix + bucket + ":" + key
+}
@@ -0,0 +1,197 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+)
+
+// region субъект РФ, из которого согласованно выводятся ИНН, паспорт, адрес и телефон.
+// Таблица входит в алгоритм генерации v2: для изменений заведите новую версию
+type region struct {
+	Code        string   // код субъекта, первые две цифры ИНН
+	Name        string   // наименование для адреса
+	Weight      int      // относительная частота (примерно по численности населения)
+	TaxOffices  int      // число налоговых инспекций: ИНН начинается с Code + номер инспекции
+	OKATO       string   // первые две цифры серии паспорта
+	PassportDep string   // первые три цифры кода подразделения
+	IssuedBy    string   // подразделение, выдающее паспорта
+	Cities      []string // населенные пункты
+	ZipPrefixes []string // первые три цифры почтовых индексов
+	MobileCodes []string // DEF-коды мобильных операторов региона
+}
+
+var regions = []region{
+	{
+		Code: "77", Name: "г. Москва", Weight: 30, TaxOffices: 51, OKATO: "45", PassportDep: "770",
+		IssuedBy:    "ГУ МВД РОССИИ ПО Г. МОСКВЕ",
+		Cities:      []string{"Москва"},
+		ZipPrefixes: []string{"101", "105", "107", "109", "115", "117", "119", "121", "123", "125", "127", "129"},
+		MobileCodes: []string{"903", "905", "915", "916", "925", "926", "985", "999"},
+	},
+	{
+		Code: "78", Name: "г. Санкт-Петербург", Weight: 15, TaxOffices: 27, OKATO: "40", PassportDep: "780",
+		IssuedBy:    "ГУ МВД РОССИИ ПО Г. САНКТ-ПЕТЕРБУРГУ И ЛЕНИНГРАДСКОЙ ОБЛ.",
+		Cities:      []string{"Санкт-Петербург"},
+		ZipPrefixes: []string{"190", "191", "192", "193", "194", "195", "196", "197", "198", "199"},
+		MobileCodes: []string{"904", "911", "921", "931", "981"},
+	},
+	{
+		Code: "50", Name: "Московская обл.", Weight: 14, TaxOffices: 53, OKATO: "46", PassportDep: "500",
+		IssuedBy:    "ГУ МВД РОССИИ ПО МОСКОВСКОЙ ОБЛ.",
+		Cities:      []string{"Балашиха", "Химки", "Подольск", "Мытищи", "Королёв", "Люберцы"},
+		ZipPrefixes: []string{"140", "141", "142", "143", "144"},
+		MobileCodes: []string{"964", "965", "968"},
+	},
+	{
+		Code: "23", Name: "Краснодарский край", Weight: 8, TaxOffices: 75, OKATO: "03", PassportDep: "230",
+		IssuedBy:    "ГУ МВД РОССИИ ПО КРАСНОДАРСКОМУ КРАЮ",
+		Cities:      []string{"Краснодар", "Сочи", "Новороссийск", "Армавир"},
+		ZipPrefixes: []string{"350", "352", "353", "354"},
+		MobileCodes: []string{"918", "988"},
+	},
+	{
+		Code: "66", Name: "Свердловская обл.", Weight: 7, TaxOffices: 86, OKATO: "65", PassportDep: "660",
+		IssuedBy:    "ГУ МВД РОССИИ ПО СВЕРДЛОВСКОЙ ОБЛ.",
+		Cities:      []string{"Екатеринбург", "Нижний Тагил", "Каменск-Уральский"},
+		ZipPrefixes: []string{"620", "622", "623", "624"},
+		MobileCodes: []string{"912", "922", "982"},
+	},
+	{
+		Code: "16", Name: "Респ. Татарстан", Weight: 6, TaxOffices: 90, OKATO: "92", PassportDep: "160",
+		IssuedBy:    "МВД ПО РЕСПУБЛИКЕ ТАТАРСТАН",
+		Cities:      []string{"Казань", "Набережные Челны", "Альметьевск"},
+		ZipPrefixes: []string{"420", "422", "423"},
+		MobileCodes: []string{"917", "987"},
+	},
+	{
+		Code: "61", Name: "Ростовская обл.", Weight: 6, TaxOffices: 96, OKATO: "60", PassportDep: "610",
+		IssuedBy:    "ГУ МВД РОССИИ ПО РОСТОВСКОЙ ОБЛ.",
+		Cities:      []string{"Ростов-на-Дону", "Таганрог", "Шахты"},
+		ZipPrefixes: []string{"344", "346", "347"},
+		MobileCodes: []string{"908", "928", "951"},
+	},
+	{
+		Code: "52", Name: "Нижегородская обл.", Weight: 5, TaxOffices: 62, OKATO: "22", PassportDep: "520",
+		IssuedBy:    "ГУ МВД РОССИИ ПО НИЖЕГОРОДСКОЙ ОБЛ.",
+		Cities:      []string{"Нижний Новгород", "Дзержинск", "Арзамас"},
+		ZipPrefixes: []string{"603", "606", "607"},
+		MobileCodes: []string{"920", "930", "910"},
+	},
+	{
+		Code: "54", Name: "Новосибирская обл.", Weight: 5, TaxOffices: 83, OKATO: "50", PassportDep: "540",
+		IssuedBy:    "ГУ МВД РОССИИ ПО НОВОСИБИРСКОЙ ОБЛ.",
+		Cities:      []string{"Новосибирск", "Бердск", "Искитим"},
+		ZipPrefixes: []string{"630", "632", "633"},
+		MobileCodes: []string{"913", "923", "983"},
+	},
+	{
+		Code: "63", Name: "Самарская обл.", Weight: 4, TaxOffices: 82, OKATO: "36", PassportDep: "630",
+		IssuedBy:    "ГУ МВД РОССИИ ПО САМАРСКОЙ ОБЛ.",
+		Cities:      []string{"Самара", "Тольятти", "Сызрань"},
+		ZipPrefixes: []string{"443", "445", "446"},
+		MobileCodes: []string{"927", "937", "939"},
+	},
+}
+
+// streets улицы, встречающиеся практически в любом городе
+var streets = []string{
+	"Ленина", "Советская", "Мира", "Садовая", "Молодежная", "Школьная", "Лесная",
+	"Центральная", "Гагарина", "Пушкина", "Новая", "Набережная", "Октябрьская", "Заводская",
+}
+
+// regionByPhone определяет регион по DEF-коду мобильного номера
+func regionByPhone(phoneNumber string) (region, bool) {
+	digits := NormalizePhone(phoneNumber)
+
+	if len(digits) != 11 || (digits[0] != '7' && digits[0] != '8') {
+		return region{}, false
+	}
+
+	def := digits[1:4]
+	for _, reg := range regions {
+		for _, code := range reg.MobileCodes {
+			if code == def {
+				return reg, true
+			}
+		}
+	}
+	return region{}, false
+}
+
+// NormalizePhone оставляет в номере телефона только цифры: так номера хранятся
+// в моке, например 79644223811
+func NormalizePhone(phoneNumber string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, phoneNumber)
+}
+
+// pickRegion выбирает регион с учетом весов
+func pickRegion(n uint64) region {
+	total := 0
+	for _, reg := range regions {
+		total += reg.Weight
+	}
+
+	idx := int(n % uint64(total))
+	for _, reg := range regions {
+		if idx < reg.Weight {
+			return reg
+		}
+		idx -= reg.Weight
+	}
+	return regions[0]
+}
+
+// personalINN строит ИНН физлица: код инспекции, порядковый номер и два контрольных разряда
+func personalINN(taxOffice string, serial uint64) string {
+	digits := []int{}
+	for _, r := range fmt.Sprintf("%s%06d", taxOffice, serial%1000000) {
+		digits = append(digits, int(r-'0'))
+	}
+
+	checksum := func(weights []int) int {
+		sum := 0
+		for i, w := range weights {
+			sum += digits[i] * w
+		}
+		return sum % 11 % 10
+	}
+
+	digits = append(digits, checksum([]int{7, 2, 4, 10, 3, 5, 9, 4, 6, 8}))
+	digits = append(digits, checksum([]int{3, 7, 2, 4, 10, 3, 5, 9, 4, 6, 8}))
+
+	var b strings.Builder
+	for _, d := range digits {
+		b.WriteByte(byte('0' + d))
+	}
+	return b.String()
+}
+
+// snilsWithChecksum строит СНИЛС из 9-значного номера и контрольного числа
+func snilsWithChecksum(number uint64) string {
+	// Номера до 001-001-998 не проверяются контрольным числом, не используем их
+	number = 1001999 + number%(999999999-1001999)
+	num := fmt.Sprintf("%09d", number)
+	return fmt.Sprintf("%s%02d", num, snilsControl(num))
+}
+
+// snilsControl вычисляет контрольное число для 9 цифр номера СНИЛС
+func snilsControl(num string) int {
+	sum := 0
+	for i, r := range num {
+		sum += int(r-'0') * (9 - i)
+	}
+
+	control := sum
+	if sum >= 100 {
+		control = sum % 101
+		if control == 100 {
+			control = 0
+		}
+	}
+	return control
+}
@@ -0,0 +1,63 @@
+package storage
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestGenerateV2RegionConsistency(t *testing.T) {
+	c, err := New(Options{GeneratorVersion: GeneratorV2})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	for i := 0; i < 200; i++ {
+		phone := fmt.Sprintf("79%09d", i*4999999)
+		user := c.GetOrCreate(phone)
+
+		var reg region
+		for _, r := range regions {
+			if r.Code == user.Region {
+				reg = r
+			}
+		}
+		if reg.Code == "" {
+			t.Fatalf("%s: unknown region %q", phone, user.Region)
+		}
+		if byPhone, ok := regionByPhone(phone); ok && byPhone.Code != reg.Code {
+			t.Errorf("%s: region %s, phone operator region %s", phone, reg.Code, byPhone.Code)
+		}
+
+		if user.INN != "" {
+			if !strings.HasPrefix(user.INN, reg.Code) {
+				t.Errorf("%s: INN %s outside region %s", phone, user.INN, reg.Code)
+			}
+			if personalINN(user.INN[:4], mustAtoi(t, user.INN[4:10])) != user.INN {
+				t.Errorf("%s: INN %s has invalid checksum", phone, user.INN)
+			}
+		}
+		if user.SNILS != "" && fmt.Sprintf("%02d", snilsControl(user.SNILS[:9])) != user.SNILS[9:] {
+			t.Errorf("%s: SNILS %s has invalid checksum", phone, user.SNILS)
+		}
+
+		for _, doc := range user.Documents {
+			if doc.Type == DocTypeRFPassport && (!strings.HasPrefix(doc.Series, reg.OKATO) || !strings.HasPrefix(doc.IssueID, reg.PassportDep)) {
+				t.Errorf("%s: passport %s/%s issued outside region %s", phone, doc.Series, doc.IssueID, reg.Code)
+			}
+		}
+		if len(user.Addresses) != 1 || user.Addresses[0].Region != reg.Name {
+			t.Errorf("%s: address is not in region %s", phone, reg.Name)
+		}
+	}
+}
+
+func mustAtoi(t *testing.T, s string) uint64 {
+	t.Helper()
+	n, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		t.Fatalf("parse %q: %v", s, err)
+	}
+	return n
+}